@@ -2,8 +2,9 @@ package bitnut
 
 import (
     "context"
-    "fmt"
     "net/http"
+
+    "github.com/hardyzp/bitnut/common"
 )
 
 // GetBalanceService get account balance
@@ -31,14 +32,12 @@ func (s *GetBalanceService) Do(ctx context.Context, opts ...RequestOption) (*Bal
     if err != nil {
         return &Balance{}, err
     }
-    ret := new(BalanceResponse)
-    err = json.Unmarshal(data, &ret)
+    res := new(Balance)
+    _, _, err = decodeResponse(data, res)
     if err != nil {
-        fmt.Println(err)
         return &Balance{}, err
     }
-    fmt.Println("ret", ret)
-    return &ret.Data, nil
+    return res, nil
 }
 
 // BalanceResponse define user balance of your account
@@ -54,3 +53,13 @@ type Balance struct {
     Free   string `json:"free"`
     Freeze string `json:"freeze"`
 }
+
+// FreeDecimal return free as a Decimal, empty parses as zero
+func (b *Balance) FreeDecimal() (common.Decimal, error) {
+    return parseDecimal("free", b.Free)
+}
+
+// FreezeDecimal return freeze as a Decimal, empty parses as zero
+func (b *Balance) FreezeDecimal() (common.Decimal, error) {
+    return parseDecimal("freeze", b.Freeze)
+}
@@ -0,0 +1,247 @@
+package bitnuttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hardyzp/bitnut/common"
+)
+
+// Mode define whether a Recorder records or replays
+type Mode int
+
+// Recorder modes
+const (
+	ModeReplay Mode = iota
+	ModeRecord
+)
+
+// volatileParams are dropped from recorded requests, they change on every
+// call or carry secrets
+var volatileParams = map[string]bool{
+	"timestamp":  true,
+	"recvWindow": true,
+	"signature":  true,
+	"clientOid":  true,
+}
+
+// Scrubber rewrite a response body before it is recorded
+type Scrubber func(body []byte) []byte
+
+// ScrubKeys return a Scrubber masking the values of the given keys, in any
+// case, at any depth of JSON bodies. Bodies without such keys are recorded
+// verbatim.
+func ScrubKeys(keys ...string) Scrubber {
+	masked := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		masked[strings.ToLower(k)] = true
+	}
+	sensitive := func(key string) bool { return masked[strings.ToLower(key)] }
+	return func(body []byte) []byte {
+		return common.RedactJSON(body, sensitive)
+	}
+}
+
+// Interaction define a recorded request/response pair
+type Interaction struct {
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+	// Params are the query and form parameters, encoded in key order
+	// without the volatile ones
+	Params      string `json:"params"`
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body"`
+}
+
+// Cassette define the content of a fixture file
+type Cassette struct {
+	// Note describe where the fixture comes from, e.g. that it was written
+	// by hand rather than recorded
+	Note         string         `json:"note,omitempty"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records the exchanges with the
+// server to a fixture file, or replays them from it without network access.
+// Request headers are never recorded, so API keys and signatures don't end
+// up in fixtures, and response bodies go through a Scrubber that masks the
+// keys the client never logs, common.SensitiveKeys, by default.
+type Recorder struct {
+	mode      Mode
+	path      string
+	transport http.RoundTripper
+	scrub     Scrubber
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewRecorder init a recorder on the fixture at path. In replay mode the
+// fixture is loaded now. In record mode requests are sent with transport,
+// http.DefaultTransport when nil, and the fixture is written by Save.
+func NewRecorder(path string, mode Mode, transport http.RoundTripper) (*Recorder, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	r := &Recorder{mode: mode, path: path, transport: transport, scrub: ScrubKeys(common.SensitiveKeys()...), cassette: &Cassette{}}
+	if mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(data, r.cassette)
+		if err != nil {
+			return nil, fmt.Errorf("bitnuttest: invalid cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// Scrubber set the scrubber of recorded response bodies, nil records them
+// verbatim. The response returned to the caller is never scrubbed.
+func (r *Recorder) Scrubber(scrub Scrubber) *Recorder {
+	r.scrub = scrub
+	return r
+}
+
+// HTTPClient return an http.Client using the recorder as transport
+func (r *Recorder) HTTPClient() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip record or replay req
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	params, err := normalizeParams(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, params)
+	}
+	return r.record(req, params)
+}
+
+func (r *Recorder) replay(req *http.Request, params string) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	match := -1
+	for i, it := range r.cassette.Interactions {
+		if it.Method != req.Method || it.Endpoint != req.URL.Path || it.Params != params {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("bitnuttest: no recorded interaction for %s %s?%s", req.Method, req.URL.Path, params)
+	}
+	r.used[match] = true
+	return r.cassette.Interactions[match].response(req), nil
+}
+
+func (r *Recorder) record(req *http.Request, params string) (*http.Response, error) {
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if r.scrub != nil {
+		body = r.scrub(body)
+	}
+	it := &Interaction{
+		Method:      req.Method,
+		Endpoint:    req.URL.Path,
+		Params:      params,
+		StatusCode:  res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+		Body:        string(body),
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, it)
+	r.mu.Unlock()
+	return res, nil
+}
+
+// Save write the recorded interactions to the fixture file
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(r.path), 0o755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+func (it *Interaction) response(req *http.Request) *http.Response {
+	header := http.Header{}
+	if it.ContentType != "" {
+		header.Set("Content-Type", it.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.StatusCode, http.StatusText(it.StatusCode)),
+		StatusCode:    it.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(it.Body)),
+		ContentLength: int64(len(it.Body)),
+		Request:       req,
+	}
+}
+
+// normalizeParams merge the query and form parameters of req, drop the
+// volatile ones and encode them in key order. The request body is restored.
+func normalizeParams(req *http.Request) (string, error) {
+	params := url.Values{}
+	for k, v := range req.URL.Query() {
+		params[k] = append(params[k], v...)
+	}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", err
+		}
+		for k, v := range form {
+			params[k] = append(params[k], v...)
+		}
+	}
+	for k := range params {
+		if volatileParams[k] {
+			delete(params, k)
+		}
+	}
+	return params.Encode(), nil
+}
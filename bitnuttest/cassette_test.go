@@ -0,0 +1,97 @@
+package bitnuttest
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hardyzp/bitnut"
+	"github.com/hardyzp/bitnut/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+	srv := NewServer()
+	srv.SetBalance("USDT", "1000", "0")
+	srv.SetDepth("BTCUSDT", &bitnut.Depth{Bids: [][2]string{{"9", "1"}}, Asks: [][2]string{{"11", "2"}}})
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := NewRecorder(path, ModeRecord, srv.Server.Client().Transport)
+	assert.NoError(err)
+	c := srv.Client()
+	c.HTTPClient = rec.HTTPClient()
+	ctx := context.Background()
+	_, err = c.NewDepthService().Symbol("BTCUSDT").Do(ctx)
+	assert.NoError(err)
+	_, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+		Type(bitnut.OrderTypeLimit).Quantity("1").Price("10").Do(ctx)
+	assert.NoError(err)
+	balance, err := c.NewGetBalanceService().SetCoin("USDT").Do(ctx)
+	assert.NoError(err)
+	assert.NoError(rec.Save())
+	srv.Close()
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.NotContains(string(data), DefaultAPIKey)
+	assert.NotContains(string(data), "timestamp")
+	assert.NotContains(string(data), "clientOid")
+
+	// the server is gone, the same calls are answered from the fixture
+	rec, err = NewRecorder(path, ModeReplay, nil)
+	assert.NoError(err)
+	c = bitnut.NewClient("other-key", "other-secret")
+	c.HTTPClient = rec.HTTPClient()
+	c.SetEnvironment(bitnut.CustomEnvironment("replay", "http://replay.invalid", ""))
+	depth, err := c.NewDepthService().Symbol("BTCUSDT").Do(ctx)
+	assert.NoError(err)
+	assert.Equal([2]string{"11", "2"}, depth.Asks[0])
+	_, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+		Type(bitnut.OrderTypeLimit).Quantity("1").Price("10").Do(ctx)
+	assert.NoError(err)
+	replayed, err := c.NewGetBalanceService().SetCoin("USDT").Do(ctx)
+	assert.NoError(err)
+	assert.Equal(balance, replayed)
+
+	_, err = c.NewDepthService().Symbol("ETHUSDT").Do(ctx, bitnut.WithRetryPolicy(nil))
+	assert.Error(err)
+	assert.True(strings.Contains(err.Error(), "no recorded interaction"))
+}
+
+func TestRecorderScrubsResponses(t *testing.T) {
+	assert := assert.New(t)
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetBalance("USDT", "1234.5", "0")
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := NewRecorder(path, ModeRecord, srv.Server.Client().Transport)
+	assert.NoError(err)
+	c := srv.Client()
+	c.HTTPClient = rec.HTTPClient()
+	ctx := context.Background()
+	listenKey, err := c.NewStartUserStreamService().Do(ctx)
+	assert.NoError(err)
+	assert.Equal(srv.ListenKeys(), []string{listenKey})
+	assert.NoError(rec.Save())
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.NotContains(string(data), listenKey)
+	assert.Contains(string(data), common.Redacted)
+
+	// account data is scrubbed on demand, the caller still sees it
+	rec, err = NewRecorder(path, ModeRecord, srv.Server.Client().Transport)
+	assert.NoError(err)
+	rec.Scrubber(ScrubKeys("Free", "FREEZE"))
+	c.HTTPClient = rec.HTTPClient()
+	balance, err := c.NewGetBalanceService().SetCoin("USDT").Do(ctx)
+	assert.NoError(err)
+	assert.Equal("1234.5", balance.Free)
+	assert.NoError(rec.Save())
+	data, err = ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.NotContains(string(data), "1234.5")
+}
@@ -0,0 +1,1042 @@
+// Package bitnuttest provides an in-process mock of the exchange REST API,
+// market data stream and user data stream for testing code built on the
+// bitnut SDK.
+package bitnuttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hardyzp/bitnut"
+	"github.com/hardyzp/bitnut/common"
+)
+
+// Credentials accepted by a new Server
+const (
+	DefaultAPIKey    = "test-api-key"
+	DefaultSecretKey = "test-secret-key"
+)
+
+// defaultRecvWindow is the recvWindow applied to requests that don't send one
+const defaultRecvWindow = 5000
+
+// Fault define a scripted failure of an endpoint
+type Fault struct {
+	// StatusCode is the HTTP status of the response, 200 when zero
+	StatusCode int
+	// Code and Msg fill the response envelope
+	Code int64
+	Msg  string
+	// Header is added to the response, e.g. Retry-After
+	Header http.Header
+	// Drop closes the connection without answering
+	Drop bool
+	// Processed handles the request before failing, as a server erroring
+	// after it committed the change
+	Processed bool
+}
+
+// Symbol define a market of the mock exchange and the trading rules it
+// reports through /v1/exchangeInfo. Rules left empty are not reported.
+type Symbol struct {
+	Name              string
+	BaseAsset         string
+	QuoteAsset        string
+	PricePrecision    int
+	QuantityPrecision int
+	TickSize          string
+	StepSize          string
+	MinQuantity       string
+	MaxQuantity       string
+	MinNotional       string
+}
+
+// info return the exchange info entry of the symbol
+func (s Symbol) info() bitnut.Symbol {
+	res := bitnut.Symbol{
+		Symbol:            s.Name,
+		Status:            bitnut.SymbolStatusTypeTrading,
+		BaseAsset:         s.BaseAsset,
+		QuoteAsset:        s.QuoteAsset,
+		PricePrecision:    s.PricePrecision,
+		QuantityPrecision: s.QuantityPrecision,
+	}
+	if s.TickSize != "" {
+		res.Filters.Price = &bitnut.PriceFilter{MinPrice: s.TickSize, MaxPrice: "0", TickSize: s.TickSize}
+	}
+	if s.StepSize != "" || s.MinQuantity != "" || s.MaxQuantity != "" {
+		res.Filters.LotSize = &bitnut.LotSizeFilter{MinQuantity: s.MinQuantity, MaxQuantity: s.MaxQuantity, StepSize: s.StepSize}
+	}
+	if s.MinNotional != "" {
+		res.Filters.MinNotional = &bitnut.MinNotionalFilter{MinNotional: s.MinNotional, ApplyToMarket: true}
+	}
+	return res
+}
+
+type balance struct {
+	free   *big.Rat
+	freeze *big.Rat
+}
+
+// Server is a mock exchange served by an httptest.Server. It verifies
+// signatures and timestamps, and keeps balances and orders in memory.
+type Server struct {
+	*httptest.Server
+
+	APIKey string
+	// Signer recomputes the expected BU-ACCESS-SIGN of signed requests
+	Signer bitnut.Signer
+	// Now is the server clock
+	Now func() time.Time
+
+	mu       sync.Mutex
+	symbols  map[string]Symbol
+	balances map[string]*balance
+	orders   []*bitnut.Order
+	nextID   int64
+	depths   map[string]*bitnut.Depth
+	klines   map[string][]*bitnut.Kline
+	tickers  map[string]*bitnut.SymbolTicker
+	trades   map[string][]*bitnut.Trade
+	fills    []*bitnut.AccountTrade
+	faults   map[string][]Fault
+	latency  map[string]time.Duration
+	requests map[string]int
+
+	// listen keys alive and the user events waiting to be sent
+	listenKeys    map[string]bool
+	nextListenKey int64
+	nextTradeID   int64
+	userEvents    []interface{}
+	pushMu        sync.Mutex
+
+	streamMu       sync.Mutex
+	streams        map[*streamConn]bool
+	streamConnects int
+}
+
+// NewServer start a mock exchange with the default credentials and a
+// BTCUSDT market
+func NewServer() *Server {
+	s := &Server{
+		APIKey:   DefaultAPIKey,
+		Signer:   bitnut.NewHMACSigner(DefaultSecretKey),
+		Now:      time.Now,
+		symbols:  map[string]Symbol{},
+		balances: map[string]*balance{},
+		depths:   map[string]*bitnut.Depth{},
+		klines:   map[string][]*bitnut.Kline{},
+		tickers:  map[string]*bitnut.SymbolTicker{},
+		trades:   map[string][]*bitnut.Trade{},
+		faults:   map[string][]Fault{},
+		latency:  map[string]time.Duration{},
+		requests: map[string]int{},
+		streams:  map[*streamConn]bool{},
+
+		listenKeys: map[string]bool{},
+	}
+	s.AddSymbol(Symbol{
+		Name:              "BTCUSDT",
+		BaseAsset:         "BTC",
+		QuoteAsset:        "USDT",
+		PricePrecision:    2,
+		QuantityPrecision: 6,
+		TickSize:          "0.01",
+		StepSize:          "0.000001",
+		MinQuantity:       "0.000001",
+		MaxQuantity:       "9000",
+		MinNotional:       "5",
+	})
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client return a client for the server, using the default credentials
+// and with rate limiting disabled
+func (s *Server) Client() *bitnut.Client {
+	c := bitnut.NewClient(DefaultAPIKey, DefaultSecretKey)
+	c.SetEnvironment(s.Environment())
+	c.HTTPClient = s.Server.Client()
+	c.Logger = bitnut.NopLogger()
+	c.RateLimiter = nil
+	c.RetryPolicy = &bitnut.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	return c
+}
+
+// Environment return a custom environment pointing at the server
+func (s *Server) Environment() bitnut.Environment {
+	env := bitnut.CustomEnvironment("bitnuttest", s.URL, s.StreamURL())
+	env.UserStreamURL = s.UserStreamURL()
+	return env
+}
+
+// AddSymbol add a market
+func (s *Server) AddSymbol(symbol Symbol) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols[symbol.Name] = symbol
+}
+
+// SetBalance set the free and frozen amounts of a coin
+func (s *Server) SetBalance(coin, free, freeze string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances[coin] = &balance{free: mustRat(free), freeze: mustRat(freeze)}
+}
+
+// Balance return the balance of a coin
+func (s *Server) Balance(coin string) bitnut.Balance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balance(coin)
+}
+
+func (s *Server) balance(coin string) bitnut.Balance {
+	b := s.wallet(coin)
+	return bitnut.Balance{Coin: coin, Free: formatRat(b.free), Freeze: formatRat(b.freeze)}
+}
+
+func (s *Server) wallet(coin string) *balance {
+	b, ok := s.balances[coin]
+	if !ok {
+		b = &balance{free: new(big.Rat), freeze: new(big.Rat)}
+		s.balances[coin] = b
+	}
+	return b
+}
+
+// SetDepth set the order book returned for a symbol
+func (s *Server) SetDepth(symbol string, depth *bitnut.Depth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.depths[symbol] = depth
+}
+
+// SetKlines set the klines returned for a symbol and interval
+func (s *Server) SetKlines(symbol string, interval bitnut.KlineInterval, klines []*bitnut.Kline) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.klines[symbol+"@"+string(interval)] = klines
+}
+
+// SetTicker set the 24h ticker of a symbol. Its last price is used to fill
+// market orders.
+func (s *Server) SetTicker(ticker *bitnut.SymbolTicker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickers[ticker.Symbol] = ticker
+}
+
+// AddTrades add market trades of a symbol, trades without ID are numbered
+// after the last trade
+func (s *Server) AddTrades(symbol string, trades ...bitnut.Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range trades {
+		t := t
+		if t.ID == 0 {
+			t.ID = s.nextTradeID + 1
+		}
+		if t.ID > s.nextTradeID {
+			s.nextTradeID = t.ID
+		}
+		s.trades[symbol] = append(s.trades[symbol], &t)
+	}
+}
+
+// Orders return a copy of every order, oldest first
+func (s *Server) Orders() []bitnut.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]bitnut.Order, len(s.orders))
+	for i, o := range s.orders {
+		res[i] = *o
+	}
+	return res
+}
+
+// Fail make the next calls to endpoint fail, one fault per call
+func (s *Server) Fail(endpoint string, faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[endpoint] = append(s.faults[endpoint], faults...)
+}
+
+// SetLatency delay every response of endpoint
+func (s *Server) SetLatency(endpoint string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency[endpoint] = latency
+}
+
+// Requests return how many requests endpoint received
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+// FillOrder fill qty of an open order at its price and settle the balances
+func (s *Server) FillOrder(orderID, qty string) error {
+	defer s.flushUserEvents()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(orderID, "")
+	if o == nil {
+		return fmt.Errorf("bitnuttest: unknown order %s", orderID)
+	}
+	_, err := s.fill(o, mustRat(qty), true)
+	return err
+}
+
+// fill execute qty of o, maker tells if o was resting in the book
+func (s *Server) fill(o *bitnut.Order, qty *big.Rat, maker bool) (*bitnut.AccountTrade, error) {
+	if !isOpen(o) {
+		return nil, fmt.Errorf("bitnuttest: order %s is %s", o.OrderID, o.Status)
+	}
+	executed := mustRat(o.ExecutedQuantity)
+	remaining := new(big.Rat).Sub(mustRat(o.OrigQuantity), executed)
+	if qty.Cmp(remaining) > 0 {
+		return nil, fmt.Errorf("bitnuttest: fill of %s exceeds remaining %s", formatRat(qty), formatRat(remaining))
+	}
+	sym := s.symbols[o.Symbol]
+	quote := new(big.Rat).Mul(qty, mustRat(o.Price))
+	base, counter := s.wallet(sym.BaseAsset), s.wallet(sym.QuoteAsset)
+	if o.Side == bitnut.SideTypeBuy {
+		counter.freeze.Sub(counter.freeze, quote)
+		base.free.Add(base.free, qty)
+	} else {
+		base.freeze.Sub(base.freeze, qty)
+		counter.free.Add(counter.free, quote)
+	}
+	executed.Add(executed, qty)
+	o.ExecutedQuantity = formatRat(executed)
+	o.Status = bitnut.OrderStatusTypePartiallyFilled
+	if executed.Cmp(mustRat(o.OrigQuantity)) == 0 {
+		o.Status = bitnut.OrderStatusTypeFilled
+	}
+	o.UpdateTime = bitnut.FormatTimestamp(s.Now())
+	s.nextTradeID++
+	trade := &bitnut.AccountTrade{
+		Symbol:          o.Symbol,
+		ID:              s.nextTradeID,
+		OrderID:         o.OrderID,
+		Price:           o.Price,
+		Quantity:        formatRat(qty),
+		QuoteQuantity:   formatRat(quote),
+		Commission:      "0",
+		CommissionAsset: sym.QuoteAsset,
+		Time:            o.UpdateTime,
+		IsBuyer:         o.Side == bitnut.SideTypeBuy,
+		IsMaker:         maker,
+	}
+	s.fills = append(s.fills, trade)
+	s.trades[o.Symbol] = append(s.trades[o.Symbol], &bitnut.Trade{
+		ID:            trade.ID,
+		Price:         trade.Price,
+		Quantity:      trade.Quantity,
+		QuoteQuantity: trade.QuoteQuantity,
+		Time:          trade.Time,
+		IsBuyerMaker:  trade.IsBuyer == maker,
+	})
+	s.pushOrder(o, bitnut.ExecutionTypeTrade, trade, "")
+	if o.Side == bitnut.SideTypeBuy {
+		s.pushBalance(sym.BaseAsset, qty)
+		s.pushBalance(sym.QuoteAsset, new(big.Rat))
+	} else {
+		s.pushBalance(sym.BaseAsset, new(big.Rat))
+		s.pushBalance(sym.QuoteAsset, quote)
+	}
+	return trade, nil
+}
+
+// isOpen tell if an order can still be filled or canceled
+func isOpen(o *bitnut.Order) bool {
+	return o.Status == bitnut.OrderStatusTypeNew || o.Status == bitnut.OrderStatusTypePartiallyFilled
+}
+
+type handlerFunc func(p url.Values) (interface{}, *common.APIError)
+
+type route struct {
+	method  string
+	signed  bool
+	handler handlerFunc
+}
+
+func (s *Server) routes() map[string]route {
+	return map[string]route{
+		"/v1/time":                  {http.MethodGet, false, s.handleTime},
+		"/v1/exchangeInfo":          {http.MethodGet, false, s.handleExchangeInfo},
+		"/v1/tick/depth":            {http.MethodGet, false, s.handleDepth},
+		"/v1/tick/kline":            {http.MethodGet, false, s.handleKlines},
+		"/v1/tick/24info":           {http.MethodGet, false, s.handleTicker},
+		"/v1/tick/trades":           {http.MethodGet, false, s.handleRecentTrades},
+		"/v1/tick/historicalTrades": {http.MethodGet, false, s.handleHistoricalTrades},
+		"/v1/tick/aggTrades":        {http.MethodGet, false, s.handleAggTrades},
+		"/v1/trade/order":           {http.MethodPost, true, s.handleCreateOrder},
+		"/v1/trade/cancel":          {http.MethodPost, true, s.handleCancelOrder},
+		"/v1/trade/open-cancel":     {http.MethodPost, true, s.handleCancelOpenOrders},
+		"/v1/spot/user/order":       {http.MethodPost, true, s.handleListOrders},
+		"/v1/spot/user/orderInfo":   {http.MethodPost, true, s.handleGetOrder},
+		"/v1/spot/user/trades":      {http.MethodPost, true, s.handleListTrades},
+		"/v1/asset/balance":         {http.MethodPost, true, s.handleBalance},
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	endpoint := req.URL.Path
+	s.mu.Lock()
+	s.requests[endpoint]++
+	latency := s.latency[endpoint]
+	var fault *Fault
+	if faults := s.faults[endpoint]; len(faults) > 0 {
+		fault = &faults[0]
+		s.faults[endpoint] = faults[1:]
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-req.Context().Done():
+			return
+		}
+	}
+	if fault != nil && !fault.Processed {
+		writeFault(w, fault)
+		return
+	}
+	switch {
+	case endpoint == streamPath:
+		s.serveStream(w, req, "")
+		return
+	case endpoint == listenKeyPath:
+		s.serveListenKey(w, req)
+		return
+	case strings.HasPrefix(endpoint, userStreamPath+"/"):
+		s.serveUserStream(w, req)
+		return
+	}
+	rt, ok := s.routes()[endpoint]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, envelope{Code: 404, Msg: "not found"})
+		return
+	}
+	if req.Method != rt.method {
+		writeJSON(w, http.StatusMethodNotAllowed, envelope{Code: 405, Msg: "method not allowed"})
+		return
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, envelope{Code: 400, Msg: err.Error()})
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, envelope{Code: 400, Msg: err.Error()})
+		return
+	}
+	params := req.URL.Query()
+	for k, v := range form {
+		params[k] = v
+	}
+	if rt.signed {
+		if apiErr := s.authenticate(req, string(body)); apiErr != nil {
+			writeJSON(w, http.StatusOK, envelope{Code: apiErr.Code, Msg: apiErr.Message})
+			return
+		}
+	}
+	s.mu.Lock()
+	data, apiErr := rt.handler(params)
+	s.mu.Unlock()
+	s.flushUserEvents()
+	if fault != nil {
+		writeFault(w, fault)
+		return
+	}
+	if apiErr != nil {
+		writeJSON(w, http.StatusOK, envelope{Code: apiErr.Code, Msg: apiErr.Message})
+		return
+	}
+	writeJSON(w, http.StatusOK, envelope{Code: 0, Msg: "success", Data: data})
+}
+
+// authenticate check the API key, the timestamp and the signature
+func (s *Server) authenticate(req *http.Request, body string) *common.APIError {
+	if req.Header.Get("BU-ACCESS-KEY") != s.APIKey {
+		return &common.APIError{Code: -2015, Message: "Invalid API-key"}
+	}
+	query := req.URL.Query()
+	ts, err := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+	if err != nil {
+		return &common.APIError{Code: -1102, Message: "Mandatory parameter 'timestamp' was not sent"}
+	}
+	recvWindow := int64(defaultRecvWindow)
+	if v := query.Get("recvWindow"); v != "" {
+		recvWindow, _ = strconv.ParseInt(v, 10, 64)
+	}
+	now := bitnut.FormatTimestamp(s.Now())
+	if ts > now+1000 || now-ts > recvWindow {
+		return &common.APIError{Code: common.CodeInvalidTimestamp, Message: "Timestamp for this request is outside of the recvWindow."}
+	}
+	want, err := s.Signer.Sign(&bitnut.SignPayload{
+		Method:   req.Method,
+		Endpoint: req.URL.Path,
+		Query:    req.URL.RawQuery,
+		Body:     body,
+	})
+	if err != nil || want != req.Header.Get("BU-ACCESS-SIGN") {
+		return &common.APIError{Code: common.CodeInvalidSignature, Message: "Signature for this request is not valid."}
+	}
+	return nil
+}
+
+func (s *Server) handleTime(p url.Values) (interface{}, *common.APIError) {
+	return map[string]int64{"ts": bitnut.FormatTimestamp(s.Now())}, nil
+}
+
+func (s *Server) handleExchangeInfo(p url.Values) (interface{}, *common.APIError) {
+	var filter map[string]bool
+	if symbol := p.Get("symbol"); symbol != "" {
+		filter = map[string]bool{symbol: true}
+	} else if symbols := p.Get("symbols"); symbols != "" {
+		var names []string
+		if err := json.Unmarshal([]byte(symbols), &names); err != nil {
+			return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'symbols'"}
+		}
+		filter = map[string]bool{}
+		for _, n := range names {
+			filter[n] = true
+		}
+	}
+	info := &bitnut.ExchangeInfo{Timezone: "UTC", ServerTime: bitnut.FormatTimestamp(s.Now())}
+	for _, sym := range s.symbols {
+		if filter == nil || filter[sym.Name] {
+			info.Symbols = append(info.Symbols, sym.info())
+		}
+	}
+	if filter != nil && len(info.Symbols) != len(filter) {
+		return nil, invalidSymbol()
+	}
+	sort.Slice(info.Symbols, func(i, j int) bool { return info.Symbols[i].Symbol < info.Symbols[j].Symbol })
+	return info, nil
+}
+
+func (s *Server) handleDepth(p url.Values) (interface{}, *common.APIError) {
+	symbol := p.Get("symbol")
+	if _, ok := s.symbols[symbol]; !ok {
+		return nil, invalidSymbol()
+	}
+	depth, ok := s.depths[symbol]
+	if !ok {
+		depth = &bitnut.Depth{Bids: [][2]string{}, Asks: [][2]string{}}
+	}
+	if limit, err := strconv.Atoi(p.Get("limit")); err == nil {
+		d := *depth
+		if len(d.Bids) > limit {
+			d.Bids = d.Bids[:limit]
+		}
+		if len(d.Asks) > limit {
+			d.Asks = d.Asks[:limit]
+		}
+		depth = &d
+	}
+	return depth, nil
+}
+
+func (s *Server) handleKlines(p url.Values) (interface{}, *common.APIError) {
+	symbol := p.Get("symbol")
+	if _, ok := s.symbols[symbol]; !ok {
+		return nil, invalidSymbol()
+	}
+	startTime, hasStart := int64Param(p, "startTime")
+	endTime, hasEnd := int64Param(p, "endTime")
+	limit, err := strconv.Atoi(p.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 500
+	}
+	res := make([][]interface{}, 0)
+	for _, k := range s.klines[symbol+"@"+p.Get("interval")] {
+		if (hasStart && k.OpenTime < startTime) || (hasEnd && k.OpenTime > endTime) {
+			continue
+		}
+		if len(res) == limit {
+			break
+		}
+		res = append(res, klineRow(k))
+	}
+	return res, nil
+}
+
+func (s *Server) handleTicker(p url.Values) (interface{}, *common.APIError) {
+	if symbol := p.Get("symbol"); symbol != "" {
+		t, ok := s.tickers[symbol]
+		if !ok {
+			return nil, invalidSymbol()
+		}
+		return t, nil
+	}
+	var filter map[string]bool
+	if symbols := p.Get("symbols"); symbols != "" {
+		var names []string
+		if err := json.Unmarshal([]byte(symbols), &names); err != nil {
+			return nil, &common.APIError{Code: -1100, Message: "Illegal characters found in parameter 'symbols'"}
+		}
+		filter = map[string]bool{}
+		for _, n := range names {
+			filter[n] = true
+		}
+	}
+	res := make([]*bitnut.SymbolTicker, 0, len(s.tickers))
+	for _, t := range s.tickers {
+		if filter == nil || filter[t.Symbol] {
+			res = append(res, t)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Symbol < res[j].Symbol })
+	return res, nil
+}
+
+func (s *Server) handleRecentTrades(p url.Values) (interface{}, *common.APIError) {
+	symbol := p.Get("symbol")
+	if _, ok := s.symbols[symbol]; !ok {
+		return nil, invalidSymbol()
+	}
+	return lastTrades(s.trades[symbol], limitParam(p)), nil
+}
+
+func (s *Server) handleHistoricalTrades(p url.Values) (interface{}, *common.APIError) {
+	symbol := p.Get("symbol")
+	if _, ok := s.symbols[symbol]; !ok {
+		return nil, invalidSymbol()
+	}
+	fromID, ok := int64Param(p, "fromId")
+	if !ok {
+		return lastTrades(s.trades[symbol], limitParam(p)), nil
+	}
+	res := make([]*bitnut.Trade, 0)
+	for _, t := range s.trades[symbol] {
+		if t.ID >= fromID && len(res) < limitParam(p) {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+// handleAggTrades aggregate every trade on its own
+func (s *Server) handleAggTrades(p url.Values) (interface{}, *common.APIError) {
+	symbol := p.Get("symbol")
+	if _, ok := s.symbols[symbol]; !ok {
+		return nil, invalidSymbol()
+	}
+	fromID, hasFromID := int64Param(p, "fromId")
+	startTime, hasStart := int64Param(p, "startTime")
+	endTime, hasEnd := int64Param(p, "endTime")
+	trades := s.trades[symbol]
+	if !hasFromID && !hasStart && !hasEnd {
+		trades = lastTrades(trades, limitParam(p))
+	}
+	res := make([]*bitnut.AggTrade, 0)
+	for _, t := range trades {
+		if (hasFromID && t.ID < fromID) || (hasStart && t.Time < startTime) || (hasEnd && t.Time > endTime) {
+			continue
+		}
+		if len(res) == limitParam(p) {
+			break
+		}
+		res = append(res, &bitnut.AggTrade{
+			AggTradeID:   t.ID,
+			Price:        t.Price,
+			Quantity:     t.Quantity,
+			FirstTradeID: t.ID,
+			LastTradeID:  t.ID,
+			Timestamp:    t.Time,
+			IsBuyerMaker: t.IsBuyerMaker,
+		})
+	}
+	return res, nil
+}
+
+func (s *Server) handleListTrades(p url.Values) (interface{}, *common.APIError) {
+	symbol := p.Get("symbol")
+	if _, ok := s.symbols[symbol]; !ok {
+		return nil, invalidSymbol()
+	}
+	orderID := p.Get("orderId")
+	fromID, hasFromID := int64Param(p, "fromId")
+	startTime, hasStart := int64Param(p, "startTime")
+	endTime, hasEnd := int64Param(p, "endTime")
+	res := make([]*bitnut.AccountTrade, 0)
+	for _, t := range s.fills {
+		if t.Symbol != symbol || (orderID != "" && t.OrderID != orderID) || (hasFromID && t.ID < fromID) ||
+			(hasStart && t.Time < startTime) || (hasEnd && t.Time > endTime) {
+			continue
+		}
+		if len(res) == limitParam(p) {
+			break
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// lastTrades return the limit most recent trades, oldest first
+func lastTrades(trades []*bitnut.Trade, limit int) []*bitnut.Trade {
+	if len(trades) > limit {
+		trades = trades[len(trades)-limit:]
+	}
+	return append([]*bitnut.Trade{}, trades...)
+}
+
+// handleCreateOrder place an order. Limit orders rest until filled with
+// FillOrder, the mock has no matching engine, so IOC and FOK orders expire
+// right away. Stop orders rest at their limit or stop price and are never
+// triggered. Market orders fill at the ticker price, those given a
+// quoteOrderQty buy or sell its worth, rounded down to the step size.
+func (s *Server) handleCreateOrder(p url.Values) (interface{}, *common.APIError) {
+	sym, ok := s.symbols[p.Get("symbol")]
+	if !ok {
+		return nil, invalidSymbol()
+	}
+	side := bitnut.SideType(p.Get("side"))
+	if side != bitnut.SideTypeBuy && side != bitnut.SideTypeSell {
+		return nil, &common.APIError{Code: -1117, Message: "Invalid side."}
+	}
+	orderType := bitnut.OrderType(p.Get("type"))
+	clientOrderID := p.Get("clientOid")
+	if clientOrderID != "" && s.findOrder("", clientOrderID) != nil {
+		return nil, &common.APIError{Code: common.CodeDuplicateOrder, Message: "Duplicate order sent."}
+	}
+	timeInForce := bitnut.TimeInForceType(p.Get("timeInForce"))
+	respType := bitnut.NewOrderRespType(p.Get("newOrderRespType"))
+	var price *big.Rat
+	switch orderType {
+	case bitnut.OrderTypeLimit, bitnut.OrderTypeLimitMaker, bitnut.OrderTypeStopLossLimit, bitnut.OrderTypeTakeProfitLimit:
+		price, ok = new(big.Rat).SetString(p.Get("price"))
+		if !ok || price.Sign() <= 0 {
+			return nil, &common.APIError{Code: -1013, Message: "Invalid price."}
+		}
+		if timeInForce == "" && orderType != bitnut.OrderTypeLimitMaker {
+			timeInForce = bitnut.TimeInForceTypeGTC
+		}
+	case bitnut.OrderTypeStopLoss, bitnut.OrderTypeTakeProfit:
+		price, ok = new(big.Rat).SetString(p.Get("stopPrice"))
+		if !ok || price.Sign() <= 0 {
+			return nil, &common.APIError{Code: -1013, Message: "Invalid stopPrice."}
+		}
+	case bitnut.OrderTypeMarket:
+		t, ok := s.tickers[sym.Name]
+		if !ok {
+			return nil, &common.APIError{Code: -1013, Message: "No market price."}
+		}
+		price = mustRat(t.LastPrice)
+	default:
+		return nil, &common.APIError{Code: -1116, Message: "Invalid orderType."}
+	}
+	qty, ok := new(big.Rat).SetString(p.Get("quantity"))
+	if orderType == bitnut.OrderTypeMarket && p.Get("quantity") == "" && p.Get("quoteOrderQty") != "" {
+		quoteQty, valid := new(big.Rat).SetString(p.Get("quoteOrderQty"))
+		if !valid || quoteQty.Sign() <= 0 || price.Sign() <= 0 {
+			return nil, &common.APIError{Code: -1013, Message: "Invalid quoteOrderQty."}
+		}
+		qty, ok = floorToStep(new(big.Rat).Quo(quoteQty, price), sym.StepSize), true
+	}
+	if !ok || qty.Sign() <= 0 {
+		return nil, &common.APIError{Code: -1013, Message: "Invalid quantity."}
+	}
+	if respType == "" {
+		respType = bitnut.NewOrderRespTypeACK
+		if orderType == bitnut.OrderTypeLimit || orderType == bitnut.OrderTypeMarket {
+			respType = bitnut.NewOrderRespTypeFULL
+		}
+	}
+
+	// freeze the funds the order may spend
+	frozenCoin, frozen := sym.BaseAsset, qty
+	if side == bitnut.SideTypeBuy {
+		frozenCoin, frozen = sym.QuoteAsset, new(big.Rat).Mul(qty, price)
+	}
+	s.nextID++
+	now := bitnut.FormatTimestamp(s.Now())
+	o := &bitnut.Order{
+		Symbol:           sym.Name,
+		OrderID:          strconv.FormatInt(s.nextID, 10),
+		ClientOrderID:    clientOrderID,
+		Price:            formatRat(price),
+		OrigQuantity:     formatRat(qty),
+		ExecutedQuantity: "0",
+		Status:           bitnut.OrderStatusTypeNew,
+		Side:             side,
+		Time:             now,
+		UpdateTime:       now,
+	}
+	w := s.wallet(frozenCoin)
+	if w.free.Cmp(frozen) < 0 {
+		// the exchange reports the refused order on the user data stream
+		o.Status = bitnut.OrderStatusTypeRejected
+		s.pushOrder(o, bitnut.ExecutionTypeRejected, nil, "INSUFFICIENT_BALANCE")
+		return nil, &common.APIError{Code: common.CodeInsufficientBalance, Message: "Account has insufficient balance for requested action."}
+	}
+	w.free.Sub(w.free, frozen)
+	w.freeze.Add(w.freeze, frozen)
+	s.orders = append(s.orders, o)
+	s.pushOrder(o, bitnut.ExecutionTypeNew, nil, "")
+	s.pushBalance(frozenCoin, new(big.Rat).Neg(frozen))
+	var fills []*bitnut.AccountTrade
+	switch {
+	case orderType == bitnut.OrderTypeMarket:
+		trade, err := s.fill(o, qty, false)
+		if err != nil {
+			return nil, &common.APIError{Code: -1013, Message: err.Error()}
+		}
+		fills = append(fills, trade)
+	case timeInForce == bitnut.TimeInForceTypeIOC || timeInForce == bitnut.TimeInForceTypeFOK:
+		s.release(o, bitnut.OrderStatusTypeExpired, bitnut.ExecutionTypeExpired)
+	}
+
+	res := map[string]interface{}{
+		"symbol":        o.Symbol,
+		"orderId":       o.OrderID,
+		"clientOrderId": o.ClientOrderID,
+		"transactTime":  o.UpdateTime,
+	}
+	if respType == bitnut.NewOrderRespTypeACK {
+		return res, nil
+	}
+	res["price"] = o.Price
+	res["origQty"] = o.OrigQuantity
+	res["executedQty"] = o.ExecutedQuantity
+	res["status"] = o.Status
+	res["timeInForce"] = timeInForce
+	res["type"] = orderType
+	res["side"] = o.Side
+	if respType == bitnut.NewOrderRespTypeFULL {
+		resFills := make([]map[string]interface{}, 0, len(fills))
+		for _, f := range fills {
+			resFills = append(resFills, map[string]interface{}{
+				"price":           f.Price,
+				"qty":             f.Quantity,
+				"commission":      f.Commission,
+				"commissionAsset": f.CommissionAsset,
+				"tradeId":         f.ID,
+			})
+		}
+		res["fills"] = resFills
+	}
+	return res, nil
+}
+
+func (s *Server) handleCancelOrder(p url.Values) (interface{}, *common.APIError) {
+	o := s.findOrder(p.Get("orderId"), p.Get("origClientOrderId"))
+	if o == nil || o.Symbol != p.Get("symbol") {
+		return nil, unknownOrder()
+	}
+	if !isOpen(o) {
+		return nil, unknownOrder()
+	}
+	s.cancel(o)
+	return []interface{}{o.OrderID}, nil
+}
+
+func (s *Server) handleCancelOpenOrders(p url.Values) (interface{}, *common.APIError) {
+	symbol := p.Get("symbol")
+	if _, ok := s.symbols[symbol]; !ok {
+		return nil, invalidSymbol()
+	}
+	res := make([]interface{}, 0)
+	for _, o := range s.orders {
+		if o.Symbol == symbol && isOpen(o) {
+			s.cancel(o)
+			res = append(res, o.OrderID)
+		}
+	}
+	return res, nil
+}
+
+// cancel release the funds still frozen by an open order
+func (s *Server) cancel(o *bitnut.Order) {
+	s.release(o, bitnut.OrderStatusTypeCanceled, bitnut.ExecutionTypeCanceled)
+}
+
+// release close an open order with status and unfreeze its remaining funds
+func (s *Server) release(o *bitnut.Order, status bitnut.OrderStatusType, execution bitnut.ExecutionType) {
+	sym := s.symbols[o.Symbol]
+	remaining := new(big.Rat).Sub(mustRat(o.OrigQuantity), mustRat(o.ExecutedQuantity))
+	coin, frozen := sym.BaseAsset, remaining
+	if o.Side == bitnut.SideTypeBuy {
+		coin, frozen = sym.QuoteAsset, new(big.Rat).Mul(remaining, mustRat(o.Price))
+	}
+	w := s.wallet(coin)
+	w.freeze.Sub(w.freeze, frozen)
+	w.free.Add(w.free, frozen)
+	o.Status = status
+	o.UpdateTime = bitnut.FormatTimestamp(s.Now())
+	s.pushOrder(o, execution, nil, "")
+	s.pushBalance(coin, frozen)
+}
+
+func (s *Server) handleListOrders(p url.Values) (interface{}, *common.APIError) {
+	symbol := p.Get("symbol")
+	if _, ok := s.symbols[symbol]; !ok {
+		return nil, invalidSymbol()
+	}
+	orderID, hasOrderID := int64Param(p, "orderId")
+	startTime, hasStart := int64Param(p, "startTime")
+	endTime, hasEnd := int64Param(p, "endTime")
+	limit, err := strconv.Atoi(p.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 500
+	}
+	res := make([]bitnut.Order, 0)
+	for _, o := range s.orders {
+		id, _ := strconv.ParseInt(o.OrderID, 10, 64)
+		if o.Symbol != symbol || (hasOrderID && id < orderID) ||
+			(hasStart && o.Time < startTime) || (hasEnd && o.Time > endTime) {
+			continue
+		}
+		if len(res) == limit {
+			break
+		}
+		res = append(res, *o)
+	}
+	return res, nil
+}
+
+func (s *Server) handleGetOrder(p url.Values) (interface{}, *common.APIError) {
+	o := s.findOrder(p.Get("orderId"), p.Get("origClientOrderId"))
+	if o == nil || o.Symbol != p.Get("symbol") {
+		return nil, unknownOrder()
+	}
+	return o, nil
+}
+
+func (s *Server) handleBalance(p url.Values) (interface{}, *common.APIError) {
+	coin := p.Get("coin")
+	if coin == "" {
+		return nil, &common.APIError{Code: -1102, Message: "Mandatory parameter 'coin' was not sent"}
+	}
+	return s.balance(coin), nil
+}
+
+func (s *Server) findOrder(orderID, clientOrderID string) *bitnut.Order {
+	for _, o := range s.orders {
+		if (orderID != "" && o.OrderID == orderID) || (clientOrderID != "" && o.ClientOrderID == clientOrderID) {
+			return o
+		}
+	}
+	return nil
+}
+
+type envelope struct {
+	Code int64       `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeFault(w http.ResponseWriter, f *Fault) {
+	if f.Drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+	for k, vs := range f.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	status := f.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, envelope{Code: f.Code, Msg: f.Msg})
+}
+
+func klineRow(k *bitnut.Kline) []interface{} {
+	return []interface{}{
+		k.OpenTime, k.Open, k.High, k.Low, k.Close, k.Volume, k.CloseTime,
+		orZero(k.QuoteAssetVolume), k.TradeNum, orZero(k.TakerBuyBaseAssetVolume), orZero(k.TakerBuyQuoteAssetVolume),
+	}
+}
+
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+func invalidSymbol() *common.APIError {
+	return &common.APIError{Code: common.CodeInvalidSymbol, Message: "Invalid symbol."}
+}
+
+func unknownOrder() *common.APIError {
+	return &common.APIError{Code: common.CodeUnknownOrder, Message: "Order does not exist."}
+}
+
+// limitParam return the limit parameter, 500 when missing
+func limitParam(p url.Values) int {
+	limit, err := strconv.Atoi(p.Get("limit"))
+	if err != nil || limit <= 0 {
+		return 500
+	}
+	return limit
+}
+
+func int64Param(p url.Values, key string) (int64, bool) {
+	v, err := strconv.ParseInt(p.Get(key), 10, 64)
+	return v, err == nil
+}
+
+func mustRat(s string) *big.Rat {
+	if s == "" {
+		return new(big.Rat)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic(fmt.Sprintf("bitnuttest: invalid number %q", s))
+	}
+	return r
+}
+
+// floorToStep round r down to a multiple of step, r is returned as is
+// without step
+func floorToStep(r *big.Rat, step string) *big.Rat {
+	st := mustRat(step)
+	if st.Sign() <= 0 {
+		return r
+	}
+	n := new(big.Rat).Quo(r, st)
+	steps := new(big.Int).Quo(n.Num(), n.Denom())
+	return new(big.Rat).Mul(new(big.Rat).SetInt(steps), st)
+}
+
+// formatRat format r without trailing zeros
+func formatRat(r *big.Rat) string {
+	s := r.FloatString(18)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
@@ -0,0 +1,209 @@
+package bitnuttest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hardyzp/bitnut"
+	"github.com/hardyzp/bitnut/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerOrderLifecycle(t *testing.T) {
+	assert := assert.New(t)
+	srv := NewServer()
+	defer srv.Close()
+	c := srv.Client()
+	ctx := context.Background()
+
+	_, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+		Type(bitnut.OrderTypeLimit).Quantity("0.5").Price("20000").Do(ctx)
+	assert.True(errors.Is(err, common.ErrInsufficientBalance))
+
+	srv.SetBalance("USDT", "15000", "0")
+	res, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+		Type(bitnut.OrderTypeLimit).Quantity("0.5").Price("20000").NewClientOrderID("my-order").Do(ctx)
+	assert.NoError(err)
+	assert.Equal("my-order", res.ClientOrderID)
+	assert.Equal(bitnut.OrderStatusTypeNew, res.Status)
+	assert.Empty(res.Fills)
+
+	// the client order id is taken, whatever the balance left
+	_, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+		Type(bitnut.OrderTypeLimit).Quantity("0.0001").Price("20000").NewClientOrderID("my-order").Do(ctx)
+	assert.True(errors.Is(err, common.ErrDuplicateOrder))
+	assert.False(errors.Is(err, common.ErrInsufficientBalance))
+
+	balance, err := c.NewGetBalanceService().SetCoin("USDT").Do(ctx)
+	assert.NoError(err)
+	assert.Equal(bitnut.Balance{Coin: "USDT", Free: "5000", Freeze: "10000"}, *balance)
+
+	order, err := c.NewGetOrderService().Symbol("BTCUSDT").OrigClientOrderID("my-order").Do(ctx)
+	assert.NoError(err)
+	assert.Equal(res.OrderID, order.OrderID)
+	assert.Equal(bitnut.OrderStatusTypeNew, order.Status)
+
+	assert.NoError(srv.FillOrder(order.OrderID, "0.2"))
+	assert.Equal("0.2", srv.Balance("BTC").Free)
+
+	_, err = c.NewCancelOrderService().Symbol("BTCUSDT").OrderID(order.OrderID).Do(ctx)
+	assert.NoError(err)
+	assert.Equal(bitnut.Balance{Coin: "USDT", Free: "11000", Freeze: "0"}, srv.Balance("USDT"))
+
+	orders, err := c.NewListOrdersService().Symbol("BTCUSDT").Do(ctx)
+	assert.NoError(err)
+	assert.Len(orders.Data, 1)
+	assert.Equal(bitnut.OrderStatusTypeCanceled, orders.Data[0].Status)
+	assert.Equal("0.2", orders.Data[0].ExecutedQuantity)
+
+	_, err = c.NewGetOrderService().Symbol("BTCUSDT").OrderID("404").Do(ctx)
+	assert.True(errors.Is(err, common.ErrUnknownOrder))
+}
+
+func TestServerRejectsBadSignature(t *testing.T) {
+	assert := assert.New(t)
+	srv := NewServer()
+	defer srv.Close()
+	c := srv.Client()
+	c.SecretKey = "wrong"
+	_, err := c.NewGetBalanceService().SetCoin("BTC").Do(context.Background())
+	assert.True(errors.Is(err, common.ErrInvalidSignature))
+
+	c = srv.Client()
+	c.APIKey = "wrong"
+	_, err = c.NewGetBalanceService().SetCoin("BTC").Do(context.Background())
+	assert.True(common.IsAPIError(err))
+}
+
+func TestServerFaultsAndLatency(t *testing.T) {
+	assert := assert.New(t)
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetBalance("USDT", "100", "0")
+	c := srv.Client()
+
+	// a dropped submission is looked up and sent again exactly once
+	srv.Fail("/v1/trade/order", Fault{Drop: true})
+	_, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+		Type(bitnut.OrderTypeLimit).Quantity("1").Price("10").Do(context.Background())
+	assert.NoError(err)
+	assert.Len(srv.Orders(), 1)
+	assert.Equal(2, srv.Requests("/v1/trade/order"))
+	assert.Equal(1, srv.Requests("/v1/spot/user/orderInfo"))
+
+	// the order is stored but answered with a 5xx, and the lookup misses
+	// it: the resubmission is a duplicate and resolves to the stored order
+	srv.Fail("/v1/trade/order", Fault{StatusCode: http.StatusBadGateway, Code: 502, Msg: "bad gateway", Processed: true})
+	srv.Fail("/v1/spot/user/orderInfo", Fault{Code: common.CodeUnknownOrder, Msg: "Order does not exist."})
+	res, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+		Type(bitnut.OrderTypeLimit).Quantity("1").Price("10").NewClientOrderID("late").Do(context.Background())
+	assert.NoError(err)
+	assert.Equal("late", res.ClientOrderID)
+	assert.Equal(bitnut.OrderStatusTypeNew, res.Status)
+	assert.Len(srv.Orders(), 2)
+	assert.Equal(4, srv.Requests("/v1/trade/order"))
+	assert.Equal(3, srv.Requests("/v1/spot/user/orderInfo"))
+	assert.Equal("80", srv.Balance("USDT").Free)
+
+	srv.Fail("/v1/tick/depth", Fault{Code: common.CodeRateLimited, Msg: "Too many requests"})
+	_, err = c.NewDepthService().Symbol("BTCUSDT").Do(context.Background(), bitnut.WithRetryPolicy(nil))
+	assert.True(errors.Is(err, common.ErrRateLimited))
+
+	srv.SetLatency("/v1/time", 200*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.NewServerTimeService().Do(ctx)
+	assert.Error(err)
+}
+
+func TestServerRejectsSkewedTimestamp(t *testing.T) {
+	assert := assert.New(t)
+	srv := NewServer()
+	defer srv.Close()
+	srv.Now = func() time.Time { return time.Now().Add(time.Minute) }
+	c := srv.Client()
+
+	// the client resyncs its clock and retries
+	_, err := c.NewGetBalanceService().SetCoin("BTC").Do(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, srv.Requests("/v1/time"))
+	assert.Equal(2, srv.Requests("/v1/asset/balance"))
+}
+
+func TestServerExchangeInfo(t *testing.T) {
+	assert := assert.New(t)
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddSymbol(Symbol{Name: "ETHUSDT", BaseAsset: "ETH", QuoteAsset: "USDT", TickSize: "0.1"})
+	c := srv.Client()
+
+	info, err := c.NewExchangeInfoService().Do(context.Background())
+	assert.NoError(err)
+	assert.Len(info.Symbols, 2)
+	btc := info.Symbol("BTCUSDT")
+	assert.Equal("0.000001", btc.StepSize())
+	assert.Equal("5", btc.MinNotional())
+	eth := info.Symbol("ETHUSDT")
+	assert.Equal("0.1", eth.TickSize())
+	assert.Nil(eth.Filters.LotSize)
+
+	info, err = c.NewExchangeInfoService().Symbols("ETHUSDT").Do(context.Background())
+	assert.NoError(err)
+	assert.Len(info.Symbols, 1)
+
+	_, err = c.NewExchangeInfoService().Symbol("XRPUSDT").Do(context.Background())
+	assert.True(errors.Is(err, common.ErrInvalidSymbol))
+}
+
+func TestServerTrades(t *testing.T) {
+	assert := assert.New(t)
+	srv := NewServer()
+	defer srv.Close()
+	c := srv.Client()
+	ctx := context.Background()
+
+	srv.AddTrades("BTCUSDT",
+		bitnut.Trade{Price: "40000", Quantity: "1", QuoteQuantity: "40000", Time: 1650000000000},
+		bitnut.Trade{Price: "40001", Quantity: "2", QuoteQuantity: "80002", Time: 1650000001000, IsBuyerMaker: true})
+	srv.SetBalance("BTC", "1", "0")
+	_, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeSell).
+		Type(bitnut.OrderTypeLimit).Quantity("0.5").Price("40002").Do(ctx)
+	assert.NoError(err)
+	order := srv.Orders()[0]
+	assert.NoError(srv.FillOrder(order.OrderID, "0.5"))
+
+	trades, err := c.NewRecentTradesService().Symbol("BTCUSDT").Limit(2).Do(ctx)
+	assert.NoError(err)
+	assert.Len(trades, 2)
+	assert.Equal(int64(2), trades[0].ID)
+	fill := *trades[1]
+	fill.Time = 0
+	assert.Equal(bitnut.Trade{ID: 3, Price: "40002", Quantity: "0.5", QuoteQuantity: "20001"}, fill)
+
+	trades, err = c.NewHistoricalTradesService().Symbol("BTCUSDT").FromID(1).Limit(2).Do(ctx)
+	assert.NoError(err)
+	assert.Equal([]int64{1, 2}, []int64{trades[0].ID, trades[1].ID})
+
+	aggTrades, err := c.NewAggTradesService().Symbol("BTCUSDT").FromID(2).Do(ctx)
+	assert.NoError(err)
+	assert.Len(aggTrades, 2)
+	assert.True(aggTrades[0].IsBuyerMaker)
+
+	fills, err := c.NewListTradesService().Symbol("BTCUSDT").Do(ctx)
+	assert.NoError(err)
+	assert.Len(fills, 1)
+	assert.Equal(order.OrderID, fills[0].OrderID)
+	assert.Equal("USDT", fills[0].CommissionAsset)
+	assert.False(fills[0].IsBuyer)
+	assert.True(fills[0].IsMaker)
+
+	fills, err = c.NewListTradesService().Symbol("BTCUSDT").OrderID(order.OrderID).Do(ctx)
+	assert.NoError(err)
+	assert.Len(fills, 1)
+	fills, err = c.NewListTradesService().Symbol("BTCUSDT").OrderID("404").Do(ctx)
+	assert.NoError(err)
+	assert.Empty(fills)
+}
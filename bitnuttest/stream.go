@@ -0,0 +1,227 @@
+package bitnuttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/hardyzp/bitnut"
+)
+
+// streamPath is the path of the market data stream of the server
+const streamPath = "/ws"
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// streamConn is a websocket client of the server, listenKey is set on
+// user data stream connections
+type streamConn struct {
+	conn      *websocket.Conn
+	mu        sync.Mutex
+	subs      map[string]bool
+	listenKey string
+}
+
+func (c *streamConn) write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+type streamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	ID     int64    `json:"id"`
+}
+
+// StreamURL return the websocket URL of the market data stream
+func (s *Server) StreamURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + streamPath
+}
+
+// Close shut down the websocket connections and the server
+func (s *Server) Close() {
+	s.DropStreams()
+	s.Server.Close()
+}
+
+func (s *Server) serveStream(w http.ResponseWriter, req *http.Request, listenKey string) {
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	c := &streamConn{conn: conn, subs: map[string]bool{}, listenKey: listenKey}
+	s.streamMu.Lock()
+	s.streams[c] = true
+	s.streamConnects++
+	s.streamMu.Unlock()
+	defer func() {
+		s.streamMu.Lock()
+		delete(s.streams, c)
+		s.streamMu.Unlock()
+		conn.Close()
+	}()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		r := new(streamRequest)
+		if err := json.Unmarshal(data, r); err != nil {
+			_ = c.write([]byte(`{"error":{"code":-1100,"msg":"Invalid JSON"},"id":null}`))
+			continue
+		}
+		s.streamMu.Lock()
+		switch r.Method {
+		case "SUBSCRIBE":
+			for _, name := range r.Params {
+				c.subs[name] = true
+			}
+		case "UNSUBSCRIBE":
+			for _, name := range r.Params {
+				delete(c.subs, name)
+			}
+		}
+		s.streamMu.Unlock()
+		var res interface{} = map[string]interface{}{"result": nil, "id": r.ID}
+		if r.Method != "SUBSCRIBE" && r.Method != "UNSUBSCRIBE" {
+			res = map[string]interface{}{
+				"error": map[string]interface{}{"code": -1100, "msg": "Unknown method " + r.Method},
+				"id":    r.ID,
+			}
+		}
+		out, _ := json.Marshal(res)
+		_ = c.write(out)
+	}
+}
+
+// Publish send event as JSON to the connections subscribed to stream, such
+// as btcusdt@trade, and return how many received it
+func (s *Server) Publish(stream string, event interface{}) int {
+	data, err := json.Marshal(event)
+	if err != nil {
+		panic(err)
+	}
+	sent := 0
+	for _, c := range s.streamConns() {
+		s.streamMu.Lock()
+		subscribed := c.subs[stream]
+		s.streamMu.Unlock()
+		if subscribed && c.write(data) == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// PublishRaw send data as is to every connection and return how many
+// received it
+func (s *Server) PublishRaw(data []byte) int {
+	sent := 0
+	for _, c := range s.streamConns() {
+		if c.write(data) == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// StreamSubscribers return how many connections are subscribed to stream
+func (s *Server) StreamSubscribers(stream string) int {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	n := 0
+	for c := range s.streams {
+		if c.subs[stream] {
+			n++
+		}
+	}
+	return n
+}
+
+// StreamConnections return how many websocket connections were accepted
+func (s *Server) StreamConnections() int {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	return s.streamConnects
+}
+
+// DropStreams close every websocket connection, like a server restart
+func (s *Server) DropStreams() {
+	for _, c := range s.streamConns() {
+		c.conn.Close()
+	}
+}
+
+func (s *Server) streamConns() []*streamConn {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	res := make([]*streamConn, 0, len(s.streams))
+	for c := range s.streams {
+		res = append(res, c)
+	}
+	return res
+}
+
+// UpdateDepth apply level changes to the order book of symbol, a zero
+// quantity removes a level. The book update id is increased and the diff is
+// published on the depth stream of the symbol.
+func (s *Server) UpdateDepth(symbol string, bids, asks [][2]string) *bitnut.WsDepthEvent {
+	s.mu.Lock()
+	depth, ok := s.depths[symbol]
+	if !ok {
+		depth = &bitnut.Depth{Bids: [][2]string{}, Asks: [][2]string{}}
+		s.depths[symbol] = depth
+	}
+	d := *depth
+	d.Bids = applyLevels(d.Bids, bids, true)
+	d.Asks = applyLevels(d.Asks, asks, false)
+	d.LastUpdateID++
+	s.depths[symbol] = &d
+	e := &bitnut.WsDepthEvent{
+		Event:         bitnut.StreamEventDepthUpdate,
+		Time:          bitnut.FormatTimestamp(s.Now()),
+		Symbol:        symbol,
+		FirstUpdateID: d.LastUpdateID,
+		LastUpdateID:  d.LastUpdateID,
+		Bids:          bids,
+		Asks:          asks,
+	}
+	s.mu.Unlock()
+	s.Publish(strings.ToLower(symbol)+"@depth", e)
+	return e
+}
+
+// applyLevels return a copy of levels with the updates applied, sorted by
+// price
+func applyLevels(levels, updates [][2]string, descending bool) [][2]string {
+	byPrice := map[string][2]string{}
+	for _, l := range levels {
+		byPrice[mustRat(l[0]).RatString()] = l
+	}
+	for _, u := range updates {
+		key := mustRat(u[0]).RatString()
+		if mustRat(u[1]).Sign() == 0 {
+			delete(byPrice, key)
+			continue
+		}
+		byPrice[key] = u
+	}
+	res := make([][2]string, 0, len(byPrice))
+	for _, l := range byPrice {
+		res = append(res, l)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		c := mustRat(res[i][0]).Cmp(mustRat(res[j][0]))
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+	return res
+}
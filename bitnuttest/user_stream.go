@@ -0,0 +1,180 @@
+package bitnuttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hardyzp/bitnut"
+)
+
+// Paths of the listen key endpoint and of the user data stream, which is
+// served at userStreamPath/<listenKey>
+const (
+	listenKeyPath  = "/v1/userDataStream"
+	userStreamPath = "/user"
+)
+
+// UserStreamURL return the websocket URL of the user data stream, without
+// the listen key
+func (s *Server) UserStreamURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + userStreamPath
+}
+
+// ListenKeys return the listen keys alive
+func (s *Server) ListenKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]string, 0, len(s.listenKeys))
+	for key := range s.listenKeys {
+		res = append(res, key)
+	}
+	return res
+}
+
+// serveListenKey create, keep alive and close listen keys, requests only
+// need the API key
+func (s *Server) serveListenKey(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("BU-ACCESS-KEY") != s.APIKey {
+		writeJSON(w, http.StatusOK, envelope{Code: -2015, Msg: "Invalid API-key"})
+		return
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, envelope{Code: 400, Msg: err.Error()})
+		return
+	}
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, envelope{Code: 400, Msg: err.Error()})
+		return
+	}
+	key := params.Get("listenKey")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch req.Method {
+	case http.MethodPost:
+		// like the exchange, return the key alive if any
+		for k := range s.listenKeys {
+			key = k
+		}
+		if key == "" {
+			s.nextListenKey++
+			key = fmt.Sprintf("listen-key-%d", s.nextListenKey)
+			s.listenKeys[key] = true
+		}
+		writeJSON(w, http.StatusOK, envelope{Code: 0, Msg: "success", Data: map[string]string{"listenKey": key}})
+	case http.MethodPut, http.MethodDelete:
+		if !s.listenKeys[key] {
+			writeJSON(w, http.StatusOK, envelope{Code: -1125, Msg: "This listenKey does not exist."})
+			return
+		}
+		if req.Method == http.MethodDelete {
+			delete(s.listenKeys, key)
+			for _, c := range s.streamConns() {
+				if c.listenKey == key {
+					c.conn.Close()
+				}
+			}
+		}
+		writeJSON(w, http.StatusOK, envelope{Code: 0, Msg: "success", Data: map[string]interface{}{}})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, envelope{Code: 405, Msg: "method not allowed"})
+	}
+}
+
+// serveUserStream accept a user data stream connection with a live listen
+// key
+func (s *Server) serveUserStream(w http.ResponseWriter, req *http.Request) {
+	key := strings.TrimPrefix(req.URL.Path, userStreamPath+"/")
+	s.mu.Lock()
+	ok := s.listenKeys[key]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, envelope{Code: -1125, Msg: "This listenKey does not exist."})
+		return
+	}
+	s.serveStream(w, req, key)
+}
+
+// pushOrder queue an execution report of o, trade is the fill of a trade
+// execution
+func (s *Server) pushOrder(o *bitnut.Order, execution bitnut.ExecutionType, trade *bitnut.AccountTrade, reason string) {
+	now := bitnut.FormatTimestamp(s.Now())
+	e := &bitnut.WsOrderUpdateEvent{
+		Event:            bitnut.UserDataEventTypeExecutionReport,
+		Time:             now,
+		Symbol:           o.Symbol,
+		ClientOrderID:    o.ClientOrderID,
+		Side:             o.Side,
+		Quantity:         o.OrigQuantity,
+		Price:            o.Price,
+		ExecutionType:    execution,
+		Status:           o.Status,
+		RejectReason:     reason,
+		OrderID:          o.OrderID,
+		LastFilledQty:    "0",
+		ExecutedQuantity: o.ExecutedQuantity,
+		LastFilledPrice:  "0",
+		Commission:       "0",
+		TransactionTime:  now,
+		TradeID:          -1,
+		CreateTime:       o.Time,
+		QuoteVolume:      formatRat(new(big.Rat).Mul(mustRat(o.ExecutedQuantity), mustRat(o.Price))),
+		LastQuoteQty:     "0",
+	}
+	if reason == "" {
+		e.RejectReason = "NONE"
+	}
+	if trade != nil {
+		e.TradeID = trade.ID
+		e.LastFilledQty = trade.Quantity
+		e.LastFilledPrice = trade.Price
+		e.LastQuoteQty = trade.QuoteQuantity
+		e.Commission = trade.Commission
+		e.CommissionAsset = trade.CommissionAsset
+		e.IsMaker = trade.IsMaker
+		e.TransactionTime = trade.Time
+	}
+	s.userEvents = append(s.userEvents, e)
+}
+
+// pushBalance queue a balance update of coin, delta is the change of the
+// free amount
+func (s *Server) pushBalance(coin string, delta *big.Rat) {
+	b := s.balance(coin)
+	s.userEvents = append(s.userEvents, &bitnut.WsBalanceUpdateEvent{
+		Event:  bitnut.UserDataEventTypeBalanceUpdate,
+		Time:   bitnut.FormatTimestamp(s.Now()),
+		Coin:   coin,
+		Delta:  formatRat(delta),
+		Free:   b.Free,
+		Freeze: b.Freeze,
+	})
+}
+
+// flushUserEvents send the queued user events to the user data streams,
+// in order. It is called without s.mu held.
+func (s *Server) flushUserEvents() {
+	s.pushMu.Lock()
+	defer s.pushMu.Unlock()
+	s.mu.Lock()
+	events := s.userEvents
+	s.userEvents = nil
+	s.mu.Unlock()
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			panic(err)
+		}
+		for _, c := range s.streamConns() {
+			if c.listenKey != "" {
+				_ = c.write(data)
+			}
+		}
+	}
+}
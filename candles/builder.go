@@ -0,0 +1,291 @@
+package candles
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hardyzp/bitnut"
+	"github.com/hardyzp/bitnut/common"
+)
+
+// ErrLateTrade is returned by Builder when a trade belongs to a candle that
+// was already closed
+var ErrLateTrade = errors.New("candles: trade arrived after its candle closed")
+
+// Update define a candle emitted by a Builder. Kline is a copy that the
+// builder never modifies again.
+type Update struct {
+	Interval bitnut.KlineInterval
+	Kline    *bitnut.Kline
+	Closed   bool
+}
+
+// Builder build klines from trades for one or more intervals, in the same
+// shape as KlinesService returns them. A candle stays open until the
+// latest trade time, or the time given to Advance, passes its close time
+// plus the grace window, so trades arriving late within the window are still
+// counted. Intervals without any trade produce no candle, see Fill.
+type Builder struct {
+	mu        sync.Mutex
+	series    []*series
+	grace     time.Duration
+	watermark int64
+}
+
+type series struct {
+	interval bitnut.KlineInterval
+	bars     map[int64]*bar
+	// closedUntil is the open time of the first candle still open
+	closedUntil int64
+}
+
+// NewBuilder init a builder for intervals
+func NewBuilder(intervals ...bitnut.KlineInterval) (*Builder, error) {
+	if len(intervals) == 0 {
+		return nil, errors.New("candles: no interval")
+	}
+	b := &Builder{}
+	for _, interval := range intervals {
+		err := interval.Validate()
+		if err != nil {
+			return nil, err
+		}
+		b.series = append(b.series, &series{interval: interval, bars: map[int64]*bar{}})
+	}
+	return b, nil
+}
+
+// Grace set how long after its close time a candle still accepts trades
+func (b *Builder) Grace(grace time.Duration) *Builder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.grace = grace
+	return b
+}
+
+// AddTrade add a trade, the updates are the candles closed by its time
+// followed by the in-progress candles it changed
+func (b *Builder) AddTrade(t *bitnut.Trade) ([]Update, error) {
+	return b.add(&tick{
+		id:         t.ID,
+		time:       t.Time,
+		price:      t.Price,
+		quantity:   t.Quantity,
+		quote:      t.QuoteQuantity,
+		buyerMaker: t.IsBuyerMaker,
+		count:      1,
+	})
+}
+
+// AddAggTrade add an aggregate trade, counting every trade it aggregates
+func (b *Builder) AddAggTrade(t *bitnut.AggTrade) ([]Update, error) {
+	count := t.LastTradeID - t.FirstTradeID + 1
+	if count < 1 {
+		count = 1
+	}
+	return b.add(&tick{
+		id:         t.AggTradeID,
+		time:       t.Timestamp,
+		price:      t.Price,
+		quantity:   t.Quantity,
+		buyerMaker: t.IsBuyerMaker,
+		count:      count,
+	})
+}
+
+// Advance close the candles whose grace window ended before now, for when
+// no trade comes to move the builder forward
+func (b *Builder) Advance(now time.Time) []Update {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.advance(bitnut.FormatTimestamp(now))
+}
+
+// Flush close every open candle, at the end of a replay
+func (b *Builder) Flush() []Update {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	updates := make([]Update, 0)
+	for _, s := range b.series {
+		for _, bar := range s.sortedBars() {
+			updates = append(updates, Update{Interval: s.interval, Kline: bar.kline(), Closed: true})
+			if bar.next > s.closedUntil {
+				s.closedUntil = bar.next
+			}
+		}
+		s.bars = map[int64]*bar{}
+	}
+	return updates
+}
+
+// Replay build the closed klines of interval from historical trades
+func Replay(trades []*bitnut.Trade, interval bitnut.KlineInterval) ([]*bitnut.Kline, error) {
+	b, err := NewBuilder(interval)
+	if err != nil {
+		return nil, err
+	}
+	b.grace = time.Duration(1<<63 - 1)
+	for _, t := range trades {
+		_, err = b.AddTrade(t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	res := make([]*bitnut.Kline, 0)
+	for _, u := range b.Flush() {
+		res = append(res, u.Kline)
+	}
+	return res, nil
+}
+
+func (b *Builder) add(t *tick) ([]Update, error) {
+	price, err := common.ParseDecimal(t.price)
+	if err != nil {
+		return nil, fmt.Errorf("candles: invalid trade price: %w", err)
+	}
+	quantity, err := common.ParseDecimal(t.quantity)
+	if err != nil {
+		return nil, fmt.Errorf("candles: invalid trade quantity: %w", err)
+	}
+	quote := price.Mul(quantity)
+	if t.quote != "" {
+		quote, err = common.ParseDecimal(t.quote)
+		if err != nil {
+			return nil, fmt.Errorf("candles: invalid trade quote quantity: %w", err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.series {
+		if t.time < s.closedUntil {
+			return nil, ErrLateTrade
+		}
+	}
+	updates := b.advance(t.time)
+	for _, s := range b.series {
+		start := s.interval.Start(time.Unix(0, t.time*int64(time.Millisecond)))
+		open := bitnut.FormatTimestamp(start)
+		cur, ok := s.bars[open]
+		if !ok {
+			cur = &bar{open: open, next: bitnut.FormatTimestamp(s.interval.Next(start))}
+			s.bars[open] = cur
+		}
+		cur.add(t, price, quantity, quote)
+		updates = append(updates, Update{Interval: s.interval, Kline: cur.kline()})
+	}
+	return updates, nil
+}
+
+// advance move the watermark to now and close the candles it passed
+func (b *Builder) advance(now int64) []Update {
+	updates := make([]Update, 0)
+	if now <= b.watermark {
+		return updates
+	}
+	b.watermark = now
+	grace := b.grace.Milliseconds()
+	if grace > now {
+		return updates
+	}
+	cutoff := now - grace
+	for _, s := range b.series {
+		for _, bar := range s.sortedBars() {
+			if bar.next > cutoff {
+				break
+			}
+			updates = append(updates, Update{Interval: s.interval, Kline: bar.kline(), Closed: true})
+			delete(s.bars, bar.open)
+		}
+		until := bitnut.FormatTimestamp(s.interval.Start(time.Unix(0, cutoff*int64(time.Millisecond))))
+		if until > s.closedUntil {
+			s.closedUntil = until
+		}
+	}
+	return updates
+}
+
+func (s *series) sortedBars() []*bar {
+	res := make([]*bar, 0, len(s.bars))
+	for _, bar := range s.bars {
+		res = append(res, bar)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].open < res[j].open })
+	return res
+}
+
+// tick is a trade or an aggregate trade
+type tick struct {
+	id         int64
+	time       int64
+	price      string
+	quantity   string
+	quote      string
+	buyerMaker bool
+	count      int64
+}
+
+type bar struct {
+	open, next int64
+	first      *tick
+	last       *tick
+	high, low  string
+	highValue  common.Decimal
+	lowValue   common.Decimal
+	volume     common.Decimal
+	quote      common.Decimal
+	takerBase  common.Decimal
+	takerQuote common.Decimal
+	trades     int64
+}
+
+func (b *bar) add(t *tick, price, quantity, quote common.Decimal) {
+	if b.first == nil || before(t, b.first) {
+		b.first = t
+	}
+	if b.last == nil || before(b.last, t) {
+		b.last = t
+	}
+	if b.high == "" || price.GreaterThan(b.highValue) {
+		b.high, b.highValue = t.price, price
+	}
+	if b.low == "" || price.LessThan(b.lowValue) {
+		b.low, b.lowValue = t.price, price
+	}
+	b.volume = b.volume.Add(quantity)
+	b.quote = b.quote.Add(quote)
+	// the taker is the buyer when the maker is the seller, a zero is still
+	// added to keep the precision of the volumes
+	if t.buyerMaker {
+		quantity, quote = quantity.Sub(quantity), quote.Sub(quote)
+	}
+	b.takerBase = b.takerBase.Add(quantity)
+	b.takerQuote = b.takerQuote.Add(quote)
+	b.trades += t.count
+}
+
+func before(a, b *tick) bool {
+	if a.time != b.time {
+		return a.time < b.time
+	}
+	return a.id < b.id
+}
+
+func (b *bar) kline() *bitnut.Kline {
+	return &bitnut.Kline{
+		OpenTime:                 b.open,
+		Open:                     b.first.price,
+		High:                     b.high,
+		Low:                      b.low,
+		Close:                    b.last.price,
+		Volume:                   b.volume.String(),
+		CloseTime:                b.next - 1,
+		QuoteAssetVolume:         b.quote.String(),
+		TradeNum:                 b.trades,
+		TakerBuyBaseAssetVolume:  b.takerBase.String(),
+		TakerBuyQuoteAssetVolume: b.takerQuote.String(),
+	}
+}
@@ -0,0 +1,119 @@
+package candles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hardyzp/bitnut"
+	"github.com/stretchr/testify/assert"
+)
+
+func trade(id int64, at time.Duration, price, quantity string, buyerMaker bool) *bitnut.Trade {
+	return &bitnut.Trade{
+		ID:           id,
+		Price:        price,
+		Quantity:     quantity,
+		Time:         bitnut.FormatTimestamp(start.Add(at)),
+		IsBuyerMaker: buyerMaker,
+	}
+}
+
+func TestReplay(t *testing.T) {
+	assert := assert.New(t)
+	trades := []*bitnut.Trade{
+		trade(1, 5*time.Second, "100.5", "0.2", false),
+		trade(3, 40*time.Second, "99", "0.15", true),
+		trade(2, 20*time.Second, "101", "1", false),
+		trade(4, 70*time.Second, "98", "2", true),
+		trade(5, 7*time.Minute, "103", "0.5", false),
+	}
+
+	res, err := Replay(trades, bitnut.KlineInterval1m)
+	assert.NoError(err)
+	assert.Equal([]int64{0, 1, 7}, openTimes(res))
+	assert.Equal(&bitnut.Kline{
+		OpenTime:                 bitnut.FormatTimestamp(start),
+		Open:                     "100.5",
+		High:                     "101",
+		Low:                      "99",
+		Close:                    "99",
+		Volume:                   "1.35",
+		CloseTime:                bitnut.FormatTimestamp(start.Add(time.Minute)) - 1,
+		QuoteAssetVolume:         "135.95",
+		TradeNum:                 3,
+		TakerBuyBaseAssetVolume:  "1.20",
+		TakerBuyQuoteAssetVolume: "121.10",
+	}, res[0])
+
+	resampled, err := Resample(res, bitnut.KlineInterval5m)
+	assert.NoError(err)
+	direct, err := Replay(trades, bitnut.KlineInterval5m)
+	assert.NoError(err)
+	assert.Equal(direct, resampled)
+
+	_, err = Replay([]*bitnut.Trade{trade(1, 0, "x", "1", false)}, bitnut.KlineInterval1m)
+	assert.Error(err)
+}
+
+func TestBuilderLive(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewBuilder(bitnut.KlineInterval1m, bitnut.KlineInterval5m)
+	assert.NoError(err)
+	b.Grace(2 * time.Second)
+
+	updates, err := b.AddTrade(trade(1, 10*time.Second, "10", "1", false))
+	assert.NoError(err)
+	assert.Len(updates, 2)
+	assert.False(updates[0].Closed)
+	assert.Equal(bitnut.KlineInterval1m, updates[0].Interval)
+	assert.Equal(bitnut.KlineInterval5m, updates[1].Interval)
+
+	updates, err = b.AddTrade(trade(2, 61*time.Second, "11", "1", false))
+	assert.NoError(err)
+	assert.Len(updates, 2, "the first minute is still within its grace window")
+
+	updates, err = b.AddTrade(trade(3, 59*time.Second, "12", "1", false))
+	assert.NoError(err, "late trade within the grace window")
+	assert.Equal("12", updates[0].Kline.Close)
+	assert.Equal(int64(2), updates[0].Kline.TradeNum)
+	assert.Equal("11", updates[1].Kline.Close, "the 5m candle closes with its last trade by time")
+
+	updates, err = b.AddAggTrade(&bitnut.AggTrade{
+		AggTradeID:   4,
+		Price:        "9",
+		Quantity:     "3",
+		FirstTradeID: 4,
+		LastTradeID:  6,
+		Timestamp:    bitnut.FormatTimestamp(start.Add(63 * time.Second)),
+		IsBuyerMaker: true,
+	})
+	assert.NoError(err)
+	assert.Len(updates, 3)
+	assert.True(updates[0].Closed)
+	assert.Equal(bitnut.FormatTimestamp(start), updates[0].Kline.OpenTime)
+	assert.Equal("12", updates[0].Kline.High)
+	assert.Equal(int64(4), updates[1].Kline.TradeNum)
+	assert.Equal("9", updates[1].Kline.Low)
+	assert.Equal("11", updates[1].Kline.TakerBuyQuoteAssetVolume)
+
+	_, err = b.AddTrade(trade(5, 30*time.Second, "10", "1", false))
+	assert.ErrorIs(err, ErrLateTrade)
+
+	assert.Empty(b.Advance(start.Add(2 * time.Minute)))
+	updates = b.Advance(start.Add(2*time.Minute + 2*time.Second))
+	assert.Len(updates, 1)
+	assert.True(updates[0].Closed)
+	assert.Equal(bitnut.KlineInterval1m, updates[0].Interval)
+
+	updates = b.Flush()
+	assert.Len(updates, 1)
+	assert.Equal(bitnut.KlineInterval5m, updates[0].Interval)
+	assert.Equal(int64(6), updates[0].Kline.TradeNum)
+	assert.Equal("10", updates[0].Kline.Open)
+	assert.Equal("9", updates[0].Kline.Close)
+
+	_, err = NewBuilder()
+	assert.Error(err)
+	_, err = NewBuilder(bitnut.KlineInterval("7m"))
+	assert.Error(err)
+}
@@ -0,0 +1,179 @@
+// Package candles builds klines from trades, and resamples, repairs and
+// merges kline series returned by bitnut.KlinesService.
+package candles
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hardyzp/bitnut"
+)
+
+// FillMode define how missing klines are filled
+type FillMode int
+
+// Fill modes
+const (
+	// FillFlat fill a missing kline with a flat candle at the previous
+	// close and zero volume
+	FillFlat FillMode = iota
+	// FillMarker fill a missing kline with a gap marker, a kline with empty
+	// prices and volumes, see IsGapMarker
+	FillMarker
+)
+
+func sorted(klines []*bitnut.Kline) []*bitnut.Kline {
+	res := make([]*bitnut.Kline, 0, len(klines))
+	for _, k := range klines {
+		if k != nil {
+			res = append(res, k)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].OpenTime < res[j].OpenTime })
+	return res
+}
+
+// Resample aggregate klines into klines of a larger interval. Buckets are
+// aligned to UTC boundaries by interval.Start. Open is the first open, Close
+// the last close, High and Low the extremes, volumes and trade counts are
+// summed exactly. Gap markers are skipped. Every input kline must fit in a
+// single bucket, so the target interval can't be shorter than the source.
+func Resample(klines []*bitnut.Kline, interval bitnut.KlineInterval) ([]*bitnut.Kline, error) {
+	err := interval.Validate()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*bitnut.Kline, 0)
+	var cur *bitnut.Kline
+	var next int64
+	for _, k := range sorted(klines) {
+		if IsGapMarker(k) {
+			continue
+		}
+		if cur == nil || k.OpenTime >= next {
+			start := interval.Start(k.OpenAt())
+			next = bitnut.FormatTimestamp(interval.Next(start))
+			cur = &bitnut.Kline{
+				OpenTime:                 bitnut.FormatTimestamp(start),
+				Open:                     k.Open,
+				High:                     k.High,
+				Low:                      k.Low,
+				Close:                    k.Close,
+				Volume:                   k.Volume,
+				CloseTime:                next - 1,
+				QuoteAssetVolume:         k.QuoteAssetVolume,
+				TradeNum:                 k.TradeNum,
+				TakerBuyBaseAssetVolume:  k.TakerBuyBaseAssetVolume,
+				TakerBuyQuoteAssetVolume: k.TakerBuyQuoteAssetVolume,
+			}
+			res = append(res, cur)
+		} else {
+			if compare(k.High, cur.High) > 0 {
+				cur.High = k.High
+			}
+			if compare(k.Low, cur.Low) < 0 {
+				cur.Low = k.Low
+			}
+			cur.Close = k.Close
+			cur.Volume = sum(cur.Volume, k.Volume)
+			cur.QuoteAssetVolume = sum(cur.QuoteAssetVolume, k.QuoteAssetVolume)
+			cur.TakerBuyBaseAssetVolume = sum(cur.TakerBuyBaseAssetVolume, k.TakerBuyBaseAssetVolume)
+			cur.TakerBuyQuoteAssetVolume = sum(cur.TakerBuyQuoteAssetVolume, k.TakerBuyQuoteAssetVolume)
+			cur.TradeNum += k.TradeNum
+		}
+		if k.CloseTime >= next {
+			return nil, fmt.Errorf("candles: kline opening at %d does not fit in a %s bucket", k.OpenTime, interval)
+		}
+	}
+	return res, nil
+}
+
+// FindGaps return the ranges of missing klines between the first and the
+// last kline of a series of the given interval
+func FindGaps(klines []*bitnut.Kline, interval bitnut.KlineInterval) []bitnut.KlineGap {
+	gaps := make([]bitnut.KlineGap, 0)
+	var expected int64
+	for _, k := range sorted(klines) {
+		if IsGapMarker(k) {
+			continue
+		}
+		if expected != 0 && k.OpenTime > expected {
+			gaps = append(gaps, bitnut.KlineGap{From: expected, To: k.OpenTime})
+		}
+		if next := bitnut.FormatTimestamp(interval.Next(k.OpenAt())); next > expected {
+			expected = next
+		}
+	}
+	return gaps
+}
+
+// Fill return the series with every missing kline between the first and the
+// last one filled according to mode
+func Fill(klines []*bitnut.Kline, interval bitnut.KlineInterval, mode FillMode) []*bitnut.Kline {
+	in := sorted(klines)
+	res := make([]*bitnut.Kline, 0, len(in))
+	var prev *bitnut.Kline
+	for _, k := range in {
+		if IsGapMarker(k) {
+			continue
+		}
+		if prev != nil {
+			open := interval.Next(prev.OpenAt())
+			for bitnut.FormatTimestamp(open) < k.OpenTime {
+				next := interval.Next(open)
+				res = append(res, filler(prev, bitnut.FormatTimestamp(open), bitnut.FormatTimestamp(next)-1, mode))
+				open = next
+			}
+		}
+		res = append(res, k)
+		prev = k
+	}
+	return res
+}
+
+func filler(prev *bitnut.Kline, openTime, closeTime int64, mode FillMode) *bitnut.Kline {
+	if mode == FillMarker {
+		return &bitnut.Kline{OpenTime: openTime, CloseTime: closeTime}
+	}
+	return &bitnut.Kline{
+		OpenTime:                 openTime,
+		Open:                     prev.Close,
+		High:                     prev.Close,
+		Low:                      prev.Close,
+		Close:                    prev.Close,
+		Volume:                   zero(prev.Volume),
+		CloseTime:                closeTime,
+		QuoteAssetVolume:         zero(prev.QuoteAssetVolume),
+		TakerBuyBaseAssetVolume:  zero(prev.TakerBuyBaseAssetVolume),
+		TakerBuyQuoteAssetVolume: zero(prev.TakerBuyQuoteAssetVolume),
+	}
+}
+
+// IsGapMarker report whether k is a gap marker made by Fill
+func IsGapMarker(k *bitnut.Kline) bool {
+	return k.Open == "" && k.Close == "" && k.High == "" && k.Low == ""
+}
+
+// Merge combine series from separate backfills into one series ordered by
+// open time. When several series have a kline with the same open time, the
+// one from the later series wins, unless it is a gap marker.
+func Merge(series ...[]*bitnut.Kline) []*bitnut.Kline {
+	byOpen := map[int64]*bitnut.Kline{}
+	for _, s := range series {
+		for _, k := range s {
+			if k == nil {
+				continue
+			}
+			if cur, ok := byOpen[k.OpenTime]; ok && IsGapMarker(k) && !IsGapMarker(cur) {
+				continue
+			}
+			byOpen[k.OpenTime] = k
+		}
+	}
+	res := make([]*bitnut.Kline, 0, len(byOpen))
+	for _, k := range byOpen {
+		res = append(res, k)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].OpenTime < res[j].OpenTime })
+	return res
+}
@@ -0,0 +1,145 @@
+package candles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hardyzp/bitnut"
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC)
+
+func minute(m int, open, high, low, close, volume string) *bitnut.Kline {
+	openTime := bitnut.FormatTimestamp(start.Add(time.Duration(m) * time.Minute))
+	return &bitnut.Kline{
+		OpenTime:                 openTime,
+		Open:                     open,
+		High:                     high,
+		Low:                      low,
+		Close:                    close,
+		Volume:                   volume,
+		CloseTime:                openTime + 59999,
+		QuoteAssetVolume:         volume,
+		TradeNum:                 2,
+		TakerBuyBaseAssetVolume:  "0.1",
+		TakerBuyQuoteAssetVolume: "0.01",
+	}
+}
+
+func openTimes(klines []*bitnut.Kline) []int64 {
+	res := make([]int64, 0, len(klines))
+	for _, k := range klines {
+		res = append(res, (k.OpenTime-bitnut.FormatTimestamp(start))/60000)
+	}
+	return res
+}
+
+func TestResample(t *testing.T) {
+	assert := assert.New(t)
+	klines := []*bitnut.Kline{
+		minute(6, "12", "14", "9", "13", "0.5"),
+		minute(0, "10", "11", "9.5", "10.5", "1.25"),
+		minute(1, "10.5", "12.25", "10", "11", "2"),
+		minute(4, "11", "11.5", "8.75", "12", "0.005"),
+	}
+
+	res, err := Resample(klines, bitnut.KlineInterval5m)
+	assert.NoError(err)
+	assert.Len(res, 2)
+	assert.Equal(bitnut.FormatTimestamp(start), res[0].OpenTime)
+	assert.Equal(bitnut.FormatTimestamp(start.Add(5*time.Minute))-1, res[0].CloseTime)
+	assert.Equal("10", res[0].Open)
+	assert.Equal("12.25", res[0].High)
+	assert.Equal("8.75", res[0].Low)
+	assert.Equal("12", res[0].Close)
+	assert.Equal("3.255", res[0].Volume)
+	assert.Equal("3.255", res[0].QuoteAssetVolume)
+	assert.Equal("0.3", res[0].TakerBuyBaseAssetVolume)
+	assert.Equal("0.03", res[0].TakerBuyQuoteAssetVolume)
+	assert.Equal(int64(6), res[0].TradeNum)
+	assert.Equal([]int64{0, 5}, openTimes(res))
+	assert.Equal("13", res[1].Close)
+
+	_, err = Resample(klines, bitnut.KlineInterval("2m"))
+	assert.Error(err)
+	hourly := []*bitnut.Kline{{OpenTime: bitnut.FormatTimestamp(start), Open: "1", High: "1", Low: "1", Close: "1",
+		CloseTime: bitnut.FormatTimestamp(start.Add(time.Hour)) - 1}}
+	_, err = Resample(hourly, bitnut.KlineInterval15m)
+	assert.Error(err)
+}
+
+func TestFindGapsAndFill(t *testing.T) {
+	assert := assert.New(t)
+	klines := []*bitnut.Kline{
+		minute(0, "1", "1", "1", "1", "1"),
+		minute(1, "1", "2", "1", "2.5", "1.50"),
+		minute(4, "3", "3", "3", "3", "1"),
+		minute(5, "3", "3", "3", "3", "1"),
+		minute(7, "3", "3", "3", "3", "1"),
+	}
+	minuteMs := int64(60000)
+	origin := bitnut.FormatTimestamp(start)
+
+	gaps := FindGaps(klines, bitnut.KlineInterval1m)
+	assert.Equal([]bitnut.KlineGap{
+		{From: origin + 2*minuteMs, To: origin + 4*minuteMs},
+		{From: origin + 6*minuteMs, To: origin + 7*minuteMs},
+	}, gaps)
+	assert.Empty(FindGaps(klines[:2], bitnut.KlineInterval1m))
+
+	testCases := []struct {
+		name string
+		mode FillMode
+		gaps []bitnut.KlineGap
+		fill func(k *bitnut.Kline)
+	}{
+		{"flat", FillFlat, []bitnut.KlineGap{}, func(k *bitnut.Kline) {
+			assert.False(IsGapMarker(k))
+			assert.Equal("2.5", k.Open)
+			assert.Equal("2.5", k.Close)
+			assert.Equal("0.00", k.Volume)
+			assert.Equal(k.OpenTime+59999, k.CloseTime)
+		}},
+		{"marker", FillMarker, gaps, func(k *bitnut.Kline) {
+			assert.True(IsGapMarker(k))
+			assert.Equal(k.OpenTime+59999, k.CloseTime)
+		}},
+	}
+	for _, tc := range testCases {
+		filled := Fill(klines, bitnut.KlineInterval1m, tc.mode)
+		assert.Equal([]int64{0, 1, 2, 3, 4, 5, 6, 7}, openTimes(filled), tc.name)
+		tc.fill(filled[2])
+		tc.fill(filled[3])
+		assert.Equal(tc.gaps, FindGaps(filled, bitnut.KlineInterval1m), tc.name)
+	}
+
+	res, err := Resample(Fill(klines, bitnut.KlineInterval1m, FillMarker), bitnut.KlineInterval5m)
+	assert.NoError(err)
+	assert.Equal("3.50", res[0].Volume)
+}
+
+func TestMerge(t *testing.T) {
+	assert := assert.New(t)
+	first := []*bitnut.Kline{
+		minute(0, "1", "1", "1", "1", "1"),
+		minute(1, "1", "1", "1", "1", "1"),
+		minute(2, "1", "1", "1", "1", "1"),
+	}
+	second := Fill([]*bitnut.Kline{
+		minute(2, "2", "2", "2", "2", "2"),
+		minute(4, "2", "2", "2", "2", "2"),
+	}, bitnut.KlineInterval1m, FillMarker)
+	third := Fill([]*bitnut.Kline{
+		minute(0, "3", "3", "3", "3", "3"),
+		minute(3, "3", "3", "3", "3", "3"),
+	}, bitnut.KlineInterval1m, FillMarker)
+
+	res := Merge(first, second, third)
+	assert.Equal([]int64{0, 1, 2, 3, 4}, openTimes(res))
+	assert.Equal("3", res[0].Close)
+	assert.Equal("1", res[1].Close, "a marker doesn't replace a kline")
+	assert.Equal("2", res[2].Close)
+	assert.Equal("3", res[3].Close)
+	assert.Equal("2", res[4].Close)
+}
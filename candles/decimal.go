@@ -0,0 +1,42 @@
+package candles
+
+import "github.com/hardyzp/bitnut/common"
+
+// sum add decimal strings exactly and format the result with as many
+// fractional digits as the most precise operand. Empty or invalid strings
+// count as zero.
+func sum(values ...string) string {
+	var total common.Decimal
+	for _, v := range values {
+		d, err := common.ParseDecimal(v)
+		if err != nil {
+			continue
+		}
+		total = total.Add(d)
+	}
+	return total.String()
+}
+
+// compare compare two decimal strings, unparsable values sort first
+func compare(a, b string) int {
+	da, errA := common.ParseDecimal(a)
+	db, errB := common.ParseDecimal(b)
+	switch {
+	case errA != nil && errB != nil:
+		return 0
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return da.Cmp(db)
+}
+
+// zero return a zero formatted with the precision of like
+func zero(like string) string {
+	d, err := common.ParseDecimal(like)
+	if err != nil {
+		return "0"
+	}
+	return d.Sub(d).String()
+}
@@ -3,18 +3,15 @@ package bitnut
 import (
     "bytes"
     "context"
-    "crypto/hmac"
-    "crypto/sha256"
-    "crypto/tls"
-    "encoding/base64"
+    "errors"
     "fmt"
     "github.com/bitly/go-simplejson"
     "github.com/hardyzp/bitnut/common"
     "io/ioutil"
     "log"
     "net/http"
-    "net/url"
     "os"
+    "sync"
     "time"
 
     jsoniter "github.com/json-iterator/go"
@@ -47,6 +44,9 @@ type SymbolFilterType string
 // UserDataEventType define spot user data event type
 type UserDataEventType string
 
+// ExecutionType define the cause of an order update
+type ExecutionType string
+
 // TransactionType define transaction type
 type TransactionType string
 
@@ -55,11 +55,17 @@ type AccountType string
 
 // Endpoints
 const (
-    baseAPIMainURL    = "https://api.bitnut.com"
-    baseAPITestnetURL = "https://testnet.bitnut.vision"
+    baseAPIMainURL           = "https://api.bitnut.com"
+    baseAPITestnetURL        = "https://testnet.bitnut.vision"
+    baseStreamMainURL        = "wss://stream.bitnut.com/ws"
+    baseStreamTestnetURL     = "wss://testnet.bitnut.vision/ws"
+    baseUserStreamMainURL    = "wss://stream.bitnut.com/user"
+    baseUserStreamTestnetURL = "wss://testnet.bitnut.vision/user"
 )
 
-// UseTestnet switch all the API endpoints from production to the testnet
+// UseTestnet select the testnet environment for clients created afterwards.
+//
+// Deprecated: use Client.SetEnvironment, which works per client.
 var UseTestnet = false
 
 // Redefining the standard package
@@ -70,8 +76,13 @@ const (
     SideTypeBuy  SideType = "BUY"
     SideTypeSell SideType = "SELL"
 
-    OrderTypeLimit  OrderType = "LIMIT"
-    OrderTypeMarket OrderType = "MARKET"
+    OrderTypeLimit           OrderType = "LIMIT"
+    OrderTypeMarket          OrderType = "MARKET"
+    OrderTypeLimitMaker      OrderType = "LIMIT_MAKER"
+    OrderTypeStopLoss        OrderType = "STOP_LOSS"
+    OrderTypeStopLossLimit   OrderType = "STOP_LOSS_LIMIT"
+    OrderTypeTakeProfit      OrderType = "TAKE_PROFIT"
+    OrderTypeTakeProfitLimit OrderType = "TAKE_PROFIT_LIMIT"
 
     TimeInForceTypeGTC TimeInForceType = "GTC"
     TimeInForceTypeIOC TimeInForceType = "IOC"
@@ -81,14 +92,36 @@ const (
     NewOrderRespTypeRESULT NewOrderRespType = "RESULT"
     NewOrderRespTypeFULL   NewOrderRespType = "FULL"
 
-    OrderStatusTypeNew      OrderStatusType = "NEW"
-    OrderStatusTypeFilled   OrderStatusType = "FILLED"
-    OrderStatusTypeCanceled OrderStatusType = "CANCELED"
+    OrderStatusTypeNew             OrderStatusType = "NEW"
+    OrderStatusTypePartiallyFilled OrderStatusType = "PARTIALLY_FILLED"
+    OrderStatusTypeFilled          OrderStatusType = "FILLED"
+    OrderStatusTypeCanceled        OrderStatusType = "CANCELED"
+    OrderStatusTypeRejected        OrderStatusType = "REJECTED"
+    OrderStatusTypeExpired         OrderStatusType = "EXPIRED"
+
+    ExecutionTypeNew      ExecutionType = "NEW"
+    ExecutionTypeCanceled ExecutionType = "CANCELED"
+    ExecutionTypeRejected ExecutionType = "REJECTED"
+    ExecutionTypeTrade    ExecutionType = "TRADE"
+    ExecutionTypeExpired  ExecutionType = "EXPIRED"
+
+    UserDataEventTypeExecutionReport UserDataEventType = "executionReport"
+    UserDataEventTypeBalanceUpdate   UserDataEventType = "balanceUpdate"
 
     SymbolTypeSpot SymbolType = "SPOT"
 
-    timestampKey = "timestamp"
-    signatureKey = "signature"
+    SymbolStatusTypeTrading SymbolStatusType = "TRADING"
+    SymbolStatusTypeHalt    SymbolStatusType = "HALT"
+    SymbolStatusTypeBreak   SymbolStatusType = "BREAK"
+
+    SymbolFilterTypePrice         SymbolFilterType = "PRICE_FILTER"
+    SymbolFilterTypeLotSize       SymbolFilterType = "LOT_SIZE"
+    SymbolFilterTypeMarketLotSize SymbolFilterType = "MARKET_LOT_SIZE"
+    SymbolFilterTypeMinNotional   SymbolFilterType = "MIN_NOTIONAL"
+
+    timestampKey  = "timestamp"
+    signatureKey  = "signature"
+    recvWindowKey = "recvWindow"
 
     AccountTypeSpot AccountType = "SPOT"
 )
@@ -110,87 +143,101 @@ func newJSON(data []byte) (j *simplejson.Json, err error) {
     return j, nil
 }
 
-// getAPIEndpoint return the base endpoint of the Rest API according the UseTestnet flag
-func getAPIEndpoint() string {
-    return baseAPIMainURL
-}
-
 // NewClient initialize an API client instance with API key and secret key.
 // You should always call this function before using this SDK.
 // Services will be created by the form client.NewXXXService().
 func NewClient(apiKey, secretKey string) *Client {
+    env := defaultEnvironment()
     return &Client{
-        APIKey:     apiKey,
-        SecretKey:  secretKey,
-        BaseURL:    getAPIEndpoint(),
-        UserAgent:  "Bitnut/golang",
-        HTTPClient: http.DefaultClient,
-        Logger:     log.New(os.Stderr, "Bitnut-golang ", log.LstdFlags),
+        APIKey:      apiKey,
+        SecretKey:   secretKey,
+        BaseURL:     env.BaseURL,
+        Environment: env,
+        UserAgent:   "Bitnut/golang",
+        HTTPClient:  http.DefaultClient,
+        Logger:      NewStdLogger(log.New(os.Stderr, "Bitnut-golang ", log.LstdFlags), LogLevelDebug),
+        RateLimiter: NewRateLimiter(DefaultRateLimiterConfig()),
+        RetryPolicy: DefaultRetryPolicy(),
     }
 }
 
 // NewProxiedClient passing a proxy url
+//
+// Deprecated: use New with WithProxy, which returns an error instead of
+// exiting the process on an invalid url.
 func NewProxiedClient(apiKey, secretKey, proxyUrl string) *Client {
-    proxy, err := url.Parse(proxyUrl)
+    c, err := New(apiKey, secretKey, WithProxy(proxyUrl))
     if err != nil {
         log.Fatal(err)
     }
-    tr := &http.Transport{
-        Proxy:           http.ProxyURL(proxy),
-        TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-    }
-    return &Client{
-        APIKey:    apiKey,
-        SecretKey: secretKey,
-        BaseURL:   getAPIEndpoint(),
-        UserAgent: "Bitnut/golang",
-        HTTPClient: &http.Client{
-            Transport: tr,
-        },
-        Logger: log.New(os.Stderr, "Bitnut-golang ", log.LstdFlags),
-    }
+    return c
 }
 
 type doFunc func(req *http.Request) (*http.Response, error)
 
 // Client define API client
 type Client struct {
+    // TimeOffset is the local clock minus the server clock in milliseconds.
+    // It is accessed atomically and kept first for 64-bit alignment.
+    TimeOffset int64
+
     APIKey     string
     SecretKey  string
     BaseURL    string
     UserAgent  string
     HTTPClient *http.Client
-    Debug      bool
-    Logger     *log.Logger
-    TimeOffset int64
-    do         doFunc
-}
-
-func (c *Client) debug(format string, v ...interface{}) {
-    if c.Debug {
-        c.Logger.Printf(format, v...)
-    }
+    // Debug enables the debug entries of Logger
+    Debug  bool
+    Logger Logger
+
+    // Environment is the deployment the client talks to
+    Environment Environment
+    // AllowMainnetTrading must be set to send order requests to mainnet
+    AllowMainnetTrading bool
+    // RateLimiter delays requests that would exceed the exchange limits,
+    // set it to nil to disable client-side rate limiting.
+    RateLimiter *RateLimiter
+    // RetryPolicy is applied to every request that is safe to resend,
+    // set it to nil to disable retries.
+    RetryPolicy *RetryPolicy
+    // Signer signs the signed requests, the body-only HMAC-SHA256 scheme
+    // keyed with SecretKey is used when nil.
+    Signer Signer
+    // RecvWindow is sent with signed requests when positive, in milliseconds
+    RecvWindow int64
+
+    middlewares []Middleware
+    // attachMu guard the helpers attached to the client
+    attachMu    sync.Mutex
+    timeSyncer  *TimeSyncer
+    symbolCache *SymbolCache
+    do          doFunc
 }
 
-func (c *Client) parseRequest(r *request, opts ...RequestOption) (err error) {
-    // set request options from user
-    for _, opt := range opts {
-        opt(r)
-    }
+func (c *Client) parseRequest(r *request) (err error) {
     err = r.validate()
     if err != nil {
         return err
     }
 
+    if r.trading && !c.AllowMainnetTrading && c.sendsToMainnet() {
+        return ErrMainnetTradingDisabled
+    }
+
     fullURL := fmt.Sprintf("%s%s", c.BaseURL, r.endpoint)
     if r.secType == secTypeSigned {
-        r.setParam(timestampKey, currentTimestamp()-c.TimeOffset)
+        r.setParam(timestampKey, currentTimestamp()-c.timeOffset())
+        recvWindow := c.RecvWindow
+        if r.recvWindow != nil {
+            recvWindow = *r.recvWindow
+        }
+        if recvWindow > 0 {
+            r.setParam(recvWindowKey, recvWindow)
+        }
     }
     queryString := r.query.Encode()
-    c.debug("queryString:", queryString)
     body := &bytes.Buffer{}
     bodyString := r.form.Encode()
-    c.debug("bodyString:", bodyString)
     header := http.Header{}
     if r.header != nil {
         header = r.header.Clone()
@@ -199,6 +246,9 @@ func (c *Client) parseRequest(r *request, opts ...RequestOption) (err error) {
         header.Set("Content-Type", "application/x-www-form-urlencoded")
         body = bytes.NewBufferString(bodyString)
     }
+    if c.UserAgent != "" && header.Get("User-Agent") == "" {
+        header.Set("User-Agent", c.UserAgent)
+    }
     if r.secType == secTypeAPIKey || r.secType == secTypeSigned {
         header.Set("BU-ACCESS-KEY", c.APIKey)
     }
@@ -206,18 +256,21 @@ func (c *Client) parseRequest(r *request, opts ...RequestOption) (err error) {
         fullURL = fmt.Sprintf("%s?%s", fullURL, queryString)
     }
     if r.secType == secTypeSigned {
-        // raw := fmt.Sprintf("%s%s", queryString, bodyString)
-        raw := bodyString
-        mac := hmac.New(sha256.New, []byte(c.SecretKey))
-        _, err = mac.Write([]byte(raw))
+        signer := c.Signer
+        if signer == nil {
+            signer = NewHMACSigner(c.SecretKey)
+        }
+        signature, err := signer.Sign(&SignPayload{
+            Method:   r.method,
+            Endpoint: r.endpoint,
+            Query:    queryString,
+            Body:     bodyString,
+        })
         if err != nil {
             return err
         }
-        signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
         header.Set("BU-ACCESS-SIGN", signature)
     }
-    c.debug("full url: %s, body: %s", fullURL, bodyString)
-
     r.fullURL = fullURL
     r.header = header
     r.body = body
@@ -225,7 +278,46 @@ func (c *Client) parseRequest(r *request, opts ...RequestOption) (err error) {
 }
 
 func (c *Client) callAPI(ctx context.Context, r *request, opts ...RequestOption) (data []byte, err error) {
-    err = c.parseRequest(r, opts...)
+    // set request options from user
+    for _, opt := range opts {
+        opt(r)
+    }
+    policy := c.RetryPolicy
+    if r.retryPolicySet {
+        policy = r.retryPolicy
+    }
+    resynced := false
+    for attempt := 1; ; attempt++ {
+        data, err = c.callAPIOnce(ctx, r)
+        if err != nil && !resynced && r.secType == secTypeSigned && errors.Is(err, common.ErrInvalidTimestamp) {
+            // the request was rejected before being processed, so it is
+            // safe to send it again once the clock offset is fixed
+            resynced = true
+            c.log(LogLevelInfo, "timestamp rejected, resyncing server time", F("endpoint", r.endpoint))
+            if serr := c.resyncTime(ctx); serr == nil {
+                data, err = c.callAPIOnce(ctx, r)
+            }
+        }
+        if err == nil || r.noRetry || !policy.shouldRetry(attempt, err) {
+            return data, err
+        }
+        c.log(LogLevelInfo, "retrying request", F("endpoint", r.endpoint), F("attempt", attempt), F("error", err))
+        if werr := policy.wait(ctx, attempt); werr != nil {
+            return data, err
+        }
+    }
+}
+
+func (c *Client) callAPIOnce(ctx context.Context, r *request) (data []byte, err error) {
+    // wait before stamping and signing, so the timestamp is not stale when
+    // the limiter holds the request back
+    if c.RateLimiter != nil {
+        err = c.RateLimiter.Wait(ctx, r.endpoint)
+        if err != nil {
+            return []byte{}, err
+        }
+    }
+    err = c.parseRequest(r)
     if err != nil {
         return []byte{}, err
     }
@@ -235,15 +327,27 @@ func (c *Client) callAPI(ctx context.Context, r *request, opts ...RequestOption)
     }
     req = req.WithContext(ctx)
     req.Header = r.header
-    c.debug("request: %#v", req)
-    f := c.do
-    if f == nil {
-        f = c.HTTPClient.Do
-    }
-    res, err := f(req)
+    c.debug("sending request",
+        F("method", r.method),
+        F("endpoint", r.endpoint),
+        F("query", redactValues(r.query)),
+        F("body", redactValues(r.form)),
+        F("header", redactHeader(req.Header)),
+    )
+    start := time.Now()
+    res, err := c.handler()(r.info(), req)
     if err != nil {
+        c.log(LogLevelWarn, "request failed",
+            F("method", r.method),
+            F("endpoint", r.endpoint),
+            F("latency", time.Since(start)),
+            F("error", err),
+        )
         return []byte{}, err
     }
+    if c.RateLimiter != nil {
+        c.RateLimiter.Update(res)
+    }
     data, err = ioutil.ReadAll(res.Body)
     if err != nil {
         return []byte{}, err
@@ -256,23 +360,44 @@ func (c *Client) callAPI(ctx context.Context, r *request, opts ...RequestOption)
             err = cerr
         }
     }()
-    c.debug("response: %#v", res)
-    c.debug("response body: %s", string(data))
-    c.debug("response status code: %d", res.StatusCode)
+    code, _, codeErr := decodeResponse(data, nil)
+    level := LogLevelDebug
+    if res.StatusCode >= http.StatusBadRequest || codeErr != nil {
+        level = LogLevelWarn
+    }
+    c.log(level, "received response",
+        F("method", r.method),
+        F("endpoint", r.endpoint),
+        F("latency", time.Since(start)),
+        F("status", res.StatusCode),
+        F("code", code),
+    )
+    c.debug("response body", F("endpoint", r.endpoint), F("body", redactBody(data)))
 
     if res.StatusCode >= http.StatusBadRequest {
-        apiErr := new(common.APIError)
+        apiErr := &common.APIError{StatusCode: res.StatusCode}
         e := json.Unmarshal(data, apiErr)
         if e != nil {
-            c.debug("failed to unmarshal json: %s", e)
+            c.debug("failed to unmarshal error response", F("error", e))
         }
         return nil, apiErr
     }
+    if codeErr != nil {
+        var apiErr *common.APIError
+        if errors.As(codeErr, &apiErr) {
+            apiErr.StatusCode = res.StatusCode
+        }
+        return nil, codeErr
+    }
     return data, nil
 }
 
-// SetApiEndpoint set api Endpoint
+// SetApiEndpoint set api Endpoint. The environment follows: the mainnet and
+// testnet URLs select their environment, any other URL a custom one keeping
+// the current stream URLs.
 func (c *Client) SetApiEndpoint(url string) *Client {
+    url = trimBaseURL(url)
+    c.Environment = environmentForURL(url, c.Environment)
     c.BaseURL = url
     return c
 }
@@ -287,6 +412,11 @@ func (c *Client) NewSetServerTimeService() *SetServerTimeService {
     return &SetServerTimeService{c: c}
 }
 
+// NewExchangeInfoService init exchange info service
+func (c *Client) NewExchangeInfoService() *ExchangeInfoService {
+    return &ExchangeInfoService{c: c}
+}
+
 // NewDepthService init depth service
 func (c *Client) NewDepthService() *DepthService {
     return &DepthService{c: c}
@@ -341,3 +471,28 @@ func (c *Client) NewListTradesService() *ListTradesService {
 func (c *Client) NewHistoricalTradesService() *HistoricalTradesService {
     return &HistoricalTradesService{c: c}
 }
+
+// NewRecentTradesService init recent trades service
+func (c *Client) NewRecentTradesService() *RecentTradesService {
+    return &RecentTradesService{c: c}
+}
+
+// NewAggTradesService init aggregate trades service
+func (c *Client) NewAggTradesService() *AggTradesService {
+    return &AggTradesService{c: c}
+}
+
+// NewStartUserStreamService init starting user stream service
+func (c *Client) NewStartUserStreamService() *StartUserStreamService {
+    return &StartUserStreamService{c: c}
+}
+
+// NewKeepaliveUserStreamService init keep alive user stream service
+func (c *Client) NewKeepaliveUserStreamService() *KeepaliveUserStreamService {
+    return &KeepaliveUserStreamService{c: c}
+}
+
+// NewCloseUserStreamService init closing user stream service
+func (c *Client) NewCloseUserStreamService() *CloseUserStreamService {
+    return &CloseUserStreamService{c: c}
+}
@@ -0,0 +1,321 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode define how Decimal values are rounded
+type RoundingMode int
+
+// Rounding modes
+const (
+	// RoundDown round toward zero
+	RoundDown RoundingMode = iota
+	// RoundUp round away from zero
+	RoundUp
+	// RoundHalfUp round to the nearest, ties away from zero
+	RoundHalfUp
+	// RoundHalfEven round to the nearest, ties to the even neighbour
+	RoundHalfEven
+	// RoundFloor round toward negative infinity
+	RoundFloor
+	// RoundCeiling round toward positive infinity
+	RoundCeiling
+)
+
+// ErrDivisionByZero is returned when dividing a Decimal by zero
+var ErrDivisionByZero = errors.New("division by zero")
+
+var ten = big.NewInt(10)
+
+// Decimal is an exact fixed-point decimal number, the unscaled value divided
+// by 10^scale. Operations never lose precision except the explicit
+// rounding ones. The zero value is 0.
+type Decimal struct {
+	value *big.Int
+	scale int
+}
+
+// NewDecimal return value * 10^-scale, NewDecimal(123, 2) is 1.23
+func NewDecimal(value int64, scale int) Decimal {
+	if scale < 0 {
+		return Decimal{value: new(big.Int).Mul(big.NewInt(value), pow10(-scale))}
+	}
+	return Decimal{value: big.NewInt(value), scale: scale}
+}
+
+// ParseDecimal parse a decimal string such as "-12.3400" or "1e-8". The
+// number of fractional digits is kept, so "1.50" prints back as "1.50".
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		mantissa, exp = s[:i], e
+	}
+	digits := strings.TrimLeft(mantissa, "+-")
+	if len(mantissa)-len(digits) > 1 {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	scale := 0
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		scale = len(digits) - i - 1
+		digits = digits[:i] + digits[i+1:]
+	}
+	if digits == "" || strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	value, _ := new(big.Int).SetString(digits, 10)
+	if strings.HasPrefix(mantissa, "-") {
+		value.Neg(value)
+	}
+	scale -= exp
+	if scale < 0 {
+		value.Mul(value, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{value: value, scale: scale}, nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics on invalid input, for
+// constants
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) unscaled() *big.Int {
+	if d.value == nil {
+		return new(big.Int)
+	}
+	return d.value
+}
+
+// rescale return the unscaled value of d at a larger scale
+func (d Decimal) rescale(scale int) *big.Int {
+	if scale == d.scale {
+		return d.unscaled()
+	}
+	return new(big.Int).Mul(d.unscaled(), pow10(scale-d.scale))
+}
+
+func align(a, b Decimal) (*big.Int, *big.Int, int) {
+	scale := a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	return a.rescale(scale), b.rescale(scale), scale
+}
+
+// Scale return the number of fractional digits of d
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// String return d with exactly Scale fractional digits
+func (d Decimal) String() string {
+	s := new(big.Int).Abs(d.unscaled()).String()
+	if d.scale > 0 {
+		if len(s) <= d.scale {
+			s = strings.Repeat("0", d.scale-len(s)+1) + s
+		}
+		s = s[:len(s)-d.scale] + "." + s[len(s)-d.scale:]
+	}
+	if d.Sign() < 0 {
+		return "-" + s
+	}
+	return s
+}
+
+// Rat return d as a big.Rat
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.unscaled(), pow10(d.scale))
+}
+
+// Float64 return the nearest float64 to d
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// Sign return -1, 0 or 1
+func (d Decimal) Sign() int {
+	return d.unscaled().Sign()
+}
+
+// IsZero check if d is zero
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Cmp compare d and o, returning -1, 0 or 1. Scale is ignored, 1.0 equals 1.
+func (d Decimal) Cmp(o Decimal) int {
+	a, b, _ := align(d, o)
+	return a.Cmp(b)
+}
+
+// Equal check if d and o are the same number
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+// LessThan check if d < o
+func (d Decimal) LessThan(o Decimal) bool {
+	return d.Cmp(o) < 0
+}
+
+// GreaterThan check if d > o
+func (d Decimal) GreaterThan(o Decimal) bool {
+	return d.Cmp(o) > 0
+}
+
+// Neg return -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{value: new(big.Int).Neg(d.unscaled()), scale: d.scale}
+}
+
+// Abs return |d|
+func (d Decimal) Abs() Decimal {
+	return Decimal{value: new(big.Int).Abs(d.unscaled()), scale: d.scale}
+}
+
+// Add return d + o
+func (d Decimal) Add(o Decimal) Decimal {
+	a, b, scale := align(d, o)
+	return Decimal{value: new(big.Int).Add(a, b), scale: scale}
+}
+
+// Sub return d - o
+func (d Decimal) Sub(o Decimal) Decimal {
+	a, b, scale := align(d, o)
+	return Decimal{value: new(big.Int).Sub(a, b), scale: scale}
+}
+
+// Mul return d * o
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{value: new(big.Int).Mul(d.unscaled(), o.unscaled()), scale: d.scale + o.scale}
+}
+
+// Quo return d / o rounded to places fractional digits
+func (d Decimal) Quo(o Decimal, places int, mode RoundingMode) (Decimal, error) {
+	if o.IsZero() {
+		return Decimal{}, ErrDivisionByZero
+	}
+	// d / o = (dv * 10^os) / (ov * 10^ds)
+	num := new(big.Int).Mul(d.unscaled(), pow10(o.scale))
+	den := new(big.Int).Mul(o.unscaled(), pow10(d.scale))
+	return roundFrac(num, den, places, mode), nil
+}
+
+// Round return d rounded to places fractional digits, the result has
+// exactly places fractional digits
+func (d Decimal) Round(places int, mode RoundingMode) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return Decimal{value: d.rescale(places), scale: places}
+	}
+	return roundFrac(d.unscaled(), pow10(d.scale), places, mode)
+}
+
+// Truncate return d with the fractional digits after places dropped
+func (d Decimal) Truncate(places int) Decimal {
+	return d.Round(places, RoundDown)
+}
+
+// RoundToStep return the multiple of step nearest to d in the direction of
+// mode, such as a price on the tick size or a quantity on the lot step. The
+// result has the scale of step. A zero or negative step leaves d unchanged.
+func (d Decimal) RoundToStep(step Decimal, mode RoundingMode) Decimal {
+	if step.Sign() <= 0 {
+		return d
+	}
+	a, b, _ := align(d, step)
+	n := roundFrac(a, b, 0, mode)
+	return n.Mul(step)
+}
+
+// roundFrac return num / den rounded to places fractional digits
+func roundFrac(num, den *big.Int, places int, mode RoundingMode) Decimal {
+	num = new(big.Int).Mul(num, pow10(places))
+	den = new(big.Int).Set(den)
+	if den.Sign() < 0 {
+		num.Neg(num)
+		den.Neg(den)
+	}
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return Decimal{value: q, scale: places}
+	}
+	sign := int64(num.Sign())
+	away := false
+	switch mode {
+	case RoundUp:
+		away = true
+	case RoundFloor:
+		away = sign < 0
+	case RoundCeiling:
+		away = sign > 0
+	case RoundHalfUp, RoundHalfEven:
+		half := new(big.Int).Abs(r)
+		half.Lsh(half, 1)
+		c := half.Cmp(den)
+		away = c > 0 || (c == 0 && (mode == RoundHalfUp || q.Bit(0) == 1))
+	}
+	if away {
+		q.Add(q, big.NewInt(sign))
+	}
+	return Decimal{value: q, scale: places}
+}
+
+// MarshalJSON encode d as a JSON string, like the exchange does
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON decode a JSON string or number, null and "" decode as zero
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*d = Decimal{}
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+	return d.UnmarshalText(data)
+}
+
+// MarshalText encode d as its String
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText parse text with ParseDecimal, empty text decodes as zero
+func (d *Decimal) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = Decimal{}
+		return nil
+	}
+	v, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
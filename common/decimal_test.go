@@ -0,0 +1,152 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecimal(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "0", want: "0"},
+		{in: "1.50", want: "1.50"},
+		{in: "-0.00100000", want: "-0.00100000"},
+		{in: "+42", want: "42"},
+		{in: ".5", want: "0.5"},
+		{in: "1e-8", want: "0.00000001"},
+		{in: "1.5E3", want: "1500"},
+		{in: "11232821093480213.31232419283240912834434", want: "11232821093480213.31232419283240912834434"},
+		{in: "", err: true},
+		{in: "-", err: true},
+		{in: "1.2.3", err: true},
+		{in: "--1", err: true},
+		{in: "1e", err: true},
+		{in: "abc", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := ParseDecimal(tt.in)
+			if tt.err {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, d.String())
+		})
+	}
+	assert.Equal("0", Decimal{}.String())
+	assert.Equal("1.23", NewDecimal(123, 2).String())
+	assert.Equal("-0.05", NewDecimal(-5, 2).String())
+	assert.Equal("1200", NewDecimal(12, -2).String())
+	assert.Panics(func() { MustParseDecimal("x") })
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	assert := assert.New(t)
+	a := MustParseDecimal("0.1")
+	b := MustParseDecimal("0.20")
+	assert.Equal("0.30", a.Add(b).String())
+	assert.Equal("-0.10", a.Sub(b).String())
+	assert.Equal("0.020", a.Mul(b).String())
+	assert.Equal("0.1", a.Abs().String())
+	assert.Equal("-0.1", a.Neg().String())
+	assert.Equal(0, MustParseDecimal("1.0").Cmp(MustParseDecimal("1")))
+	assert.True(a.LessThan(b))
+	assert.True(b.GreaterThan(a))
+	assert.True(MustParseDecimal("0.000").IsZero())
+	assert.Equal(-1, a.Neg().Sign())
+	assert.Equal(0.3, a.Add(b).Float64())
+	assert.Equal("3/10", a.Add(b).Rat().String())
+	assert.Equal(2, b.Scale())
+
+	q, err := MustParseDecimal("1").Quo(MustParseDecimal("3"), 4, RoundHalfUp)
+	assert.NoError(err)
+	assert.Equal("0.3333", q.String())
+	q, err = MustParseDecimal("2").Quo(MustParseDecimal("-0.3"), 2, RoundHalfUp)
+	assert.NoError(err)
+	assert.Equal("-6.67", q.String())
+	_, err = a.Quo(Decimal{}, 2, RoundDown)
+	assert.ErrorIs(err, ErrDivisionByZero)
+
+	big := MustParseDecimal("11232821093480213.31232419283240912834434")
+	assert.Equal("11232821093480213.312", big.RoundToStep(MustParseDecimal("0.001"), RoundDown).String())
+}
+
+func TestDecimalRounding(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		in   string
+		mode RoundingMode
+		want string
+	}{
+		{"1.25", RoundDown, "1.2"},
+		{"-1.25", RoundDown, "-1.2"},
+		{"1.21", RoundUp, "1.3"},
+		{"-1.21", RoundUp, "-1.3"},
+		{"1.25", RoundHalfUp, "1.3"},
+		{"-1.25", RoundHalfUp, "-1.3"},
+		{"1.24", RoundHalfUp, "1.2"},
+		{"1.25", RoundHalfEven, "1.2"},
+		{"1.35", RoundHalfEven, "1.4"},
+		{"1.251", RoundHalfEven, "1.3"},
+		{"-1.21", RoundFloor, "-1.3"},
+		{"1.29", RoundFloor, "1.2"},
+		{"-1.29", RoundCeiling, "-1.2"},
+		{"1.21", RoundCeiling, "1.3"},
+		{"1.2", RoundUp, "1.2"},
+	}
+	for _, tt := range tests {
+		assert.Equal(tt.want, MustParseDecimal(tt.in).Round(1, tt.mode).String(), "%s %d", tt.in, tt.mode)
+	}
+	assert.Equal("1.500", MustParseDecimal("1.5").Round(3, RoundDown).String())
+	assert.Equal("1", MustParseDecimal("1.99").Truncate(0).String())
+
+	steps := []struct {
+		in   string
+		step string
+		mode RoundingMode
+		want string
+	}{
+		{"1.39", "0.001", RoundDown, "1.390"},
+		{"0.0001", "0.001", RoundDown, "0.000"},
+		{"27123.456", "0.05", RoundDown, "27123.45"},
+		{"27123.476", "0.05", RoundHalfUp, "27123.50"},
+		{"27123.401", "0.05", RoundCeiling, "27123.45"},
+		{"0.123456789", "0.00001000", RoundDown, "0.12345000"},
+		{"7", "2", RoundDown, "6"},
+		{"1.39", "0", RoundDown, "1.39"},
+	}
+	for _, tt := range steps {
+		got := MustParseDecimal(tt.in).RoundToStep(MustParseDecimal(tt.step), tt.mode)
+		assert.Equal(tt.want, got.String(), "%s step %s", tt.in, tt.step)
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	assert := assert.New(t)
+	var v struct {
+		Price    Decimal  `json:"price"`
+		Quantity Decimal  `json:"qty"`
+		Empty    Decimal  `json:"empty"`
+		Null     *Decimal `json:"null"`
+	}
+	err := json.Unmarshal([]byte(`{"price":"0.00012300","qty":1.5,"empty":"","null":null}`), &v)
+	assert.NoError(err)
+	assert.Equal("0.00012300", v.Price.String())
+	assert.Equal("1.5", v.Quantity.String())
+	assert.True(v.Empty.IsZero())
+	assert.Nil(v.Null)
+
+	data, err := json.Marshal(v)
+	assert.NoError(err)
+	assert.JSONEq(`{"price":"0.00012300","qty":"1.5","empty":"0","null":null}`, string(data))
+
+	assert.Error(json.Unmarshal([]byte(`{"price":"1,5"}`), &v))
+	assert.Error(json.Unmarshal([]byte(`{"price":true}`), &v))
+}
@@ -1,13 +1,74 @@
 package common
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 )
 
-// APIError define API error when response status is 4xx or 5xx
+// Exchange error catalogue. An *APIError matches one of these through
+// errors.Is when its code is registered for it, so callers don't have to
+// compare raw codes or messages.
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrInvalidSymbol       = errors.New("invalid symbol")
+	ErrInvalidSignature    = errors.New("invalid signature")
+	ErrRateLimited         = errors.New("rate limited")
+	ErrUnknownOrder        = errors.New("unknown order")
+	ErrInvalidTimestamp    = errors.New("timestamp outside of recvWindow")
+	ErrDuplicateOrder      = errors.New("duplicate order")
+)
+
+// Exchange error codes known to the SDK
+const (
+	CodeRateLimited         int64 = -1003
+	CodeInvalidTimestamp    int64 = -1021
+	CodeInvalidSignature    int64 = -1022
+	CodeInvalidSymbol       int64 = -1121
+	CodeInsufficientBalance int64 = -2010
+	CodeUnknownOrder        int64 = -2013
+	CodeDuplicateOrder      int64 = -2026
+)
+
+// StatusIPBanned is the HTTP status answered to clients that kept sending
+// requests after being rate limited
+const StatusIPBanned = http.StatusTeapot
+
+var (
+	errorCodesMu sync.RWMutex
+	errorCodes   = map[int64]error{
+		CodeRateLimited:         ErrRateLimited,
+		CodeInvalidTimestamp:    ErrInvalidTimestamp,
+		CodeInvalidSignature:    ErrInvalidSignature,
+		CodeInvalidSymbol:       ErrInvalidSymbol,
+		CodeInsufficientBalance: ErrInsufficientBalance,
+		CodeUnknownOrder:        ErrUnknownOrder,
+		CodeDuplicateOrder:      ErrDuplicateOrder,
+	}
+)
+
+// RegisterErrorCode map an exchange error code to a catalogue error, so that
+// an *APIError carrying this code matches target through errors.Is
+func RegisterErrorCode(code int64, target error) {
+	errorCodesMu.Lock()
+	defer errorCodesMu.Unlock()
+	errorCodes[code] = target
+}
+
+// ErrorForCode return the catalogue error registered for code, or nil
+func ErrorForCode(code int64) error {
+	errorCodesMu.RLock()
+	defer errorCodesMu.RUnlock()
+	return errorCodes[code]
+}
+
+// APIError define API error when response status is 4xx or 5xx, or when a
+// 200 response carries a non-zero code in its envelope
 type APIError struct {
-	Code    int64  `json:"code"`
-	Message string `json:"msg"`
+	Code       int64  `json:"code"`
+	Message    string `json:"msg"`
+	StatusCode int    `json:"-"`
 }
 
 // Error return error code and message
@@ -15,8 +76,16 @@ func (e APIError) Error() string {
 	return fmt.Sprintf("<APIError> code=%d, msg=%s", e.Code, e.Message)
 }
 
+// Unwrap return the catalogue error registered for the code, if any
+func (e APIError) Unwrap() error {
+	if e.StatusCode == http.StatusTooManyRequests || e.StatusCode == StatusIPBanned {
+		return ErrRateLimited
+	}
+	return ErrorForCode(e.Code)
+}
+
 // IsAPIError check if e is an API error
 func IsAPIError(e error) bool {
-	_, ok := e.(*APIError)
-	return ok
+	var apiErr *APIError
+	return errors.As(e, &apiErr)
 }
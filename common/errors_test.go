@@ -0,0 +1,56 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrorCatalogue(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		name   string
+		err    error
+		target error
+	}{
+		{
+			name:   "insufficient balance",
+			err:    &APIError{Code: CodeInsufficientBalance, Message: "balance not enough"},
+			target: ErrInsufficientBalance,
+		},
+		{
+			name:   "wrapped unknown order",
+			err:    fmt.Errorf("get order: %w", &APIError{Code: CodeUnknownOrder}),
+			target: ErrUnknownOrder,
+		},
+		{
+			name:   "http 429",
+			err:    &APIError{StatusCode: http.StatusTooManyRequests},
+			target: ErrRateLimited,
+		},
+		{
+			name:   "ip banned",
+			err:    &APIError{StatusCode: StatusIPBanned},
+			target: ErrRateLimited,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(errors.Is(tt.err, tt.target))
+			assert.True(IsAPIError(tt.err))
+		})
+	}
+	assert.False(errors.Is(&APIError{Code: 1}, ErrInvalidSymbol))
+
+	errCustom := errors.New("custom")
+	RegisterErrorCode(12345, errCustom)
+	t.Cleanup(func() {
+		errorCodesMu.Lock()
+		delete(errorCodes, 12345)
+		errorCodesMu.Unlock()
+	})
+	assert.True(errors.Is(&APIError{Code: 12345}, errCustom))
+}
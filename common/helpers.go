@@ -4,6 +4,9 @@ import "math"
 import "bytes"
 
 // AmountToLotSize converts an amount to a lot sized amount
+//
+// Deprecated: float64 arithmetic loses precision, use Decimal.RoundToStep
+// or bitnut.CreateOrderService.RoundToSymbol.
 func AmountToLotSize(lot float64, precision int, amount float64) float64 {
 	return math.Trunc(math.Floor(amount/lot)*lot*math.Pow10(precision)) / math.Pow10(precision)
 }
@@ -23,3 +23,24 @@ func (p *PriceLevel) Parse() (float64, float64, error) {
 	}
 	return price, quantity, nil
 }
+
+// DecimalLevel is a PriceLevel with its Price and Quantity parsed
+// as Decimal.
+type DecimalLevel struct {
+	Price    Decimal
+	Quantity Decimal
+}
+
+// Decimal parses this PriceLevel's Price and Quantity as
+// Decimal values, without the precision loss of Parse.
+func (p *PriceLevel) Decimal() (DecimalLevel, error) {
+	price, err := ParseDecimal(p.Price)
+	if err != nil {
+		return DecimalLevel{}, err
+	}
+	quantity, err := ParseDecimal(p.Quantity)
+	if err != nil {
+		return DecimalLevel{}, err
+	}
+	return DecimalLevel{Price: price, Quantity: quantity}, nil
+}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Redacted replace the masked values of logs and recorded fixtures
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the lowercased parameter and JSON keys whose values are
+// never logged nor recorded
+var sensitiveKeys = []string{"signature", "apikey", "secretkey", "secret", "listenkey"}
+
+// SensitiveKeys return the parameter and JSON keys carrying secrets, such
+// as the listen key of the user data stream
+func SensitiveKeys() []string {
+	return append([]string(nil), sensitiveKeys...)
+}
+
+// IsSensitiveKey report whether key is one of SensitiveKeys, in any case
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactJSON mask the values of the keys matched by sensitive at any depth
+// of a JSON body. The body is returned as is when it is not JSON or nothing
+// was masked, numbers keep their original text otherwise.
+func RedactJSON(body []byte, sensitive func(key string) bool) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if dec.Decode(&v) != nil || !redactValue(v, sensitive) {
+		return body
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return []byte(Redacted)
+	}
+	return data
+}
+
+// redactValue mask the sensitive keys of a decoded JSON value in place, it
+// report whether anything was masked
+func redactValue(v interface{}, sensitive func(key string) bool) bool {
+	masked := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if sensitive(k) {
+				v[k] = Redacted
+				masked = true
+				continue
+			}
+			masked = redactValue(item, sensitive) || masked
+		}
+	case []interface{}:
+		for _, item := range v {
+			masked = redactValue(item, sensitive) || masked
+		}
+	}
+	return masked
+}
@@ -0,0 +1,24 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactJSON(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(IsSensitiveKey("listenKey"))
+	assert.True(IsSensitiveKey("SIGNATURE"))
+	assert.False(IsSensitiveKey("orderId"))
+
+	assert.Equal(`{"code":0,"data":[{"apiKey":"`+Redacted+`","id":123456789012345678}]}`,
+		string(RedactJSON([]byte(`{"code":0,"data":[{"apiKey":"k","id":123456789012345678}]}`), IsSensitiveKey)))
+	body := []byte(`{"b":1, "a":2}`)
+	assert.Equal(string(body), string(RedactJSON(body, IsSensitiveKey)))
+	assert.Equal("not json", string(RedactJSON([]byte("not json"), IsSensitiveKey)))
+
+	keys := SensitiveKeys()
+	keys[0] = "changed"
+	assert.True(IsSensitiveKey("signature"))
+}
@@ -0,0 +1,218 @@
+package bitnut_test
+
+import (
+    "context"
+    "errors"
+    "os"
+    "testing"
+
+    "github.com/hardyzp/bitnut"
+    "github.com/hardyzp/bitnut/bitnuttest"
+    "github.com/hardyzp/bitnut/common"
+    "github.com/stretchr/testify/assert"
+)
+
+// replayClient return a client answering from the fixture at path
+func replayClient(t *testing.T, path string) *bitnut.Client {
+    rec, err := bitnuttest.NewRecorder(path, bitnuttest.ModeReplay, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    c := bitnut.NewClient("key", "secret")
+    c.HTTPClient = rec.HTTPClient()
+    c.Logger = bitnut.NopLogger()
+    c.RetryPolicy = nil
+    return c
+}
+
+// TestRecordMarketData record the market endpoints decoded below from
+// testnet, when BITNUT_RECORD_TESTNET is set. testdata/market_data.json is
+// still written by hand, the recording is the base to replace it with.
+func TestRecordMarketData(t *testing.T) {
+    if os.Getenv("BITNUT_RECORD_TESTNET") == "" {
+        t.Skip("set BITNUT_RECORD_TESTNET to record from testnet")
+    }
+    assert := assert.New(t)
+    rec, err := bitnuttest.NewRecorder("testdata/market_data_testnet.json", bitnuttest.ModeRecord, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    c := bitnut.NewClient(os.Getenv("BITNUT_API_KEY"), os.Getenv("BITNUT_SECRET_KEY")).SetEnvironment(bitnut.EnvironmentTestnet)
+    c.HTTPClient = rec.HTTPClient()
+    ctx := context.Background()
+
+    _, err = c.NewKlinesService().Symbol("BTCUSDT").Interval(bitnut.KlineInterval1m).Limit(2).Do(ctx)
+    assert.NoError(err)
+    _, err = c.NewDepthService().Symbol("BTCUSDT").Limit(3).Do(ctx)
+    assert.NoError(err)
+    _, err = c.NewListSymbolTickerService().Symbol("BTCUSDT").Do(ctx)
+    assert.NoError(err)
+    _, err = c.NewExchangeInfoService().Symbol("BTCUSDT").Do(ctx)
+    assert.NoError(err)
+    _, err = c.NewRecentTradesService().Symbol("BTCUSDT").Limit(2).Do(ctx)
+    assert.NoError(err)
+    _, err = c.NewAggTradesService().Symbol("BTCUSDT").Limit(1).Do(ctx)
+    assert.NoError(err)
+    if !t.Failed() {
+        assert.NoError(rec.Save())
+    }
+}
+
+func TestDecodeMarketData(t *testing.T) {
+    assert := assert.New(t)
+    c := replayClient(t, "testdata/market_data.json")
+    ctx := context.Background()
+
+    klines, err := c.NewKlinesService().Symbol("BTCUSDT").Interval(bitnut.KlineInterval1m).Limit(2).Do(ctx)
+    assert.NoError(err)
+    assert.Len(klines, 2)
+    assert.Equal(&bitnut.Kline{
+        OpenTime:                 1650000000000,
+        Open:                     "40000.10",
+        High:                     "40010.00",
+        Low:                      "39990.50",
+        Close:                    "40005.20",
+        Volume:                   "12.345",
+        CloseTime:                1650000059999,
+        QuoteAssetVolume:         "493891.2345",
+        TradeNum:                 321,
+        TakerBuyBaseAssetVolume:  "6.100",
+        TakerBuyQuoteAssetVolume: "244040.1230",
+    }, klines[0])
+    volume, err := klines[0].QuoteAssetVolumeDecimal()
+    assert.NoError(err)
+    assert.Equal("493891.2345", volume.String())
+    values, err := klines[1].Parse()
+    assert.NoError(err)
+    assert.Equal(40018.8, values.Close)
+    assert.Equal(160070.5, values.TakerBuyQuoteAssetVolume)
+
+    _, err = c.NewKlinesService().Symbol("BTCUSDT").Interval("7m").Do(ctx)
+    assert.Error(err)
+
+    depth, err := c.NewDepthService().Symbol("BTCUSDT").Limit(3).Do(ctx)
+    assert.NoError(err)
+    assert.Equal([2]string{"40005.10", "0.512"}, depth.Bids[0])
+    assert.Equal([2]string{"40010.00", "0.700"}, depth.Asks[2])
+    asks, err := depth.AsksDecimal()
+    assert.NoError(err)
+    assert.Len(asks, 3)
+    assert.Equal("40010.00", asks[2].Price.String())
+    assert.Equal("0.700", asks[2].Quantity.String())
+    bids, err := depth.BidsDecimal()
+    assert.NoError(err)
+    assert.True(bids[0].Price.LessThan(asks[0].Price))
+    _, err = (&bitnut.Depth{Bids: [][2]string{{"1", "x"}}}).BidsDecimal()
+    assert.Error(err)
+
+    tickers, err := c.NewListSymbolTickerService().Symbol("BTCUSDT").Do(ctx)
+    assert.NoError(err)
+    assert.Len(tickers, 1)
+    assert.Equal("40005.20", tickers[0].LastPrice)
+    assert.Equal("60987654.32", tickers[0].QuoteVolume)
+    last, err := tickers[0].LastPriceDecimal()
+    assert.NoError(err)
+    assert.Equal("40005.20", last.String())
+
+    tickers, err = c.NewListSymbolTickerService().Do(ctx)
+    assert.NoError(err)
+    assert.Len(tickers, 2)
+    assert.Equal("ETHUSDT", tickers[1].Symbol)
+}
+
+func TestDecodeOrder(t *testing.T) {
+    assert := assert.New(t)
+    c := replayClient(t, "testdata/market_data.json")
+    ctx := context.Background()
+
+    order, err := c.NewGetOrderService().Symbol("BTCUSDT").OrderID("1508429001").Do(ctx)
+    assert.NoError(err)
+    assert.Equal(&bitnut.Order{
+        Symbol:           "BTCUSDT",
+        OrderID:          "1508429001",
+        ClientOrderID:    "a3f1c0de",
+        Price:            "39000.00",
+        OrigQuantity:     "0.0100",
+        ExecutedQuantity: "0.0040",
+        Status:           bitnut.OrderStatusTypeNew,
+        Side:             bitnut.SideTypeBuy,
+        Time:             1650000100123,
+        UpdateTime:       1650000200456,
+    }, order)
+    orig, err := order.OrigQuantityDecimal()
+    assert.NoError(err)
+    executed, err := order.ExecutedQuantityDecimal()
+    assert.NoError(err)
+    assert.Equal("0.0060", orig.Sub(executed).String())
+
+    _, err = c.NewGetOrderService().Symbol("BTCUSDT").OrderID("1").Do(ctx)
+    assert.True(errors.Is(err, common.ErrUnknownOrder))
+}
+
+func TestDecodeTrades(t *testing.T) {
+    assert := assert.New(t)
+    c := replayClient(t, "testdata/market_data.json")
+    ctx := context.Background()
+
+    trades, err := c.NewRecentTradesService().Symbol("BTCUSDT").Limit(2).Do(ctx)
+    assert.NoError(err)
+    assert.Len(trades, 2)
+    assert.Equal(&bitnut.Trade{ID: 28457, Price: "40005.10", Quantity: "0.012", QuoteQuantity: "480.0612", Time: 1650000030100, IsBuyerMaker: true}, trades[0])
+
+    trades, err = c.NewHistoricalTradesService().Symbol("BTCUSDT").FromID(28000).Limit(1).Do(ctx)
+    assert.NoError(err)
+    assert.Len(trades, 1)
+    assert.Equal(int64(28000), trades[0].ID)
+
+    aggTrades, err := c.NewAggTradesService().Symbol("BTCUSDT").FromID(1200).Limit(1).Do(ctx)
+    assert.NoError(err)
+    assert.Equal([]*bitnut.AggTrade{{
+        AggTradeID: 1200, Price: "40005.10", Quantity: "0.112", FirstTradeID: 28457, LastTradeID: 28458, Timestamp: 1650000031250, IsBuyerMaker: true,
+    }}, aggTrades)
+
+    fills, err := c.NewListTradesService().Symbol("BTCUSDT").OrderID("1508429001").Do(ctx)
+    assert.NoError(err)
+    assert.Equal([]*bitnut.AccountTrade{{
+        Symbol:          "BTCUSDT",
+        ID:              28459,
+        OrderID:         "1508429001",
+        Price:           "39000.00",
+        Quantity:        "0.0040",
+        QuoteQuantity:   "156.000000",
+        Commission:      "0.156",
+        CommissionAsset: "USDT",
+        Time:            1650000200456,
+        IsBuyer:         true,
+        IsMaker:         true,
+    }}, fills)
+    commission, err := fills[0].CommissionDecimal()
+    assert.NoError(err)
+    assert.Equal("0.156", commission.String())
+}
+
+func TestDecodeExchangeInfo(t *testing.T) {
+    assert := assert.New(t)
+    c := replayClient(t, "testdata/market_data.json")
+
+    info, err := c.NewExchangeInfoService().Symbol("BTCUSDT").Do(context.Background())
+    assert.NoError(err)
+    assert.Nil(info.Symbol("ETHUSDT"))
+    symbol := info.Symbol("BTCUSDT")
+    assert.NotNil(symbol)
+    assert.True(symbol.IsTrading())
+    assert.Equal("BTC", symbol.BaseAsset)
+    assert.Equal(6, symbol.QuantityPrecision)
+    assert.Equal("0.01", symbol.TickSize())
+    assert.Equal("0.000010", symbol.StepSize())
+    assert.Equal("0.000010", symbol.MinQuantity())
+    assert.Equal("9000.000000", symbol.MaxQuantity())
+    assert.Equal("5.00", symbol.MinNotional())
+    tick, err := symbol.TickSizeDecimal()
+    assert.NoError(err)
+    price := common.MustParseDecimal("40005.237").RoundToStep(tick, common.RoundDown)
+    assert.Equal("40005.23", price.String())
+    assert.Equal(&bitnut.MinNotionalFilter{MinNotional: "5.00", ApplyToMarket: true, AvgPriceInterval: 5}, symbol.Filters.MinNotional)
+    assert.Nil(symbol.Filters.MarketLotSize)
+    assert.Len(symbol.Filters.Other, 1)
+    assert.Equal("MAX_NUM_ORDERS", symbol.Filters.Other[0]["filterType"])
+}
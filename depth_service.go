@@ -2,7 +2,10 @@ package bitnut
 
 import (
     "context"
+    "fmt"
     "net/http"
+
+    "github.com/hardyzp/bitnut/common"
 )
 
 // DepthService show depth info
@@ -38,18 +41,19 @@ func (s *DepthService) Do(ctx context.Context, opts ...RequestOption) (depth *De
     if err != nil {
         return nil, err
     }
-    var res DepthResponse
-    err = json.Unmarshal(data, &res)
+    depth = new(Depth)
+    _, _, err = decodeResponse(data, depth)
     if err != nil {
         return nil, err
     }
-    return &res.Data, nil
+    return depth, nil
 }
 
 // DepthResponse define depth info with bids and asks
 type Depth struct {
-    Bids [][2]string `json:"bids"`
-    Asks [][2]string `json:"asks"`
+    LastUpdateID int64       `json:"lastUpdateId"`
+    Bids         [][2]string `json:"bids"`
+    Asks         [][2]string `json:"asks"`
 }
 
 type DepthResponse struct {
@@ -57,3 +61,25 @@ type DepthResponse struct {
     Msg  string `json:"msg"`
     Data Depth  `json:"data"`
 }
+
+// BidsDecimal return the bids as Decimal price levels
+func (d *Depth) BidsDecimal() ([]common.DecimalLevel, error) {
+    return decimalLevels("bid", d.Bids)
+}
+
+// AsksDecimal return the asks as Decimal price levels
+func (d *Depth) AsksDecimal() ([]common.DecimalLevel, error) {
+    return decimalLevels("ask", d.Asks)
+}
+
+func decimalLevels(side string, levels [][2]string) ([]common.DecimalLevel, error) {
+    res := make([]common.DecimalLevel, 0, len(levels))
+    for _, l := range levels {
+        level, err := (&common.PriceLevel{Price: l[0], Quantity: l[1]}).Decimal()
+        if err != nil {
+            return nil, fmt.Errorf("bitnut: invalid %s level: %w", side, err)
+        }
+        res = append(res, level)
+    }
+    return res, nil
+}
@@ -0,0 +1,111 @@
+package bitnut
+
+import (
+    "errors"
+    "net/url"
+    "strings"
+)
+
+// ErrMainnetTradingDisabled is returned for order requests against mainnet
+// when the client has not opted in with AllowMainnetTrading
+var ErrMainnetTradingDisabled = errors.New("bitnut: order requests against mainnet are disabled, set Client.AllowMainnetTrading to enable them")
+
+// Environment define the endpoints of an exchange deployment
+type Environment struct {
+    Name string
+    // BaseURL is the REST API base URL
+    BaseURL string
+    // StreamURL is the market data stream URL
+    StreamURL string
+    // UserStreamURL is the private user data stream URL
+    UserStreamURL string
+    // Mainnet reports whether orders placed in this environment move real funds
+    Mainnet bool
+}
+
+// Environments of the exchange
+var (
+    EnvironmentMainnet = Environment{
+        Name:          "mainnet",
+        BaseURL:       baseAPIMainURL,
+        StreamURL:     baseStreamMainURL,
+        UserStreamURL: baseUserStreamMainURL,
+        Mainnet:       true,
+    }
+    EnvironmentTestnet = Environment{
+        Name:          "testnet",
+        BaseURL:       baseAPITestnetURL,
+        StreamURL:     baseStreamTestnetURL,
+        UserStreamURL: baseUserStreamTestnetURL,
+    }
+)
+
+// CustomEnvironment define an environment served from custom URLs, such as a
+// local mock of the exchange. Custom environments are not flagged mainnet,
+// but requests sent to the mainnet URL are guarded all the same.
+func CustomEnvironment(name, baseURL, streamURL string) Environment {
+    return Environment{
+        Name:          name,
+        BaseURL:       baseURL,
+        StreamURL:     streamURL,
+        UserStreamURL: streamURL,
+    }
+}
+
+// defaultEnvironment return the environment selected by UseTestnet
+func defaultEnvironment() Environment {
+    if UseTestnet {
+        return EnvironmentTestnet
+    }
+    return EnvironmentMainnet
+}
+
+// SetEnvironment switch the client to env
+func (c *Client) SetEnvironment(env Environment) *Client {
+    c.Environment = env
+    c.BaseURL = env.BaseURL
+    return c
+}
+
+// environmentForURL return the environment serving baseURL, current when
+// the URL is not one of the known environments. baseURL must be normalized
+// with trimBaseURL.
+func environmentForURL(baseURL string, current Environment) Environment {
+    for _, env := range []Environment{EnvironmentMainnet, EnvironmentTestnet} {
+        if baseURL == env.BaseURL {
+            return env
+        }
+    }
+    if current.BaseURL == baseURL {
+        return current
+    }
+    env := CustomEnvironment("custom", baseURL, current.StreamURL)
+    env.UserStreamURL = current.UserStreamURL
+    return env
+}
+
+// trimBaseURL drop the trailing slash of baseURL, endpoints are appended
+// with their leading one
+func trimBaseURL(baseURL string) string {
+    return strings.TrimSuffix(baseURL, "/")
+}
+
+// isMainnetURL report whether baseURL points at the mainnet REST API
+func isMainnetURL(baseURL string) bool {
+    u, err := url.Parse(baseURL)
+    if err != nil {
+        return false
+    }
+    mainnet, _ := url.Parse(EnvironmentMainnet.BaseURL)
+    return strings.EqualFold(u.Host, mainnet.Host)
+}
+
+// sendsToMainnet report whether requests of the client reach mainnet. It is
+// judged from the URL requests are sent to, an environment flagged Mainnet
+// only counts while its own base URL is in use.
+func (c *Client) sendsToMainnet() bool {
+    if isMainnetURL(c.BaseURL) {
+        return true
+    }
+    return c.Environment.Mainnet && c.BaseURL == c.Environment.BaseURL
+}
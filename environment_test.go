@@ -0,0 +1,66 @@
+package bitnut
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestMainnetTradingGuard(t *testing.T) {
+    assert := assert.New(t)
+    calls := 0
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        calls++
+        return jsonResponse(`{"code":0,"msg":"","data":[]}`), nil
+    })
+    c.SetEnvironment(EnvironmentMainnet)
+    assert.Equal(baseAPIMainURL, c.BaseURL)
+
+    _, err := c.NewCancelOpenOrdersService().Symbol("BTCUSDT").Do(context.Background())
+    assert.True(errors.Is(err, ErrMainnetTradingDisabled))
+    assert.Equal(0, calls)
+
+    // read-only signed requests are not guarded
+    _, err = c.NewListOrdersService().Symbol("BTCUSDT").Do(context.Background())
+    assert.NoError(err)
+    assert.Equal(1, calls)
+
+    c.AllowMainnetTrading = true
+    _, err = c.NewCancelOpenOrdersService().Symbol("BTCUSDT").Do(context.Background())
+    assert.NoError(err)
+    assert.Equal(2, calls)
+
+    // the guard follows the URL requests are sent to, not the environment
+    c.AllowMainnetTrading = false
+    c.SetApiEndpoint(baseAPITestnetURL)
+    _, err = c.NewCancelOpenOrdersService().Symbol("BTCUSDT").Do(context.Background())
+    assert.NoError(err)
+    assert.Equal(3, calls)
+
+    c.SetEnvironment(CustomEnvironment("proxy", "https://test.local", ""))
+    c.SetApiEndpoint(baseAPIMainURL + "/")
+    assert.Equal(baseAPIMainURL, c.BaseURL)
+    assert.Equal(EnvironmentMainnet, c.Environment)
+    _, err = c.NewCancelOpenOrdersService().Symbol("BTCUSDT").Do(context.Background())
+    assert.True(errors.Is(err, ErrMainnetTradingDisabled))
+
+    c.SetEnvironment(CustomEnvironment("mainnet", baseAPIMainURL, ""))
+    _, err = c.NewCancelOpenOrdersService().Symbol("BTCUSDT").Do(context.Background())
+    assert.True(errors.Is(err, ErrMainnetTradingDisabled))
+    assert.Equal(3, calls)
+
+    testnet := NewClient("key", "secret").SetEnvironment(EnvironmentTestnet)
+    assert.Equal(baseAPITestnetURL, testnet.BaseURL)
+    assert.False(testnet.Environment.Mainnet)
+
+    custom := NewClient("key", "secret").SetApiEndpoint("https://test.local/")
+    assert.Equal("https://test.local", custom.BaseURL)
+    assert.Equal("https://test.local", custom.Environment.BaseURL)
+    custom, err = New("key", "secret", WithBaseURL(baseAPITestnetURL+"/"))
+    assert.NoError(err)
+    assert.Equal(baseAPITestnetURL, custom.BaseURL)
+    assert.Equal(EnvironmentTestnet, custom.Environment)
+}
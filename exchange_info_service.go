@@ -0,0 +1,297 @@
+package bitnut
+
+import (
+    "context"
+    "net/http"
+
+    "github.com/hardyzp/bitnut/common"
+    jsoniter "github.com/json-iterator/go"
+)
+
+// ExchangeInfoService exchange info service
+type ExchangeInfoService struct {
+    c       *Client
+    symbol  *string
+    symbols []string
+}
+
+// Symbol set symbol
+func (s *ExchangeInfoService) Symbol(symbol string) *ExchangeInfoService {
+    s.symbol = &symbol
+    return s
+}
+
+// Symbols set symbols
+func (s *ExchangeInfoService) Symbols(symbols ...string) *ExchangeInfoService {
+    s.symbols = symbols
+    return s
+}
+
+// Do send request
+func (s *ExchangeInfoService) Do(ctx context.Context, opts ...RequestOption) (res *ExchangeInfo, err error) {
+    r := &request{
+        method:   http.MethodGet,
+        endpoint: "/v1/exchangeInfo",
+    }
+    if s.symbol != nil {
+        r.setParam("symbol", *s.symbol)
+    } else if len(s.symbols) > 0 {
+        r.setParam("symbols", s.symbols)
+    }
+    data, err := s.c.callAPI(ctx, r, opts...)
+    if err != nil {
+        return nil, err
+    }
+    res = new(ExchangeInfo)
+    _, _, err = decodeResponse(data, res)
+    if err != nil {
+        return nil, err
+    }
+    return res, nil
+}
+
+// ExchangeInfo define exchange info
+type ExchangeInfo struct {
+    Timezone   string   `json:"timezone"`
+    ServerTime int64    `json:"serverTime"`
+    Symbols    []Symbol `json:"symbols"`
+}
+
+// Symbol return the metadata of a symbol, or nil if it is not listed
+func (e *ExchangeInfo) Symbol(symbol string) *Symbol {
+    for i := range e.Symbols {
+        if e.Symbols[i].Symbol == symbol {
+            return &e.Symbols[i]
+        }
+    }
+    return nil
+}
+
+// Symbol market symbol
+type Symbol struct {
+    Symbol            string           `json:"symbol"`
+    Status            SymbolStatusType `json:"status"`
+    BaseAsset         string           `json:"baseAsset"`
+    QuoteAsset        string           `json:"quoteAsset"`
+    PricePrecision    int              `json:"pricePrecision"`
+    QuantityPrecision int              `json:"quantityPrecision"`
+    Filters           SymbolFilters    `json:"filters"`
+}
+
+// IsTrading report whether orders can be placed on the symbol
+func (s *Symbol) IsTrading() bool {
+    return s.Status == SymbolStatusTypeTrading
+}
+
+// SymbolFilters define the typed trading rules of a symbol. A filter the
+// exchange does not send is nil, filters unknown to the SDK are kept in Other.
+type SymbolFilters struct {
+    Price         *PriceFilter
+    LotSize       *LotSizeFilter
+    MarketLotSize *LotSizeFilter
+    MinNotional   *MinNotionalFilter
+    Other         []map[string]interface{}
+}
+
+// TickSizeDecimal return the price step of the symbol as a Decimal, zero when unknown
+func (s *Symbol) TickSizeDecimal() (common.Decimal, error) {
+    return parseDecimal("tickSize", s.TickSize())
+}
+
+// StepSizeDecimal return the quantity step of the symbol as a Decimal, zero when unknown
+func (s *Symbol) StepSizeDecimal() (common.Decimal, error) {
+    return parseDecimal("stepSize", s.StepSize())
+}
+
+// MinQuantityDecimal return the minimum order quantity of the symbol as a Decimal, zero when unknown
+func (s *Symbol) MinQuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("minQuantity", s.MinQuantity())
+}
+
+// MaxQuantityDecimal return the maximum order quantity of the symbol as a Decimal, zero when unknown
+func (s *Symbol) MaxQuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("maxQuantity", s.MaxQuantity())
+}
+
+// MinNotionalDecimal return the minimum order value of the symbol as a Decimal, zero when unknown
+func (s *Symbol) MinNotionalDecimal() (common.Decimal, error) {
+    return parseDecimal("minNotional", s.MinNotional())
+}
+
+// PriceFilter define price rules of symbol
+type PriceFilter struct {
+    MinPrice string `json:"minPrice"`
+    MaxPrice string `json:"maxPrice"`
+    TickSize string `json:"tickSize"`
+}
+
+// LotSizeFilter define lot size rules of symbol
+type LotSizeFilter struct {
+    MinQuantity string `json:"minQty"`
+    MaxQuantity string `json:"maxQty"`
+    StepSize    string `json:"stepSize"`
+}
+
+// MinNotionalFilter define min notional rules of symbol
+type MinNotionalFilter struct {
+    MinNotional      string `json:"minNotional"`
+    ApplyToMarket    bool   `json:"applyToMarket"`
+    AvgPriceInterval int    `json:"avgPriceMins"`
+}
+
+// UnmarshalJSON decode the filter list of a symbol into typed filters
+func (f *SymbolFilters) UnmarshalJSON(data []byte) error {
+    var raw []jsoniter.RawMessage
+    err := json.Unmarshal(data, &raw)
+    if err != nil {
+        return err
+    }
+    *f = SymbolFilters{}
+    for _, item := range raw {
+        var head struct {
+            FilterType SymbolFilterType `json:"filterType"`
+        }
+        err = json.Unmarshal(item, &head)
+        if err != nil {
+            return err
+        }
+        var target interface{}
+        switch head.FilterType {
+        case SymbolFilterTypePrice:
+            f.Price = new(PriceFilter)
+            target = f.Price
+        case SymbolFilterTypeLotSize:
+            f.LotSize = new(LotSizeFilter)
+            target = f.LotSize
+        case SymbolFilterTypeMarketLotSize:
+            f.MarketLotSize = new(LotSizeFilter)
+            target = f.MarketLotSize
+        case SymbolFilterTypeMinNotional:
+            f.MinNotional = new(MinNotionalFilter)
+            target = f.MinNotional
+        default:
+            m := map[string]interface{}{}
+            f.Other = append(f.Other, m)
+            target = &m
+        }
+        err = json.Unmarshal(item, target)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// MarshalJSON encode the filters back into the exchange list form
+func (f SymbolFilters) MarshalJSON() ([]byte, error) {
+    list := make([]interface{}, 0, 4+len(f.Other))
+    add := func(filterType SymbolFilterType, filter interface{}) error {
+        data, err := json.Marshal(filter)
+        if err != nil {
+            return err
+        }
+        m := map[string]interface{}{}
+        err = json.Unmarshal(data, &m)
+        if err != nil {
+            return err
+        }
+        m["filterType"] = filterType
+        list = append(list, m)
+        return nil
+    }
+    var err error
+    if f.Price != nil {
+        err = add(SymbolFilterTypePrice, f.Price)
+    }
+    if err == nil && f.LotSize != nil {
+        err = add(SymbolFilterTypeLotSize, f.LotSize)
+    }
+    if err == nil && f.MarketLotSize != nil {
+        err = add(SymbolFilterTypeMarketLotSize, f.MarketLotSize)
+    }
+    if err == nil && f.MinNotional != nil {
+        err = add(SymbolFilterTypeMinNotional, f.MinNotional)
+    }
+    if err != nil {
+        return nil, err
+    }
+    for _, m := range f.Other {
+        list = append(list, m)
+    }
+    return json.Marshal(list)
+}
+
+// TickSize return the price step of the symbol, empty when unknown
+func (s *Symbol) TickSize() string {
+    if s.Filters.Price == nil {
+        return ""
+    }
+    return s.Filters.Price.TickSize
+}
+
+// StepSize return the quantity step of the symbol, empty when unknown
+func (s *Symbol) StepSize() string {
+    if s.Filters.LotSize == nil {
+        return ""
+    }
+    return s.Filters.LotSize.StepSize
+}
+
+// MinQuantity return the minimum order quantity, empty when unknown
+func (s *Symbol) MinQuantity() string {
+    if s.Filters.LotSize == nil {
+        return ""
+    }
+    return s.Filters.LotSize.MinQuantity
+}
+
+// MaxQuantity return the maximum order quantity, empty when unknown
+func (s *Symbol) MaxQuantity() string {
+    if s.Filters.LotSize == nil {
+        return ""
+    }
+    return s.Filters.LotSize.MaxQuantity
+}
+
+// MinNotional return the minimum order value in quote asset, empty when unknown
+func (s *Symbol) MinNotional() string {
+    if s.Filters.MinNotional == nil {
+        return ""
+    }
+    return s.Filters.MinNotional.MinNotional
+}
+
+// MinPriceDecimal return min price as a Decimal, empty parses as zero
+func (f *PriceFilter) MinPriceDecimal() (common.Decimal, error) {
+    return parseDecimal("minPrice", f.MinPrice)
+}
+
+// MaxPriceDecimal return max price as a Decimal, empty parses as zero
+func (f *PriceFilter) MaxPriceDecimal() (common.Decimal, error) {
+    return parseDecimal("maxPrice", f.MaxPrice)
+}
+
+// TickSizeDecimal return tick size as a Decimal, empty parses as zero
+func (f *PriceFilter) TickSizeDecimal() (common.Decimal, error) {
+    return parseDecimal("tickSize", f.TickSize)
+}
+
+// MinQuantityDecimal return min quantity as a Decimal, empty parses as zero
+func (f *LotSizeFilter) MinQuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("minQty", f.MinQuantity)
+}
+
+// MaxQuantityDecimal return max quantity as a Decimal, empty parses as zero
+func (f *LotSizeFilter) MaxQuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("maxQty", f.MaxQuantity)
+}
+
+// StepSizeDecimal return step size as a Decimal, empty parses as zero
+func (f *LotSizeFilter) StepSizeDecimal() (common.Decimal, error) {
+    return parseDecimal("stepSize", f.StepSize)
+}
+
+// MinNotionalDecimal return min notional as a Decimal, empty parses as zero
+func (f *MinNotionalFilter) MinNotionalDecimal() (common.Decimal, error) {
+    return parseDecimal("minNotional", f.MinNotional)
+}
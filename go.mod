@@ -4,6 +4,7 @@ go 1.18
 
 require (
 	github.com/bitly/go-simplejson v0.5.1
+	github.com/gorilla/websocket v1.5.0
 	github.com/json-iterator/go v1.1.12
 	github.com/stretchr/testify v1.8.4
 )
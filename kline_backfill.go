@@ -0,0 +1,176 @@
+package bitnut
+
+import (
+    "context"
+    "errors"
+    "time"
+)
+
+// defaultBackfillPageLimit is the page size requested by KlinesBackfillService
+const defaultBackfillPageLimit = 1000
+
+// errStopBackfill end the backfill started by Stream when its consumer is gone
+var errStopBackfill = errors.New("bitnut: backfill stopped")
+
+// KlineGap define a range of missing klines, by open time in milliseconds:
+// the klines opening in [From, To) were expected but not returned
+type KlineGap struct {
+    From int64
+    To   int64
+}
+
+// KlinesBackfillService fetch every kline of a symbol over [from, to),
+// paging forward with KlinesService. Each request goes through the client
+// rate limiter.
+type KlinesBackfillService struct {
+    c         *Client
+    symbol    string
+    interval  KlineInterval
+    from      time.Time
+    to        time.Time
+    after     *int64
+    pageLimit int
+    onGap     func(KlineGap)
+}
+
+// NewKlinesBackfillService init klines backfill service
+func (c *Client) NewKlinesBackfillService() *KlinesBackfillService {
+    return &KlinesBackfillService{c: c, pageLimit: defaultBackfillPageLimit}
+}
+
+// Symbol set symbol
+func (s *KlinesBackfillService) Symbol(symbol string) *KlinesBackfillService {
+    s.symbol = symbol
+    return s
+}
+
+// Interval set interval
+func (s *KlinesBackfillService) Interval(interval KlineInterval) *KlinesBackfillService {
+    s.interval = interval
+    return s
+}
+
+// Range set the time range, klines opening in [from, to) are fetched
+func (s *KlinesBackfillService) Range(from, to time.Time) *KlinesBackfillService {
+    s.from = from
+    s.to = to
+    return s
+}
+
+// ResumeAfter skip every kline up to and including the one opening at
+// openTime, typically the last one received before an interruption
+func (s *KlinesBackfillService) ResumeAfter(openTime int64) *KlinesBackfillService {
+    s.after = &openTime
+    return s
+}
+
+// PageLimit set the number of klines requested per page
+func (s *KlinesBackfillService) PageLimit(limit int) *KlinesBackfillService {
+    s.pageLimit = limit
+    return s
+}
+
+// OnGap set a callback receiving the ranges the exchange returned no kline
+// for. Missing klines after the last one are reported up to the current
+// kline, which may not exist yet.
+func (s *KlinesBackfillService) OnGap(f func(KlineGap)) *KlinesBackfillService {
+    s.onGap = f
+    return s
+}
+
+// Do fetch the klines in open time order and pass each of them to fn.
+// Klines repeated across page boundaries are passed once. It stop at the
+// first error, from the API or from fn.
+func (s *KlinesBackfillService) Do(ctx context.Context, fn func(*Kline) error, opts ...RequestOption) error {
+    err := s.interval.Validate()
+    if err != nil {
+        return err
+    }
+    if s.symbol == "" {
+        return errors.New("bitnut: backfill symbol is required")
+    }
+    if !s.to.After(s.from) {
+        return errors.New("bitnut: backfill range is empty")
+    }
+    cursor := FormatTimestamp(s.from)
+    end := FormatTimestamp(s.to)
+    expected := FormatTimestamp(s.interval.Start(s.from))
+    if expected < cursor {
+        expected = FormatTimestamp(s.interval.Next(s.interval.Start(s.from)))
+    }
+    if s.after != nil && *s.after >= cursor {
+        cursor = *s.after + 1
+        expected = s.nextOpenTime(*s.after)
+    }
+    for cursor < end {
+        page, err := s.c.NewKlinesService().
+            Symbol(s.symbol).
+            Interval(s.interval).
+            StartTime(cursor).
+            EndTime(end-1).
+            Limit(s.pageLimit).
+            Do(ctx, opts...)
+        if err != nil {
+            return err
+        }
+        progressed := false
+        for _, k := range page {
+            if k.OpenTime < cursor || k.OpenTime >= end {
+                continue
+            }
+            if k.OpenTime > expected && s.onGap != nil {
+                s.onGap(KlineGap{From: expected, To: k.OpenTime})
+            }
+            err = fn(k)
+            if err != nil {
+                return err
+            }
+            cursor = k.OpenTime + 1
+            expected = s.nextOpenTime(k.OpenTime)
+            progressed = true
+        }
+        if !progressed {
+            break
+        }
+    }
+    last := FormatTimestamp(s.interval.Start(time.Now()))
+    if end < last {
+        last = end
+    }
+    if expected < last && s.onGap != nil {
+        s.onGap(KlineGap{From: expected, To: last})
+    }
+    return nil
+}
+
+// Stream run the backfill in the background and deliver the klines on the
+// returned channel, which is closed at the end. The error channel receives
+// at most one error and is closed after the kline channel.
+func (s *KlinesBackfillService) Stream(ctx context.Context, opts ...RequestOption) (<-chan *Kline, <-chan error) {
+    klines := make(chan *Kline)
+    errs := make(chan error, 1)
+    go func() {
+        defer close(errs)
+        defer close(klines)
+        err := s.Do(ctx, func(k *Kline) error {
+            select {
+            case klines <- k:
+                return nil
+            case <-ctx.Done():
+                return errStopBackfill
+            }
+        }, opts...)
+        if errors.Is(err, errStopBackfill) {
+            err = ctx.Err()
+        }
+        if err != nil {
+            errs <- err
+        }
+    }()
+    return klines, errs
+}
+
+func (s *KlinesBackfillService) nextOpenTime(openTime int64) int64 {
+    start := time.Unix(0, openTime*int64(time.Millisecond)).UTC()
+    return FormatTimestamp(s.interval.Next(start))
+}
@@ -0,0 +1,104 @@
+package bitnut_test
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/hardyzp/bitnut"
+    "github.com/hardyzp/bitnut/bitnuttest"
+    "github.com/stretchr/testify/assert"
+)
+
+func minuteKlines(start time.Time, minutes ...int) []*bitnut.Kline {
+    res := make([]*bitnut.Kline, 0, len(minutes))
+    for _, m := range minutes {
+        open := bitnut.FormatTimestamp(start.Add(time.Duration(m) * time.Minute))
+        res = append(res, &bitnut.Kline{
+            OpenTime:  open,
+            Open:      "1",
+            High:      "1",
+            Low:       "1",
+            Close:     "1",
+            Volume:    "1",
+            CloseTime: open + 59999,
+        })
+    }
+    return res
+}
+
+func TestKlinesBackfill(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+    srv.SetKlines("BTCUSDT", bitnut.KlineInterval1m, minuteKlines(start, 0, 1, 2, 3, 6, 7, 8, 9, 10, 11))
+    c := srv.Client()
+
+    var opens []int64
+    var gaps []bitnut.KlineGap
+    backfill := c.NewKlinesBackfillService().
+        Symbol("BTCUSDT").
+        Interval(bitnut.KlineInterval1m).
+        Range(start, start.Add(10*time.Minute)).
+        PageLimit(3).
+        OnGap(func(g bitnut.KlineGap) { gaps = append(gaps, g) })
+    err := backfill.Do(context.Background(), func(k *bitnut.Kline) error {
+        opens = append(opens, (k.OpenTime-bitnut.FormatTimestamp(start))/60000)
+        return nil
+    })
+    assert.NoError(err)
+    assert.Equal([]int64{0, 1, 2, 3, 6, 7, 8, 9}, opens)
+    assert.Equal([]bitnut.KlineGap{{
+        From: bitnut.FormatTimestamp(start.Add(4 * time.Minute)),
+        To:   bitnut.FormatTimestamp(start.Add(6 * time.Minute)),
+    }}, gaps)
+    assert.Equal(4, srv.Requests("/v1/tick/kline"))
+
+    // stop half way, then resume after the last kline received
+    errStop := errors.New("stop")
+    var last int64
+    err = c.NewKlinesBackfillService().Symbol("BTCUSDT").Interval(bitnut.KlineInterval1m).
+        Range(start, start.Add(12*time.Minute)).PageLimit(4).
+        Do(context.Background(), func(k *bitnut.Kline) error {
+            if k.OpenTime >= bitnut.FormatTimestamp(start.Add(3*time.Minute)) {
+                return errStop
+            }
+            last = k.OpenTime
+            return nil
+        })
+    assert.Equal(errStop, err)
+    klines, errs := c.NewKlinesBackfillService().Symbol("BTCUSDT").Interval(bitnut.KlineInterval1m).
+        Range(start, start.Add(12*time.Minute)).PageLimit(4).ResumeAfter(last).
+        Stream(context.Background())
+    opens = nil
+    for k := range klines {
+        opens = append(opens, (k.OpenTime-bitnut.FormatTimestamp(start))/60000)
+    }
+    assert.NoError(<-errs)
+    assert.Equal([]int64{3, 6, 7, 8, 9, 10, 11}, opens)
+
+    // klines missing at the end are gaps too, up to the current kline
+    gaps = nil
+    err = c.NewKlinesBackfillService().Symbol("BTCUSDT").Interval(bitnut.KlineInterval1m).
+        Range(start.Add(10*time.Minute), start.Add(14*time.Minute)).
+        OnGap(func(g bitnut.KlineGap) { gaps = append(gaps, g) }).
+        Do(context.Background(), func(k *bitnut.Kline) error { return nil })
+    assert.NoError(err)
+    assert.Equal([]bitnut.KlineGap{{
+        From: bitnut.FormatTimestamp(start.Add(12 * time.Minute)),
+        To:   bitnut.FormatTimestamp(start.Add(14 * time.Minute)),
+    }}, gaps)
+    now := bitnut.KlineInterval1m.Start(time.Now())
+    gaps = nil
+    err = c.NewKlinesBackfillService().Symbol("BTCUSDT").Interval(bitnut.KlineInterval1m).
+        Range(now.Add(-2*time.Minute), now.Add(time.Hour)).
+        OnGap(func(g bitnut.KlineGap) { gaps = append(gaps, g) }).
+        Do(context.Background(), func(k *bitnut.Kline) error { return nil })
+    assert.NoError(err)
+    assert.Len(gaps, 1)
+    assert.Equal(bitnut.FormatTimestamp(now.Add(-2*time.Minute)), gaps[0].From)
+    assert.True(gaps[0].To >= bitnut.FormatTimestamp(now), gaps[0].To)
+    assert.True(gaps[0].To <= bitnut.FormatTimestamp(now.Add(time.Minute)), gaps[0].To)
+}
@@ -0,0 +1,116 @@
+package bitnut
+
+import (
+    "fmt"
+    "time"
+)
+
+// KlineInterval define kline interval
+type KlineInterval string
+
+// Kline intervals
+const (
+    KlineInterval1m  KlineInterval = "1m"
+    KlineInterval3m  KlineInterval = "3m"
+    KlineInterval5m  KlineInterval = "5m"
+    KlineInterval15m KlineInterval = "15m"
+    KlineInterval30m KlineInterval = "30m"
+    KlineInterval1h  KlineInterval = "1h"
+    KlineInterval2h  KlineInterval = "2h"
+    KlineInterval4h  KlineInterval = "4h"
+    KlineInterval6h  KlineInterval = "6h"
+    KlineInterval8h  KlineInterval = "8h"
+    KlineInterval12h KlineInterval = "12h"
+    KlineInterval1d  KlineInterval = "1d"
+    KlineInterval3d  KlineInterval = "3d"
+    KlineInterval1w  KlineInterval = "1w"
+    KlineInterval1M  KlineInterval = "1M"
+)
+
+const oneDay = 24 * time.Hour
+
+var klineIntervalDurations = map[KlineInterval]time.Duration{
+    KlineInterval1m:  time.Minute,
+    KlineInterval3m:  3 * time.Minute,
+    KlineInterval5m:  5 * time.Minute,
+    KlineInterval15m: 15 * time.Minute,
+    KlineInterval30m: 30 * time.Minute,
+    KlineInterval1h:  time.Hour,
+    KlineInterval2h:  2 * time.Hour,
+    KlineInterval4h:  4 * time.Hour,
+    KlineInterval6h:  6 * time.Hour,
+    KlineInterval8h:  8 * time.Hour,
+    KlineInterval12h: 12 * time.Hour,
+    KlineInterval1d:  oneDay,
+    KlineInterval3d:  3 * oneDay,
+    KlineInterval1w:  7 * oneDay,
+    KlineInterval1M:  30 * oneDay,
+}
+
+// KlineIntervals return every supported interval, shortest first
+func KlineIntervals() []KlineInterval {
+    return []KlineInterval{
+        KlineInterval1m, KlineInterval3m, KlineInterval5m, KlineInterval15m, KlineInterval30m,
+        KlineInterval1h, KlineInterval2h, KlineInterval4h, KlineInterval6h, KlineInterval8h,
+        KlineInterval12h, KlineInterval1d, KlineInterval3d, KlineInterval1w, KlineInterval1M,
+    }
+}
+
+// ParseKlineInterval parse and validate an interval such as "5m"
+func ParseKlineInterval(s string) (KlineInterval, error) {
+    i := KlineInterval(s)
+    return i, i.Validate()
+}
+
+// Validate return an error if the interval is not supported by the exchange
+func (i KlineInterval) Validate() error {
+    if _, ok := klineIntervalDurations[i]; !ok {
+        return fmt.Errorf("bitnut: invalid kline interval %q", string(i))
+    }
+    return nil
+}
+
+// Duration return the length of the interval. Months are counted as 30
+// days, use Start and Next for calendar-exact month boundaries.
+func (i KlineInterval) Duration() time.Duration {
+    return klineIntervalDurations[i]
+}
+
+// Start return the open time of the kline containing t. Buckets are aligned
+// to UTC: days at midnight, weeks on Monday and months on the first day.
+func (i KlineInterval) Start(t time.Time) time.Time {
+    t = t.UTC()
+    switch i {
+    case KlineInterval1M:
+        return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+    case KlineInterval1w:
+        midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+        offset := (int(midnight.Weekday()) + 6) % 7
+        return midnight.AddDate(0, 0, -offset)
+    default:
+        // align on the Unix epoch, as the exchange does, time.Truncate
+        // aligns on the zero time which is off for 3d
+        secs := int64(i.Duration() / time.Second)
+        if secs <= 0 {
+            return t
+        }
+        return time.Unix(floorDiv(t.Unix(), secs)*secs, 0).UTC()
+    }
+}
+
+// floorDiv return a / b rounded towards negative infinity
+func floorDiv(a, b int64) int64 {
+    q := a / b
+    if a%b != 0 && (a < 0) != (b < 0) {
+        q--
+    }
+    return q
+}
+
+// Next return the open time of the kline following the one that opens at start
+func (i KlineInterval) Next(start time.Time) time.Time {
+    if i == KlineInterval1M {
+        return start.UTC().AddDate(0, 1, 0)
+    }
+    return start.Add(i.Duration())
+}
@@ -0,0 +1,46 @@
+package bitnut
+
+import (
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestKlineInterval(t *testing.T) {
+    assert := assert.New(t)
+    at := time.Date(2022, 3, 17, 13, 47, 12, 0, time.UTC) // a Thursday
+    tests := []struct {
+        interval KlineInterval
+        duration time.Duration
+        start    time.Time
+        next     time.Time
+    }{
+        {KlineInterval1m, time.Minute, time.Date(2022, 3, 17, 13, 47, 0, 0, time.UTC), time.Date(2022, 3, 17, 13, 48, 0, 0, time.UTC)},
+        {KlineInterval15m, 15 * time.Minute, time.Date(2022, 3, 17, 13, 45, 0, 0, time.UTC), time.Date(2022, 3, 17, 14, 0, 0, 0, time.UTC)},
+        {KlineInterval4h, 4 * time.Hour, time.Date(2022, 3, 17, 12, 0, 0, 0, time.UTC), time.Date(2022, 3, 17, 16, 0, 0, 0, time.UTC)},
+        {KlineInterval1d, 24 * time.Hour, time.Date(2022, 3, 17, 0, 0, 0, 0, time.UTC), time.Date(2022, 3, 18, 0, 0, 0, 0, time.UTC)},
+        {KlineInterval3d, 3 * 24 * time.Hour, time.Date(2022, 3, 17, 0, 0, 0, 0, time.UTC), time.Date(2022, 3, 20, 0, 0, 0, 0, time.UTC)},
+        {KlineInterval1w, 7 * 24 * time.Hour, time.Date(2022, 3, 14, 0, 0, 0, 0, time.UTC), time.Date(2022, 3, 21, 0, 0, 0, 0, time.UTC)},
+        {KlineInterval1M, 30 * 24 * time.Hour, time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+    }
+    for _, tt := range tests {
+        t.Run(string(tt.interval), func(t *testing.T) {
+            assert.NoError(tt.interval.Validate())
+            assert.Equal(tt.duration, tt.interval.Duration())
+            assert.Equal(tt.start, tt.interval.Start(at))
+            assert.Equal(tt.next, tt.interval.Next(tt.start))
+        })
+    }
+    // 3d buckets follow the Unix epoch, not the zero time
+    assert.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), KlineInterval3d.Start(time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)))
+    assert.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), KlineInterval3d.Start(time.Date(2022, 1, 3, 23, 59, 0, 0, time.UTC)))
+    assert.Equal(time.Date(1969, 12, 29, 0, 0, 0, 0, time.UTC), KlineInterval3d.Start(time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)))
+
+    _, err := ParseKlineInterval("2m")
+    assert.Error(err)
+    i, err := ParseKlineInterval("1h")
+    assert.NoError(err)
+    assert.Equal(KlineInterval1h, i)
+    assert.Len(KlineIntervals(), len(klineIntervalDurations))
+}
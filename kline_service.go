@@ -4,13 +4,18 @@ import (
     "context"
     "fmt"
     "net/http"
+    "strconv"
+    "time"
+
+    "github.com/hardyzp/bitnut/common"
+    jsoniter "github.com/json-iterator/go"
 )
 
 // KlinesService list klines
 type KlinesService struct {
     c         *Client
     symbol    string
-    interval  string
+    interval  KlineInterval
     limit     *int
     startTime *int64
     endTime   *int64
@@ -23,7 +28,7 @@ func (s *KlinesService) Symbol(symbol string) *KlinesService {
 }
 
 // Interval set interval
-func (s *KlinesService) Interval(interval string) *KlinesService {
+func (s *KlinesService) Interval(interval KlineInterval) *KlinesService {
     s.interval = interval
     return s
 }
@@ -48,6 +53,10 @@ func (s *KlinesService) EndTime(endTime int64) *KlinesService {
 
 // Do send request
 func (s *KlinesService) Do(ctx context.Context, opts ...RequestOption) (res []*Kline, err error) {
+    err = s.interval.Validate()
+    if err != nil {
+        return []*Kline{}, err
+    }
     r := &request{
         method:   http.MethodGet,
         endpoint: "/v1/tick/kline",
@@ -67,7 +76,12 @@ func (s *KlinesService) Do(ctx context.Context, opts ...RequestOption) (res []*K
     if err != nil {
         return []*Kline{}, err
     }
-    j, err := newJSON(data)
+    var raw jsoniter.RawMessage
+    _, _, err = decodeResponse(data, &raw)
+    if err != nil {
+        return []*Kline{}, err
+    }
+    j, err := newJSON(raw)
     if err != nil {
         return []*Kline{}, err
     }
@@ -80,14 +94,17 @@ func (s *KlinesService) Do(ctx context.Context, opts ...RequestOption) (res []*K
             return []*Kline{}, err
         }
         res[i] = &Kline{
-            OpenTime:  item.GetIndex(0).MustInt64(),
-            Open:      item.GetIndex(1).MustString(),
-            High:      item.GetIndex(2).MustString(),
-            Low:       item.GetIndex(3).MustString(),
-            Close:     item.GetIndex(4).MustString(),
-            Volume:    item.GetIndex(5).MustString(),
-            CloseTime: item.GetIndex(6).MustInt64(),
-            TradeNum:  item.GetIndex(8).MustInt64(),
+            OpenTime:                 item.GetIndex(0).MustInt64(),
+            Open:                     item.GetIndex(1).MustString(),
+            High:                     item.GetIndex(2).MustString(),
+            Low:                      item.GetIndex(3).MustString(),
+            Close:                    item.GetIndex(4).MustString(),
+            Volume:                   item.GetIndex(5).MustString(),
+            CloseTime:                item.GetIndex(6).MustInt64(),
+            QuoteAssetVolume:         item.GetIndex(7).MustString(),
+            TradeNum:                 item.GetIndex(8).MustInt64(),
+            TakerBuyBaseAssetVolume:  item.GetIndex(9).MustString(),
+            TakerBuyQuoteAssetVolume: item.GetIndex(10).MustString(),
         }
     }
     return res, nil
@@ -95,12 +112,109 @@ func (s *KlinesService) Do(ctx context.Context, opts ...RequestOption) (res []*K
 
 // Kline define kline info
 type Kline struct {
-    OpenTime  int64  `json:"openTime"`
-    Open      string `json:"open"`
-    High      string `json:"high"`
-    Low       string `json:"low"`
-    Close     string `json:"close"`
-    Volume    string `json:"volume"`
-    CloseTime int64  `json:"closeTime"`
-    TradeNum  int64  `json:"tradeNum"`
+    OpenTime                 int64  `json:"openTime"`
+    Open                     string `json:"open"`
+    High                     string `json:"high"`
+    Low                      string `json:"low"`
+    Close                    string `json:"close"`
+    Volume                   string `json:"volume"`
+    CloseTime                int64  `json:"closeTime"`
+    QuoteAssetVolume         string `json:"quoteAssetVolume"`
+    TradeNum                 int64  `json:"tradeNum"`
+    TakerBuyBaseAssetVolume  string `json:"takerBuyBaseAssetVolume"`
+    TakerBuyQuoteAssetVolume string `json:"takerBuyQuoteAssetVolume"`
+}
+
+// KlineValues define the numeric fields of a kline
+type KlineValues struct {
+    Open                     float64
+    High                     float64
+    Low                      float64
+    Close                    float64
+    Volume                   float64
+    QuoteAssetVolume         float64
+    TakerBuyBaseAssetVolume  float64
+    TakerBuyQuoteAssetVolume float64
+}
+
+// Parse parses the prices and volumes of the kline. Empty volumes parse
+// as zero, any other field that fails to parse is returned as an error.
+func (k *Kline) Parse() (*KlineValues, error) {
+    v := new(KlineValues)
+    fields := []struct {
+        name  string
+        raw   string
+        dst   *float64
+        empty bool
+    }{
+        {"open", k.Open, &v.Open, false},
+        {"high", k.High, &v.High, false},
+        {"low", k.Low, &v.Low, false},
+        {"close", k.Close, &v.Close, false},
+        {"volume", k.Volume, &v.Volume, true},
+        {"quoteAssetVolume", k.QuoteAssetVolume, &v.QuoteAssetVolume, true},
+        {"takerBuyBaseAssetVolume", k.TakerBuyBaseAssetVolume, &v.TakerBuyBaseAssetVolume, true},
+        {"takerBuyQuoteAssetVolume", k.TakerBuyQuoteAssetVolume, &v.TakerBuyQuoteAssetVolume, true},
+    }
+    for _, f := range fields {
+        if f.raw == "" && f.empty {
+            continue
+        }
+        n, err := strconv.ParseFloat(f.raw, 64)
+        if err != nil {
+            return nil, fmt.Errorf("invalid kline %s: %w", f.name, err)
+        }
+        *f.dst = n
+    }
+    return v, nil
+}
+
+// OpenAt return the open time of the kline
+func (k *Kline) OpenAt() time.Time {
+    return time.Unix(0, k.OpenTime*int64(time.Millisecond)).UTC()
+}
+
+// CloseAt return the close time of the kline
+func (k *Kline) CloseAt() time.Time {
+    return time.Unix(0, k.CloseTime*int64(time.Millisecond)).UTC()
+}
+
+// OpenDecimal return open as a Decimal, empty parses as zero
+func (k *Kline) OpenDecimal() (common.Decimal, error) {
+    return parseDecimal("open", k.Open)
+}
+
+// HighDecimal return high as a Decimal, empty parses as zero
+func (k *Kline) HighDecimal() (common.Decimal, error) {
+    return parseDecimal("high", k.High)
+}
+
+// LowDecimal return low as a Decimal, empty parses as zero
+func (k *Kline) LowDecimal() (common.Decimal, error) {
+    return parseDecimal("low", k.Low)
+}
+
+// CloseDecimal return close as a Decimal, empty parses as zero
+func (k *Kline) CloseDecimal() (common.Decimal, error) {
+    return parseDecimal("close", k.Close)
+}
+
+// VolumeDecimal return volume as a Decimal, empty parses as zero
+func (k *Kline) VolumeDecimal() (common.Decimal, error) {
+    return parseDecimal("volume", k.Volume)
+}
+
+// QuoteAssetVolumeDecimal return quote asset volume as a Decimal, empty parses as zero
+func (k *Kline) QuoteAssetVolumeDecimal() (common.Decimal, error) {
+    return parseDecimal("quoteAssetVolume", k.QuoteAssetVolume)
+}
+
+// TakerBuyBaseAssetVolumeDecimal return taker buy base asset volume as a Decimal, empty parses as zero
+func (k *Kline) TakerBuyBaseAssetVolumeDecimal() (common.Decimal, error) {
+    return parseDecimal("takerBuyBaseAssetVolume", k.TakerBuyBaseAssetVolume)
+}
+
+// TakerBuyQuoteAssetVolumeDecimal return taker buy quote asset volume as a Decimal, empty parses as zero
+func (k *Kline) TakerBuyQuoteAssetVolumeDecimal() (common.Decimal, error) {
+    return parseDecimal("takerBuyQuoteAssetVolume", k.TakerBuyQuoteAssetVolume)
 }
@@ -0,0 +1,337 @@
+package bitnut
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/hardyzp/bitnut/common"
+)
+
+const defaultLocalOrderBookLimit = 1000
+
+// ErrBookNotSynced is returned when reading a LocalOrderBook that is
+// waiting for a snapshot
+var ErrBookNotSynced = errors.New("bitnut: order book not synced")
+
+// ErrNoUpdateID is returned by WaitSynced when the depth snapshot has no
+// lastUpdateId, the diffs can't be applied to it and the book stops
+var ErrNoUpdateID = errors.New("bitnut: depth snapshot has no lastUpdateId, the book can't follow the diffs")
+
+// BookGapError is reported when a depth diff doesn't follow the last
+// applied update, the book is then resynced from a new snapshot
+type BookGapError struct {
+    Symbol       string
+    LastUpdateID int64
+    // FirstUpdateID is the first update of the diff received
+    FirstUpdateID int64
+}
+
+// Error return the missing update range
+func (e *BookGapError) Error() string {
+    return fmt.Sprintf("<BookGapError> symbol=%s, lastUpdateId=%d, firstUpdateId=%d", e.Symbol, e.LastUpdateID, e.FirstUpdateID)
+}
+
+// LocalOrderBook maintain an order book from a DepthService snapshot and
+// the depth diffs of a MarketStream. Diffs are buffered while the snapshot is
+// fetched, then applied in update id order. A missing update, including
+// those lost while the stream reconnects, triggers a resync from a new
+// snapshot.
+type LocalOrderBook struct {
+    c       *Client
+    stream  MarketStream
+    symbol  string
+    limit   int
+    backoff *RetryPolicy
+
+    mu           sync.RWMutex
+    book         *OrderBook
+    lastUpdateID int64
+    synced       bool
+    err          error
+    onError      func(error)
+    changes      chan int64
+    syncedCh     chan struct{}
+    syncedOnce   sync.Once
+    done         chan struct{}
+}
+
+// NewLocalOrderBook init a local order book of symbol fed by stream
+func (c *Client) NewLocalOrderBook(stream MarketStream, symbol string) *LocalOrderBook {
+    return &LocalOrderBook{
+        c:      c,
+        stream: stream,
+        symbol: symbol,
+        limit:  defaultLocalOrderBookLimit,
+        backoff: &RetryPolicy{
+            BaseDelay: 100 * time.Millisecond,
+            MaxDelay:  5 * time.Second,
+            Jitter:    0.2,
+        },
+        book:     NewOrderBook(nil, nil),
+        changes:  make(chan int64, 1),
+        syncedCh: make(chan struct{}),
+        done:     make(chan struct{}),
+    }
+}
+
+// Limit set the depth of the snapshots
+func (b *LocalOrderBook) Limit(limit int) *LocalOrderBook {
+    b.limit = limit
+    return b
+}
+
+// OnError set the handler of gaps and snapshot failures, gaps are reported
+// as *BookGapError
+func (b *LocalOrderBook) OnError(fn func(error)) *LocalOrderBook {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.onError = fn
+    return b
+}
+
+// Start subscribe to the depth diffs and maintain the book in the
+// background until ctx is done or the stream ends. The stream may be
+// connected before or after.
+func (b *LocalOrderBook) Start(ctx context.Context) error {
+    sub, err := b.stream.SubscribeDepth(b.symbol, nil)
+    if err != nil {
+        return err
+    }
+    go b.run(ctx, sub)
+    return nil
+}
+
+// Done return a channel closed when the book stopped being maintained
+func (b *LocalOrderBook) Done() <-chan struct{} {
+    return b.done
+}
+
+// WaitSynced wait until the first snapshot is applied, it fails with
+// ErrNoUpdateID when the snapshot can't be followed
+func (b *LocalOrderBook) WaitSynced(ctx context.Context) error {
+    select {
+    case <-b.syncedCh:
+        return nil
+    case <-b.done:
+        b.mu.RLock()
+        defer b.mu.RUnlock()
+        if b.err != nil {
+            return b.err
+        }
+        return ErrStreamClosed
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// Changes return a channel receiving the last update id after every change
+// of the book. Notifications are coalesced when the reader falls behind.
+func (b *LocalOrderBook) Changes() <-chan int64 {
+    return b.changes
+}
+
+// Book return a copy of the book
+func (b *LocalOrderBook) Book() (*OrderBook, error) {
+    var res *OrderBook
+    err := b.View(func(book *OrderBook, _ int64) {
+        res = &OrderBook{
+            Bids: append([]common.DecimalLevel(nil), book.Bids...),
+            Asks: append([]common.DecimalLevel(nil), book.Asks...),
+        }
+    })
+    return res, err
+}
+
+// View call fn with the book under a read lock, fn must not keep or modify
+// the book
+func (b *LocalOrderBook) View(fn func(book *OrderBook, lastUpdateID int64)) error {
+    b.mu.RLock()
+    defer b.mu.RUnlock()
+    if !b.synced {
+        return ErrBookNotSynced
+    }
+    fn(b.book, b.lastUpdateID)
+    return nil
+}
+
+// LastUpdateID return the id of the last update applied
+func (b *LocalOrderBook) LastUpdateID() int64 {
+    b.mu.RLock()
+    defer b.mu.RUnlock()
+    return b.lastUpdateID
+}
+
+func (b *LocalOrderBook) run(ctx context.Context, sub *Subscription) {
+    defer close(b.done)
+    defer func() {
+        b.mu.Lock()
+        b.synced = false
+        b.mu.Unlock()
+        _ = sub.Unsubscribe()
+    }()
+    events := sub.Events()
+    // failures count the snapshot failures and gaps in a row, every resync
+    // after one waits for a growing backoff
+    failures := 0
+    for {
+        if failures > 0 {
+            err := b.backoff.wait(ctx, failures)
+            if err != nil {
+                return
+            }
+        }
+        err := b.resync(ctx)
+        if err != nil {
+            if ctx.Err() != nil {
+                return
+            }
+            b.report(err)
+            if errors.Is(err, ErrNoUpdateID) {
+                b.mu.Lock()
+                b.err = err
+                b.mu.Unlock()
+                return
+            }
+            failures++
+            continue
+        }
+        synced := time.Now()
+        err = b.follow(ctx, events)
+        if err == nil {
+            return
+        }
+        b.report(err)
+        // a book that stayed in sync for a while resyncs right away
+        if time.Since(synced) >= b.backoff.MaxDelay {
+            failures = 0
+        } else {
+            failures++
+        }
+    }
+}
+
+// resync replace the book with a new snapshot, the diffs received
+// meanwhile stay buffered in the subscription
+func (b *LocalOrderBook) resync(ctx context.Context) error {
+    b.mu.Lock()
+    b.synced = false
+    b.mu.Unlock()
+    depth, err := b.c.NewDepthService().Symbol(b.symbol).Limit(b.limit).Do(ctx)
+    if err != nil {
+        return err
+    }
+    // a snapshot without update id never lines up with the diffs
+    if depth.LastUpdateID == 0 {
+        return ErrNoUpdateID
+    }
+    book, err := depth.OrderBook()
+    if err != nil {
+        return err
+    }
+    b.mu.Lock()
+    b.book = book
+    b.lastUpdateID = depth.LastUpdateID
+    b.synced = true
+    b.mu.Unlock()
+    b.syncedOnce.Do(func() { close(b.syncedCh) })
+    b.notify(depth.LastUpdateID)
+    return nil
+}
+
+// follow apply the diffs until one is missing, it return nil when the
+// stream or ctx ended
+func (b *LocalOrderBook) follow(ctx context.Context, events <-chan interface{}) error {
+    first := true
+    for {
+        var e *WsDepthEvent
+        select {
+        case <-ctx.Done():
+            return nil
+        case v, ok := <-events:
+            if !ok {
+                return nil
+            }
+            e = v.(*WsDepthEvent)
+        }
+        last := b.LastUpdateID()
+        if e.LastUpdateID <= last {
+            // already in the snapshot
+            continue
+        }
+        // the first diff may straddle the snapshot, the next ones must
+        // follow each other
+        if (first && e.FirstUpdateID > last+1) || (!first && e.FirstUpdateID != last+1) {
+            return &BookGapError{Symbol: b.symbol, LastUpdateID: last, FirstUpdateID: e.FirstUpdateID}
+        }
+        first = false
+        err := b.apply(e)
+        if err != nil {
+            return err
+        }
+        b.notify(e.LastUpdateID)
+    }
+}
+
+func (b *LocalOrderBook) apply(e *WsDepthEvent) error {
+    bids, err := (&Depth{Bids: e.Bids}).BidsDecimal()
+    if err != nil {
+        return err
+    }
+    asks, err := (&Depth{Asks: e.Asks}).AsksDecimal()
+    if err != nil {
+        return err
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.book.Bids = applyLevels(b.book.Bids, bids, true)
+    b.book.Asks = applyLevels(b.book.Asks, asks, false)
+    b.lastUpdateID = e.LastUpdateID
+    return nil
+}
+
+// applyLevels update sorted levels in place, a zero quantity removes a level
+func applyLevels(levels, updates []common.DecimalLevel, descending bool) []common.DecimalLevel {
+    for _, u := range updates {
+        i := sort.Search(len(levels), func(i int) bool {
+            if descending {
+                return levels[i].Price.Cmp(u.Price) <= 0
+            }
+            return levels[i].Price.Cmp(u.Price) >= 0
+        })
+        found := i < len(levels) && levels[i].Price.Equal(u.Price)
+        switch {
+        case u.Quantity.Sign() <= 0:
+            if found {
+                levels = append(levels[:i], levels[i+1:]...)
+            }
+        case found:
+            levels[i] = u
+        default:
+            levels = append(levels, common.DecimalLevel{})
+            copy(levels[i+1:], levels[i:])
+            levels[i] = u
+        }
+    }
+    return levels
+}
+
+func (b *LocalOrderBook) notify(lastUpdateID int64) {
+    select {
+    case b.changes <- lastUpdateID:
+    default:
+    }
+}
+
+func (b *LocalOrderBook) report(err error) {
+    b.c.log(LogLevelWarn, "local order book", F("symbol", b.symbol), F("error", err))
+    b.mu.RLock()
+    onError := b.onError
+    b.mu.RUnlock()
+    if onError != nil {
+        onError(err)
+    }
+}
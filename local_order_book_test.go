@@ -0,0 +1,157 @@
+package bitnut_test
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/hardyzp/bitnut"
+    "github.com/hardyzp/bitnut/bitnuttest"
+    "github.com/stretchr/testify/assert"
+)
+
+func levels(book *bitnut.OrderBook) (bids, asks []string) {
+    for _, l := range book.Bids {
+        bids = append(bids, l.Price.String()+"x"+l.Quantity.String())
+    }
+    for _, l := range book.Asks {
+        asks = append(asks, l.Price.String()+"x"+l.Quantity.String())
+    }
+    return bids, asks
+}
+
+func waitUpdate(t *testing.T, b *bitnut.LocalOrderBook, id int64) {
+    deadline := time.After(2 * time.Second)
+    for b.LastUpdateID() != id {
+        select {
+        case <-b.Changes():
+        case <-deadline:
+            t.Fatalf("book at update %d, want %d", b.LastUpdateID(), id)
+        }
+    }
+}
+
+func TestLocalOrderBook(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    srv.SetDepth("BTCUSDT", &bitnut.Depth{
+        LastUpdateID: 100,
+        Bids:         [][2]string{{"100", "1"}, {"99", "2"}},
+        Asks:         [][2]string{{"101", "1"}, {"103", "4"}},
+    })
+    c := srv.Client()
+    s := testStream(srv)
+    assert.NoError(s.Connect(context.Background()))
+    defer s.Close()
+
+    var mu sync.Mutex
+    var gaps []*bitnut.BookGapError
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    b := c.NewLocalOrderBook(s, "BTCUSDT").Limit(50).OnError(func(err error) {
+        var gap *bitnut.BookGapError
+        if errors.As(err, &gap) {
+            mu.Lock()
+            gaps = append(gaps, gap)
+            mu.Unlock()
+        }
+    })
+    _, err := b.Book()
+    assert.ErrorIs(err, bitnut.ErrBookNotSynced)
+    assert.NoError(b.Start(ctx))
+    assert.NoError(b.WaitSynced(ctx))
+    assert.Eventually(func() bool { return srv.StreamSubscribers("btcusdt@depth") == 1 }, time.Second, time.Millisecond)
+
+    book, err := b.Book()
+    assert.NoError(err)
+    bids, asks := levels(book)
+    assert.Equal([]string{"100x1", "99x2"}, bids)
+    assert.Equal([]string{"101x1", "103x4"}, asks)
+
+    srv.UpdateDepth("BTCUSDT", [][2]string{{"99.5", "3"}, {"100", "0"}}, [][2]string{{"102", "2"}, {"103", "1"}})
+    waitUpdate(t, b, 101)
+    assert.NoError(b.View(func(book *bitnut.OrderBook, lastUpdateID int64) {
+        assert.Equal(int64(101), lastUpdateID)
+        bids, asks := levels(book)
+        assert.Equal([]string{"99.5x3", "99x2"}, bids)
+        assert.Equal([]string{"101x1", "102x2", "103x1"}, asks)
+    }))
+
+    // the exchange moves on while a diff is lost
+    srv.SetDepth("BTCUSDT", &bitnut.Depth{
+        LastUpdateID: 110,
+        Bids:         [][2]string{{"98", "5"}},
+        Asks:         [][2]string{{"104", "6"}},
+    })
+    srv.Publish("btcusdt@depth", &bitnut.WsDepthEvent{Event: "depthUpdate", Symbol: "BTCUSDT", FirstUpdateID: 105, LastUpdateID: 106})
+    waitUpdate(t, b, 110)
+    mu.Lock()
+    assert.Equal([]*bitnut.BookGapError{{Symbol: "BTCUSDT", LastUpdateID: 101, FirstUpdateID: 105}}, gaps)
+    mu.Unlock()
+
+    srv.UpdateDepth("BTCUSDT", [][2]string{{"98.5", "1"}}, nil)
+    waitUpdate(t, b, 111)
+    book, err = b.Book()
+    assert.NoError(err)
+    bids, asks = levels(book)
+    assert.Equal([]string{"98.5x1", "98x5"}, bids)
+    assert.Equal([]string{"104x6"}, asks)
+
+    cancel()
+    select {
+    case <-b.Done():
+    case <-time.After(2 * time.Second):
+        t.Fatal("book still running")
+    }
+    _, err = b.Book()
+    assert.ErrorIs(err, bitnut.ErrBookNotSynced)
+}
+
+func TestLocalOrderBookGapBackoff(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    srv.SetDepth("BTCUSDT", &bitnut.Depth{LastUpdateID: 100, Bids: [][2]string{{"100", "1"}}, Asks: [][2]string{{"101", "1"}}})
+    c := srv.Client()
+    s := testStream(srv)
+    assert.NoError(s.Connect(context.Background()))
+    defer s.Close()
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    b := c.NewLocalOrderBook(s, "BTCUSDT")
+    assert.NoError(b.Start(ctx))
+    assert.NoError(b.WaitSynced(ctx))
+    assert.Eventually(func() bool { return srv.StreamSubscribers("btcusdt@depth") == 1 }, time.Second, time.Millisecond)
+
+    // every diff is ahead of the snapshot, the resyncs back off
+    for i := int64(0); i < 10; i++ {
+        srv.Publish("btcusdt@depth", &bitnut.WsDepthEvent{Event: "depthUpdate", Symbol: "BTCUSDT", FirstUpdateID: 200 + 10*i, LastUpdateID: 205 + 10*i})
+    }
+    time.Sleep(300 * time.Millisecond)
+    snapshots := srv.Requests("/v1/tick/depth")
+    assert.True(snapshots >= 2, snapshots)
+    assert.True(snapshots <= 4, snapshots)
+}
+
+func TestLocalOrderBookNoUpdateID(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    srv.SetDepth("BTCUSDT", &bitnut.Depth{Bids: [][2]string{{"100", "1"}}, Asks: [][2]string{{"101", "1"}}})
+    c := srv.Client()
+    s := testStream(srv)
+    assert.NoError(s.Connect(context.Background()))
+    defer s.Close()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+    defer cancel()
+    b := c.NewLocalOrderBook(s, "BTCUSDT")
+    assert.NoError(b.Start(ctx))
+    assert.ErrorIs(b.WaitSynced(ctx), bitnut.ErrNoUpdateID)
+    <-b.Done()
+    assert.Equal(1, srv.Requests("/v1/tick/depth"))
+}
@@ -0,0 +1,161 @@
+package bitnut
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "sort"
+    "strings"
+
+    "github.com/hardyzp/bitnut/common"
+)
+
+// LogLevel define the severity of a log entry
+type LogLevel int
+
+// Log levels
+const (
+    LogLevelDebug LogLevel = iota
+    LogLevelInfo
+    LogLevelWarn
+    LogLevelError
+)
+
+// String return the name of the level
+func (l LogLevel) String() string {
+    switch l {
+    case LogLevelDebug:
+        return "DEBUG"
+    case LogLevelInfo:
+        return "INFO"
+    case LogLevelWarn:
+        return "WARN"
+    case LogLevelError:
+        return "ERROR"
+    default:
+        return fmt.Sprintf("LEVEL(%d)", int(l))
+    }
+}
+
+// Field define a key/value pair attached to a log entry
+type Field struct {
+    Key   string
+    Value interface{}
+}
+
+// F build a Field
+func F(key string, value interface{}) Field {
+    return Field{Key: key, Value: value}
+}
+
+// Logger receive the log entries of the client
+type Logger interface {
+    Log(level LogLevel, msg string, fields ...Field)
+}
+
+// stdLogger adapt a *log.Logger to Logger
+type stdLogger struct {
+    logger   *log.Logger
+    minLevel LogLevel
+}
+
+// NewStdLogger adapt a standard library logger, entries below minLevel are dropped
+func NewStdLogger(logger *log.Logger, minLevel LogLevel) Logger {
+    return &stdLogger{logger: logger, minLevel: minLevel}
+}
+
+// Log format the entry as "LEVEL msg key=value ..."
+func (l *stdLogger) Log(level LogLevel, msg string, fields ...Field) {
+    if level < l.minLevel {
+        return
+    }
+    var b strings.Builder
+    b.WriteString(level.String())
+    b.WriteByte(' ')
+    b.WriteString(msg)
+    for _, f := range fields {
+        b.WriteByte(' ')
+        b.WriteString(f.Key)
+        b.WriteByte('=')
+        v := fmt.Sprint(f.Value)
+        if strings.ContainsAny(v, " \t\n\"=") {
+            v = fmt.Sprintf("%q", v)
+        }
+        b.WriteString(v)
+    }
+    l.logger.Print(b.String())
+}
+
+// nopLogger discard every entry
+type nopLogger struct{}
+
+func (nopLogger) Log(LogLevel, string, ...Field) {}
+
+// NopLogger return a logger discarding every entry
+func NopLogger() Logger {
+    return nopLogger{}
+}
+
+const redacted = common.Redacted
+
+// sensitiveHeaders are the headers whose values are never logged
+var sensitiveHeaders = map[string]bool{
+    "Bu-Access-Key":  true,
+    "Bu-Access-Sign": true,
+    "Authorization":  true,
+}
+
+// redactValues encode v with the sensitive values masked
+func redactValues(v url.Values) string {
+    if len(v) == 0 {
+        return ""
+    }
+    masked := make(url.Values, len(v))
+    for k, vs := range v {
+        if common.IsSensitiveKey(k) {
+            masked[k] = []string{redacted}
+            continue
+        }
+        masked[k] = vs
+    }
+    s, _ := url.QueryUnescape(masked.Encode())
+    return s
+}
+
+// redactBody format a JSON response body with the values of sensitive keys
+// masked, such as the listen key of the user data stream
+func redactBody(data []byte) string {
+    return string(common.RedactJSON(data, common.IsSensitiveKey))
+}
+
+// redactHeader format h with the sensitive values masked
+func redactHeader(h http.Header) string {
+    keys := make([]string, 0, len(h))
+    for k := range h {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    parts := make([]string, 0, len(keys))
+    for _, k := range keys {
+        v := strings.Join(h[k], ",")
+        if sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+            v = redacted
+        }
+        parts = append(parts, k+": "+v)
+    }
+    return strings.Join(parts, "; ")
+}
+
+// log send an entry to the client logger, debug entries are only sent
+// when Debug is set
+func (c *Client) log(level LogLevel, msg string, fields ...Field) {
+    if c.Logger == nil || (level == LogLevelDebug && !c.Debug) {
+        return
+    }
+    c.Logger.Log(level, msg, fields...)
+}
+
+func (c *Client) debug(msg string, fields ...Field) {
+    c.log(LogLevelDebug, msg, fields...)
+}
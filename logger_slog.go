@@ -0,0 +1,41 @@
+//go:build go1.21
+// +build go1.21
+
+package bitnut
+
+import (
+    "context"
+    "log/slog"
+)
+
+// slogLogger adapt a *slog.Logger to Logger
+type slogLogger struct {
+    logger *slog.Logger
+}
+
+// NewSlogLogger adapt a log/slog logger, levels map to their slog counterparts
+func NewSlogLogger(logger *slog.Logger) Logger {
+    return &slogLogger{logger: logger}
+}
+
+// Log send the entry with its fields as attributes
+func (l *slogLogger) Log(level LogLevel, msg string, fields ...Field) {
+    attrs := make([]slog.Attr, 0, len(fields))
+    for _, f := range fields {
+        attrs = append(attrs, slog.Any(f.Key, f.Value))
+    }
+    l.logger.LogAttrs(context.Background(), slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level LogLevel) slog.Level {
+    switch level {
+    case LogLevelDebug:
+        return slog.LevelDebug
+    case LogLevelInfo:
+        return slog.LevelInfo
+    case LogLevelWarn:
+        return slog.LevelWarn
+    default:
+        return slog.LevelError
+    }
+}
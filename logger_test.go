@@ -0,0 +1,64 @@
+package bitnut
+
+import (
+    "bytes"
+    "context"
+    "log"
+    "net/http"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestLoggerRedactsSecrets(t *testing.T) {
+    assert := assert.New(t)
+    var buf bytes.Buffer
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        return jsonResponse(`{"code":0,"msg":"","data":{"coin":"BTC","free":"1","freeze":"0"}}`), nil
+    })
+    c.APIKey = "my-api-key"
+    c.SecretKey = "my-secret-key"
+    c.Debug = true
+    c.Logger = NewStdLogger(log.New(&buf, "", 0), LogLevelDebug)
+
+    _, err := c.NewGetBalanceService().SetCoin("BTC").Do(context.Background())
+    assert.NoError(err)
+    out := buf.String()
+    assert.Contains(out, "DEBUG sending request method=POST endpoint=/v1/asset/balance")
+    assert.Contains(out, "status=200 code=0")
+    assert.Contains(out, "latency=")
+    assert.Contains(out, redacted)
+    assert.NotContains(out, "my-api-key")
+    assert.NotContains(out, "my-secret-key")
+    sig, err := NewHMACSigner("my-secret-key").Sign(&SignPayload{Body: "coin=BTC"})
+    assert.NoError(err)
+    assert.NotContains(out, sig)
+
+    buf.Reset()
+    c.Debug = false
+    _, err = c.NewGetBalanceService().SetCoin("BTC").Do(context.Background())
+    assert.NoError(err)
+    assert.Empty(buf.String())
+}
+
+func TestLoggerRedactsListenKey(t *testing.T) {
+    assert := assert.New(t)
+    var buf bytes.Buffer
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        return jsonResponse(`{"code":0,"msg":"","data":{"listenKey":"my-listen-key"}}`), nil
+    })
+    c.Debug = true
+    c.Logger = NewStdLogger(log.New(&buf, "", 0), LogLevelDebug)
+
+    listenKey, err := c.NewStartUserStreamService().Do(context.Background())
+    assert.NoError(err)
+    assert.Equal("my-listen-key", listenKey)
+    out := buf.String()
+    assert.Contains(out, "response body")
+    assert.Contains(out, redacted)
+    assert.NotContains(out, "my-listen-key")
+
+    assert.Equal(`{"code":0,"data":{"listenKey":"`+redacted+`"}}`, redactBody([]byte(`{"code":0,"data":{"listenKey":"abc"}}`)))
+    assert.Equal(`{"code":0,"data":{"orderId":"123456789012345678"}}`, redactBody([]byte(`{"code":0,"data":{"orderId":"123456789012345678"}}`)))
+    assert.Equal("not json", redactBody([]byte("not json")))
+}
@@ -0,0 +1,66 @@
+package bitnut
+
+import (
+    "net/http"
+    "net/url"
+)
+
+// SecurityType define how a request is authenticated
+type SecurityType int
+
+// Security types
+const (
+    SecurityTypeNone SecurityType = iota
+    SecurityTypeAPIKey
+    SecurityTypeSigned
+)
+
+// RequestInfo describe the API call wrapped by a middleware.
+// Query and Form are the parameters as they were signed, they must not be modified.
+type RequestInfo struct {
+    Method       string
+    Endpoint     string
+    SecurityType SecurityType
+    Query        url.Values
+    Form         url.Values
+}
+
+// Handler send a signed HTTP request and return the raw response,
+// before its body is read and decoded
+type Handler func(info *RequestInfo, req *http.Request) (*http.Response, error)
+
+// Middleware wrap a Handler, it may inspect or alter the request and the
+// response, or answer without calling next
+type Middleware func(next Handler) Handler
+
+// Use append middlewares to the chain of the client. The first middleware
+// added is the outermost one.
+func (c *Client) Use(middlewares ...Middleware) *Client {
+    c.middlewares = append(c.middlewares, middlewares...)
+    return c
+}
+
+// handler return the middleware chain around the HTTP client
+func (c *Client) handler() Handler {
+    f := c.do
+    if f == nil {
+        f = c.HTTPClient.Do
+    }
+    h := Handler(func(info *RequestInfo, req *http.Request) (*http.Response, error) {
+        return f(req)
+    })
+    for i := len(c.middlewares) - 1; i >= 0; i-- {
+        h = c.middlewares[i](h)
+    }
+    return h
+}
+
+func (r *request) info() *RequestInfo {
+    return &RequestInfo{
+        Method:       r.method,
+        Endpoint:     r.endpoint,
+        SecurityType: SecurityType(r.secType),
+        Query:        r.query,
+        Form:         r.form,
+    }
+}
@@ -0,0 +1,47 @@
+package bitnut
+
+import (
+    "context"
+    "net/http"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+    assert := assert.New(t)
+    var header http.Header
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        header = req.Header
+        return jsonResponse(`{"code":0,"msg":"","data":{"coin":"BTC","free":"1","freeze":"0"}}`), nil
+    })
+    var trace []string
+    record := func(name string) Middleware {
+        return func(next Handler) Handler {
+            return func(info *RequestInfo, req *http.Request) (*http.Response, error) {
+                trace = append(trace, name+" "+info.Endpoint)
+                assert.Equal(SecurityTypeSigned, info.SecurityType)
+                assert.Equal("BTC", info.Form.Get("coin"))
+                assert.NotEmpty(req.Header.Get("BU-ACCESS-SIGN"))
+                req.Header.Set("X-Trace", name)
+                res, err := next(info, req)
+                trace = append(trace, name+" done")
+                return res, err
+            }
+        }
+    }
+    c.Use(record("outer"), record("inner"))
+    _, err := c.NewGetBalanceService().SetCoin("BTC").Do(context.Background())
+    assert.NoError(err)
+    assert.Equal([]string{"outer /v1/asset/balance", "inner /v1/asset/balance", "inner done", "outer done"}, trace)
+    assert.Equal("inner", header.Get("X-Trace"))
+
+    // a middleware may answer on its own, e.g. to inject faults
+    c.Use(func(next Handler) Handler {
+        return func(info *RequestInfo, req *http.Request) (*http.Response, error) {
+            return jsonResponse(`{"code":-2010,"msg":"Account has insufficient balance"}`), nil
+        }
+    })
+    _, err = c.NewGetBalanceService().SetCoin("BTC").Do(context.Background())
+    assert.Error(err)
+}
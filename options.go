@@ -0,0 +1,229 @@
+package bitnut
+
+import (
+    "crypto/tls"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "time"
+)
+
+// Transport defaults of clients created with New
+const (
+    defaultTimeout             = 30 * time.Second
+    defaultDialTimeout         = 10 * time.Second
+    defaultTLSHandshakeTimeout = 10 * time.Second
+    defaultIdleConnTimeout     = 90 * time.Second
+    defaultMaxIdleConnsPerHost = 16
+)
+
+// clientConfig collect the settings of ClientOptions before the client and
+// its transport are built
+type clientConfig struct {
+    client      *Client
+    proxyURL    *url.URL
+    timeout     time.Duration
+    dialTimeout time.Duration
+    transport   http.RoundTripper
+    tlsConfig   *tls.Config
+    environment *Environment
+    baseURL     string
+}
+
+// ClientOption define option type for New
+type ClientOption func(*clientConfig) error
+
+// New initialize an API client with API key, secret key and options.
+// Unlike NewClient it uses its own tuned transport with certificate
+// verification on, rather than http.DefaultClient.
+func New(apiKey, secretKey string, opts ...ClientOption) (*Client, error) {
+    cfg := &clientConfig{
+        client:      NewClient(apiKey, secretKey),
+        timeout:     defaultTimeout,
+        dialTimeout: defaultDialTimeout,
+    }
+    for _, opt := range opts {
+        err := opt(cfg)
+        if err != nil {
+            return nil, err
+        }
+    }
+    if cfg.environment != nil {
+        if cfg.baseURL != "" && cfg.baseURL != cfg.environment.BaseURL {
+            return nil, fmt.Errorf("bitnut: base url %q conflicts with environment %q", cfg.baseURL, cfg.environment.Name)
+        }
+        cfg.client.SetEnvironment(*cfg.environment)
+    } else if cfg.baseURL != "" {
+        cfg.client.SetApiEndpoint(cfg.baseURL)
+    }
+    transport := cfg.transport
+    if transport == nil {
+        transport = newTransport(cfg)
+    } else if cfg.proxyURL != nil || cfg.tlsConfig != nil {
+        return nil, errors.New("bitnut: proxy and TLS options cannot be combined with a custom transport")
+    }
+    cfg.client.HTTPClient = &http.Client{
+        Transport: transport,
+        Timeout:   cfg.timeout,
+    }
+    return cfg.client, nil
+}
+
+func newTransport(cfg *clientConfig) *http.Transport {
+    proxy := http.ProxyFromEnvironment
+    if cfg.proxyURL != nil {
+        proxy = http.ProxyURL(cfg.proxyURL)
+    }
+    tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+    if cfg.tlsConfig != nil {
+        tlsConfig = cfg.tlsConfig.Clone()
+    }
+    return &http.Transport{
+        Proxy: proxy,
+        DialContext: (&net.Dialer{
+            Timeout:   cfg.dialTimeout,
+            KeepAlive: 30 * time.Second,
+        }).DialContext,
+        ForceAttemptHTTP2:     true,
+        TLSClientConfig:       tlsConfig,
+        TLSHandshakeTimeout:   defaultTLSHandshakeTimeout,
+        MaxIdleConns:          100,
+        MaxIdleConnsPerHost:   defaultMaxIdleConnsPerHost,
+        IdleConnTimeout:       defaultIdleConnTimeout,
+        ExpectContinueTimeout: time.Second,
+    }
+}
+
+// WithProxy send requests through the proxy at proxyURL
+func WithProxy(proxyURL string) ClientOption {
+    return func(cfg *clientConfig) error {
+        proxy, err := url.Parse(proxyURL)
+        if err != nil {
+            return fmt.Errorf("bitnut: invalid proxy url: %w", err)
+        }
+        if proxy.Scheme == "" || proxy.Host == "" {
+            return fmt.Errorf("bitnut: invalid proxy url %q", proxyURL)
+        }
+        cfg.proxyURL = proxy
+        return nil
+    }
+}
+
+// WithTimeout set the overall timeout of a request, 0 means no timeout
+func WithTimeout(timeout time.Duration) ClientOption {
+    return func(cfg *clientConfig) error {
+        if timeout < 0 {
+            return fmt.Errorf("bitnut: negative timeout %s", timeout)
+        }
+        cfg.timeout = timeout
+        return nil
+    }
+}
+
+// WithDialTimeout set the timeout of establishing a connection
+func WithDialTimeout(timeout time.Duration) ClientOption {
+    return func(cfg *clientConfig) error {
+        if timeout < 0 {
+            return fmt.Errorf("bitnut: negative dial timeout %s", timeout)
+        }
+        cfg.dialTimeout = timeout
+        return nil
+    }
+}
+
+// WithBaseURL set the REST API base URL, the environment is derived from it
+// as with Client.SetApiEndpoint. It cannot be combined with WithEnvironment
+// for another URL.
+func WithBaseURL(baseURL string) ClientOption {
+    return func(cfg *clientConfig) error {
+        u, err := url.Parse(baseURL)
+        if err != nil {
+            return fmt.Errorf("bitnut: invalid base url: %w", err)
+        }
+        if u.Scheme == "" || u.Host == "" {
+            return fmt.Errorf("bitnut: invalid base url %q", baseURL)
+        }
+        cfg.baseURL = trimBaseURL(baseURL)
+        return nil
+    }
+}
+
+// WithEnvironment select the environment of the client
+func WithEnvironment(env Environment) ClientOption {
+    return func(cfg *clientConfig) error {
+        if env.BaseURL == "" {
+            return fmt.Errorf("bitnut: environment %q has no base url", env.Name)
+        }
+        cfg.environment = &env
+        return nil
+    }
+}
+
+// WithMainnetTrading allow order requests against mainnet
+func WithMainnetTrading() ClientOption {
+    return func(cfg *clientConfig) error {
+        cfg.client.AllowMainnetTrading = true
+        return nil
+    }
+}
+
+// WithLogger set the logger of the client, see NewStdLogger and NewSlogLogger
+func WithLogger(logger Logger) ClientOption {
+    return func(cfg *clientConfig) error {
+        cfg.client.Logger = logger
+        return nil
+    }
+}
+
+// WithUserAgent set the User-Agent header sent with every request
+func WithUserAgent(userAgent string) ClientOption {
+    return func(cfg *clientConfig) error {
+        cfg.client.UserAgent = userAgent
+        return nil
+    }
+}
+
+// WithTransport use a custom round tripper instead of the tuned transport
+func WithTransport(transport http.RoundTripper) ClientOption {
+    return func(cfg *clientConfig) error {
+        if transport == nil {
+            return errors.New("bitnut: nil transport")
+        }
+        cfg.transport = transport
+        return nil
+    }
+}
+
+// WithTLSConfig set the TLS configuration of the transport
+func WithTLSConfig(tlsConfig *tls.Config) ClientOption {
+    return func(cfg *clientConfig) error {
+        cfg.tlsConfig = tlsConfig
+        return nil
+    }
+}
+
+// WithSigner set the signer of signed requests
+func WithSigner(signer Signer) ClientOption {
+    return func(cfg *clientConfig) error {
+        cfg.client.Signer = signer
+        return nil
+    }
+}
+
+// WithRateLimiter set the rate limiter, nil disables rate limiting
+func WithRateLimiter(limiter *RateLimiter) ClientOption {
+    return func(cfg *clientConfig) error {
+        cfg.client.RateLimiter = limiter
+        return nil
+    }
+}
+
+// WithRetries set the default retry policy, nil disables retries
+func WithRetries(policy *RetryPolicy) ClientOption {
+    return func(cfg *clientConfig) error {
+        cfg.client.RetryPolicy = policy
+        return nil
+    }
+}
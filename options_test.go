@@ -0,0 +1,59 @@
+package bitnut
+
+import (
+    "crypto/tls"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+    assert := assert.New(t)
+    c, err := New("key", "secret",
+        WithProxy("http://127.0.0.1:8080"),
+        WithTimeout(5*time.Second),
+        WithEnvironment(EnvironmentTestnet),
+        WithUserAgent("bot/1.0"),
+    )
+    assert.NoError(err)
+    assert.Equal(baseAPITestnetURL, c.BaseURL)
+    assert.Equal("bot/1.0", c.UserAgent)
+    assert.Equal(5*time.Second, c.HTTPClient.Timeout)
+    assert.NotSame(http.DefaultClient, c.HTTPClient)
+    tr, ok := c.HTTPClient.Transport.(*http.Transport)
+    assert.True(ok)
+    assert.False(tr.TLSClientConfig.InsecureSkipVerify)
+    proxy, err := tr.Proxy(&http.Request{})
+    assert.NoError(err)
+    assert.Equal("127.0.0.1:8080", proxy.Host)
+
+    _, err = New("key", "secret", WithProxy("://bad"))
+    assert.Error(err)
+    _, err = New("key", "secret", WithBaseURL("not a url"))
+    assert.Error(err)
+    _, err = New("key", "secret", WithTransport(http.DefaultTransport), WithTLSConfig(&tls.Config{}))
+    assert.Error(err)
+
+    // the environment follows the base url, whatever the option order
+    c, err = New("key", "secret", WithBaseURL(baseAPITestnetURL))
+    assert.NoError(err)
+    assert.Equal(EnvironmentTestnet, c.Environment)
+    c, err = New("key", "secret", WithBaseURL("http://127.0.0.1:8080"))
+    assert.NoError(err)
+    assert.Equal("http://127.0.0.1:8080", c.BaseURL)
+    assert.Equal("http://127.0.0.1:8080", c.Environment.BaseURL)
+    assert.False(c.Environment.Mainnet)
+    c, err = New("key", "secret", WithBaseURL(baseAPITestnetURL), WithEnvironment(EnvironmentTestnet))
+    assert.NoError(err)
+    assert.Equal(baseAPITestnetURL, c.BaseURL)
+    _, err = New("key", "secret", WithBaseURL("http://127.0.0.1:8080"), WithEnvironment(EnvironmentTestnet))
+    assert.Error(err)
+    _, err = New("key", "secret", WithEnvironment(EnvironmentTestnet), WithBaseURL("http://127.0.0.1:8080"))
+    assert.Error(err)
+
+    c, err = New("key", "secret", WithTransport(http.DefaultTransport))
+    assert.NoError(err)
+    assert.Equal(http.DefaultTransport, c.HTTPClient.Transport)
+}
@@ -0,0 +1,309 @@
+package bitnut
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+
+    "github.com/hardyzp/bitnut/common"
+)
+
+// Quotients that are not exact, such as a VWAP or a ratio, are rounded half
+// to even to this many fractional digits more than the book prices
+const orderBookExtraPlaces = 8
+
+// ErrEmptyBook is returned when a query needs levels the book doesn't have
+var ErrEmptyBook = errors.New("bitnut: order book side is empty")
+
+// OrderBook define a depth snapshot with sorted Decimal levels, the best
+// level first: bids by descending price, asks by ascending price.
+// Queries taking a SideType are from the point of view of a taker order,
+// a BUY walks the asks and a SELL walks the bids.
+type OrderBook struct {
+    Bids []common.DecimalLevel
+    Asks []common.DecimalLevel
+}
+
+// OrderBook build an OrderBook from the depth snapshot
+func (d *Depth) OrderBook() (*OrderBook, error) {
+    bids, err := d.BidsDecimal()
+    if err != nil {
+        return nil, err
+    }
+    asks, err := d.AsksDecimal()
+    if err != nil {
+        return nil, err
+    }
+    return NewOrderBook(bids, asks), nil
+}
+
+// NewOrderBook build an OrderBook from unsorted levels. Levels at the same
+// price are merged and empty levels are dropped.
+func NewOrderBook(bids, asks []common.DecimalLevel) *OrderBook {
+    return &OrderBook{
+        Bids: sortLevels(bids, true),
+        Asks: sortLevels(asks, false),
+    }
+}
+
+func sortLevels(levels []common.DecimalLevel, descending bool) []common.DecimalLevel {
+    res := make([]common.DecimalLevel, 0, len(levels))
+    for _, l := range levels {
+        if l.Quantity.Sign() > 0 {
+            res = append(res, l)
+        }
+    }
+    sort.SliceStable(res, func(i, j int) bool {
+        if descending {
+            return res[i].Price.GreaterThan(res[j].Price)
+        }
+        return res[i].Price.LessThan(res[j].Price)
+    })
+    merged := res[:0]
+    for _, l := range res {
+        if n := len(merged); n > 0 && merged[n-1].Price.Equal(l.Price) {
+            merged[n-1].Quantity = merged[n-1].Quantity.Add(l.Quantity)
+            continue
+        }
+        merged = append(merged, l)
+    }
+    return merged
+}
+
+// BestBid return the highest bid, false when there is none
+func (b *OrderBook) BestBid() (common.DecimalLevel, bool) {
+    if len(b.Bids) == 0 {
+        return common.DecimalLevel{}, false
+    }
+    return b.Bids[0], true
+}
+
+// BestAsk return the lowest ask, false when there is none
+func (b *OrderBook) BestAsk() (common.DecimalLevel, bool) {
+    if len(b.Asks) == 0 {
+        return common.DecimalLevel{}, false
+    }
+    return b.Asks[0], true
+}
+
+// Spread return best ask - best bid
+func (b *OrderBook) Spread() (common.Decimal, error) {
+    bid, ask, err := b.top()
+    if err != nil {
+        return common.Decimal{}, err
+    }
+    return ask.Price.Sub(bid.Price), nil
+}
+
+// Mid return the average of the best bid and ask prices
+func (b *OrderBook) Mid() (common.Decimal, error) {
+    bid, ask, err := b.top()
+    if err != nil {
+        return common.Decimal{}, err
+    }
+    sum := bid.Price.Add(ask.Price)
+    // halving never needs more than one extra digit
+    return sum.Quo(common.NewDecimal(2, 0), sum.Scale()+1, common.RoundHalfEven)
+}
+
+// MicroPrice return the mid weighted by the quantities at the top of the
+// book, (bid * askQty + ask * bidQty) / (bidQty + askQty), which leans
+// toward the side with less quantity
+func (b *OrderBook) MicroPrice() (common.Decimal, error) {
+    bid, ask, err := b.top()
+    if err != nil {
+        return common.Decimal{}, err
+    }
+    num := bid.Price.Mul(ask.Quantity).Add(ask.Price.Mul(bid.Quantity))
+    return num.Quo(bid.Quantity.Add(ask.Quantity), b.places(), common.RoundHalfEven)
+}
+
+func (b *OrderBook) top() (bid, ask common.DecimalLevel, err error) {
+    bid, okBid := b.BestBid()
+    ask, okAsk := b.BestAsk()
+    if !okBid || !okAsk {
+        return bid, ask, ErrEmptyBook
+    }
+    return bid, ask, nil
+}
+
+// places return the number of fractional digits of inexact prices
+func (b *OrderBook) places() int {
+    places := 0
+    for _, levels := range [][]common.DecimalLevel{b.Bids, b.Asks} {
+        if len(levels) > 0 && levels[0].Price.Scale() > places {
+            places = levels[0].Price.Scale()
+        }
+    }
+    return places + orderBookExtraPlaces
+}
+
+// levels return the side walked by a taker order
+func (b *OrderBook) levels(side SideType) ([]common.DecimalLevel, error) {
+    switch side {
+    case SideTypeBuy:
+        return b.Asks, nil
+    case SideTypeSell:
+        return b.Bids, nil
+    }
+    return nil, fmt.Errorf("bitnut: invalid side %q", side)
+}
+
+// reaches check if a level price is at or better than limit for a taker
+func reaches(side SideType, price, limit common.Decimal) bool {
+    if side == SideTypeBuy {
+        return price.Cmp(limit) <= 0
+    }
+    return price.Cmp(limit) >= 0
+}
+
+// DepthTo return the base quantity and its quote value available to a
+// taker order of side without going past price
+func (b *OrderBook) DepthTo(side SideType, price common.Decimal) (quantity, quote common.Decimal, err error) {
+    levels, err := b.levels(side)
+    if err != nil {
+        return quantity, quote, err
+    }
+    for _, l := range levels {
+        if !reaches(side, l.Price, price) {
+            break
+        }
+        quantity = quantity.Add(l.Quantity)
+        quote = quote.Add(l.Price.Mul(l.Quantity))
+    }
+    return quantity, quote, nil
+}
+
+// BookFill define the estimated execution of a taker order against the book
+type BookFill struct {
+    // Quantity is the base quantity filled
+    Quantity common.Decimal
+    // Quote is the quote amount paid or received
+    Quote common.Decimal
+    // AveragePrice is the volume weighted average price
+    AveragePrice common.Decimal
+    // WorstPrice is the price of the last level reached
+    WorstPrice common.Decimal
+    // Slippage is the distance between AveragePrice and the best price,
+    // positive when the average is worse
+    Slippage common.Decimal
+    // SlippageRatio is Slippage relative to the best price
+    SlippageRatio common.Decimal
+    // Complete is false when the book ran out before the order was filled
+    Complete bool
+}
+
+// FillQuantity estimate the execution of a taker order of side for a base
+// quantity
+func (b *OrderBook) FillQuantity(side SideType, quantity common.Decimal) (*BookFill, error) {
+    return b.fill(side, func(l common.DecimalLevel, filled, _ common.Decimal) (common.Decimal, bool) {
+        left := quantity.Sub(filled)
+        if l.Quantity.LessThan(left) {
+            return l.Quantity, false
+        }
+        return left, true
+    })
+}
+
+// FillQuote estimate the execution of a taker order of side for a quote
+// amount, such as a market buy with quoteOrderQty. The quantity taken from
+// the last level is rounded down to eight more fractional digits than its
+// quantity.
+func (b *OrderBook) FillQuote(side SideType, quote common.Decimal) (*BookFill, error) {
+    return b.fill(side, func(l common.DecimalLevel, _, spent common.Decimal) (common.Decimal, bool) {
+        left := quote.Sub(spent)
+        if l.Price.Mul(l.Quantity).LessThan(left) {
+            return l.Quantity, false
+        }
+        q, _ := left.Quo(l.Price, l.Quantity.Scale()+orderBookExtraPlaces, common.RoundDown)
+        return q, true
+    })
+}
+
+// fill walk the levels of side, take return the quantity taken from a level
+// and whether the order is done
+func (b *OrderBook) fill(side SideType, take func(l common.DecimalLevel, filled, spent common.Decimal) (common.Decimal, bool)) (*BookFill, error) {
+    levels, err := b.levels(side)
+    if err != nil {
+        return nil, err
+    }
+    if len(levels) == 0 {
+        return nil, ErrEmptyBook
+    }
+    res := new(BookFill)
+    for _, l := range levels {
+        q, done := take(l, res.Quantity, res.Quote)
+        if q.Sign() > 0 {
+            res.Quantity = res.Quantity.Add(q)
+            res.Quote = res.Quote.Add(l.Price.Mul(q))
+            res.WorstPrice = l.Price
+        }
+        if done {
+            res.Complete = true
+            break
+        }
+    }
+    if res.Quantity.IsZero() {
+        return res, nil
+    }
+    best := levels[0].Price
+    res.AveragePrice, _ = res.Quote.Quo(res.Quantity, b.places(), common.RoundHalfEven)
+    res.Slippage = res.AveragePrice.Sub(best)
+    if side == SideTypeSell {
+        res.Slippage = res.Slippage.Neg()
+    }
+    res.SlippageRatio, err = res.Slippage.Quo(best, orderBookExtraPlaces, common.RoundHalfEven)
+    if err != nil {
+        return nil, err
+    }
+    return res, nil
+}
+
+// Imbalance return (bidQty - askQty) / (bidQty + askQty) over the top levels
+// of each side, all of them when levels <= 0. It ranges from -1, only asks,
+// to 1, only bids.
+func (b *OrderBook) Imbalance(levels int) (common.Decimal, error) {
+    bid, ask := sumQuantity(b.Bids, levels), sumQuantity(b.Asks, levels)
+    if bid.Add(ask).IsZero() {
+        return common.Decimal{}, ErrEmptyBook
+    }
+    return bid.Sub(ask).Quo(bid.Add(ask), orderBookExtraPlaces, common.RoundHalfEven)
+}
+
+// BidAskRatio return bidQty / askQty over the top levels of each side, all
+// of them when levels <= 0
+func (b *OrderBook) BidAskRatio(levels int) (common.Decimal, error) {
+    ask := sumQuantity(b.Asks, levels)
+    if ask.IsZero() {
+        return common.Decimal{}, ErrEmptyBook
+    }
+    return sumQuantity(b.Bids, levels).Quo(ask, orderBookExtraPlaces, common.RoundHalfEven)
+}
+
+func sumQuantity(levels []common.DecimalLevel, n int) common.Decimal {
+    var sum common.Decimal
+    for i, l := range levels {
+        if n > 0 && i >= n {
+            break
+        }
+        sum = sum.Add(l.Quantity)
+    }
+    return sum
+}
+
+// Group aggregate the levels into price buckets of size step, such as 0.1,
+// 1 or 10, for depth charts. Bids are rounded down and asks up to the
+// bucket boundary, so a bucket never looks better than its levels.
+func (b *OrderBook) Group(step common.Decimal) (*OrderBook, error) {
+    if step.Sign() <= 0 {
+        return nil, fmt.Errorf("bitnut: invalid bucket size %s", step)
+    }
+    group := func(levels []common.DecimalLevel, mode common.RoundingMode) []common.DecimalLevel {
+        res := make([]common.DecimalLevel, 0, len(levels))
+        for _, l := range levels {
+            res = append(res, common.DecimalLevel{Price: l.Price.RoundToStep(step, mode), Quantity: l.Quantity})
+        }
+        return res
+    }
+    return NewOrderBook(group(b.Bids, common.RoundFloor), group(b.Asks, common.RoundCeiling)), nil
+}
@@ -0,0 +1,159 @@
+package bitnut_test
+
+import (
+    "testing"
+
+    "github.com/hardyzp/bitnut"
+    "github.com/hardyzp/bitnut/common"
+    "github.com/stretchr/testify/assert"
+)
+
+func testOrderBook(t *testing.T) *bitnut.OrderBook {
+    depth := &bitnut.Depth{
+        Bids: [][2]string{{"99.5", "2"}, {"100.0", "1"}, {"98", "4"}, {"99.5", "1"}, {"97", "0"}},
+        Asks: [][2]string{{"102.0", "3"}, {"101", "1"}, {"105", "10"}},
+    }
+    book, err := depth.OrderBook()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return book
+}
+
+func TestOrderBookTop(t *testing.T) {
+    assert := assert.New(t)
+    book := testOrderBook(t)
+
+    prices := func(levels []common.DecimalLevel) []string {
+        res := make([]string, 0, len(levels))
+        for _, l := range levels {
+            res = append(res, l.Price.String()+"x"+l.Quantity.String())
+        }
+        return res
+    }
+    assert.Equal([]string{"100.0x1", "99.5x3", "98x4"}, prices(book.Bids))
+    assert.Equal([]string{"101x1", "102.0x3", "105x10"}, prices(book.Asks))
+
+    bid, ok := book.BestBid()
+    assert.True(ok)
+    assert.Equal("100.0", bid.Price.String())
+    spread, err := book.Spread()
+    assert.NoError(err)
+    assert.Equal("1.0", spread.String())
+    mid, err := book.Mid()
+    assert.NoError(err)
+    assert.Equal("100.50", mid.String())
+    micro, err := book.MicroPrice()
+    assert.NoError(err)
+    assert.Equal("100.500000000", micro.String())
+
+    imbalance, err := book.Imbalance(1)
+    assert.NoError(err)
+    assert.True(imbalance.IsZero())
+    imbalance, err = book.Imbalance(0)
+    assert.NoError(err)
+    assert.Equal("-0.27272727", imbalance.String())
+    ratio, err := book.BidAskRatio(2)
+    assert.NoError(err)
+    assert.Equal("1.00000000", ratio.String())
+
+    empty := bitnut.NewOrderBook(nil, nil)
+    _, ok = empty.BestAsk()
+    assert.False(ok)
+    _, err = empty.Mid()
+    assert.ErrorIs(err, bitnut.ErrEmptyBook)
+    _, err = empty.Imbalance(0)
+    assert.ErrorIs(err, bitnut.ErrEmptyBook)
+    _, err = (&bitnut.Depth{Asks: [][2]string{{"x", "1"}}}).OrderBook()
+    assert.Error(err)
+}
+
+func TestOrderBookFill(t *testing.T) {
+    assert := assert.New(t)
+    book := testOrderBook(t)
+    d := common.MustParseDecimal
+
+    quantity, quote, err := book.DepthTo(bitnut.SideTypeBuy, d("102"))
+    assert.NoError(err)
+    assert.Equal("4", quantity.String())
+    assert.Equal("407.0", quote.String())
+    quantity, _, err = book.DepthTo(bitnut.SideTypeSell, d("99.5"))
+    assert.NoError(err)
+    assert.Equal("4", quantity.String())
+    _, _, err = book.DepthTo("HOLD", d("1"))
+    assert.Error(err)
+
+    tests := []struct {
+        name     string
+        fill     func() (*bitnut.BookFill, error)
+        quantity string
+        quote    string
+        average  string
+        worst    string
+        slippage string
+        ratio    string
+        complete bool
+    }{
+        {
+            name:     "buy quantity",
+            fill:     func() (*bitnut.BookFill, error) { return book.FillQuantity(bitnut.SideTypeBuy, d("2")) },
+            quantity: "2", quote: "203.0", average: "101.500000000", worst: "102.0",
+            slippage: "0.500000000", ratio: "0.00495050", complete: true,
+        },
+        {
+            name:     "sell quantity",
+            fill:     func() (*bitnut.BookFill, error) { return book.FillQuantity(bitnut.SideTypeSell, d("3")) },
+            quantity: "3", quote: "299.0", average: "99.666666667", worst: "99.5",
+            slippage: "0.333333333", ratio: "0.00333333", complete: true,
+        },
+        {
+            name:     "buy quote",
+            fill:     func() (*bitnut.BookFill, error) { return book.FillQuote(bitnut.SideTypeBuy, d("152")) },
+            quantity: "1.50000000", quote: "152.000000000", average: "101.333333333", worst: "102.0",
+            slippage: "0.333333333", ratio: "0.00330033", complete: true,
+        },
+        {
+            name:     "book exhausted",
+            fill:     func() (*bitnut.BookFill, error) { return book.FillQuantity(bitnut.SideTypeSell, d("10")) },
+            quantity: "8", quote: "790.5", average: "98.812500000", worst: "98",
+            slippage: "1.187500000", ratio: "0.01187500", complete: false,
+        },
+    }
+    for _, tt := range tests {
+        fill, err := tt.fill()
+        assert.NoError(err, tt.name)
+        assert.Equal(tt.quantity, fill.Quantity.String(), tt.name)
+        assert.Equal(tt.quote, fill.Quote.String(), tt.name)
+        assert.Equal(tt.average, fill.AveragePrice.String(), tt.name)
+        assert.Equal(tt.worst, fill.WorstPrice.String(), tt.name)
+        assert.Equal(tt.slippage, fill.Slippage.String(), tt.name)
+        assert.Equal(tt.ratio, fill.SlippageRatio.String(), tt.name)
+        assert.Equal(tt.complete, fill.Complete, tt.name)
+    }
+    _, err = bitnut.NewOrderBook(nil, nil).FillQuantity(bitnut.SideTypeBuy, d("1"))
+    assert.ErrorIs(err, bitnut.ErrEmptyBook)
+}
+
+func TestOrderBookGroup(t *testing.T) {
+    assert := assert.New(t)
+    book := testOrderBook(t)
+
+    grouped, err := book.Group(common.MustParseDecimal("5"))
+    assert.NoError(err)
+    assert.Len(grouped.Bids, 2)
+    assert.Equal("100", grouped.Bids[0].Price.String())
+    assert.Equal("1", grouped.Bids[0].Quantity.String())
+    assert.Equal("95", grouped.Bids[1].Price.String())
+    assert.Equal("7", grouped.Bids[1].Quantity.String())
+    assert.Len(grouped.Asks, 1)
+    assert.Equal("105", grouped.Asks[0].Price.String())
+    assert.Equal("14", grouped.Asks[0].Quantity.String())
+
+    grouped, err = book.Group(common.MustParseDecimal("0.1"))
+    assert.NoError(err)
+    assert.Len(grouped.Bids, 3)
+    assert.Equal("99.5", grouped.Bids[1].Price.String())
+
+    _, err = book.Group(common.Decimal{})
+    assert.Error(err)
+}
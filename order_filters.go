@@ -0,0 +1,278 @@
+package bitnut
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/hardyzp/bitnut/common"
+)
+
+// OrderRounding define the rounding of the price and quantity of an order
+// to the tick and step sizes of its symbol, per side
+type OrderRounding struct {
+    BuyPrice     common.RoundingMode
+    BuyQuantity  common.RoundingMode
+    SellPrice    common.RoundingMode
+    SellQuantity common.RoundingMode
+}
+
+// DefaultOrderRounding never pay more or receive less than asked: buy
+// prices round down, sell prices round up and quantities round down
+func DefaultOrderRounding() OrderRounding {
+    return OrderRounding{
+        BuyPrice:     common.RoundDown,
+        BuyQuantity:  common.RoundDown,
+        SellPrice:    common.RoundUp,
+        SellQuantity: common.RoundDown,
+    }
+}
+
+// OrderFilterError is returned by CreateOrderService when an order breaks a
+// trading rule of its symbol
+type OrderFilterError struct {
+    Symbol string
+    Filter SymbolFilterType
+    Param  string
+    Value  string
+    Reason string
+}
+
+// Error return the rule and the value breaking it
+func (e *OrderFilterError) Error() string {
+    return fmt.Sprintf("<OrderFilterError> symbol=%s, filter=%s, %s=%s %s", e.Symbol, e.Filter, e.Param, e.Value, e.Reason)
+}
+
+// Unwrap make OrderFilterError match ErrInvalidOrder
+func (e *OrderFilterError) Unwrap() error {
+    return ErrInvalidOrder
+}
+
+// orderFilters check and round orders against the rules of a symbol
+type orderFilters struct {
+    symbol *Symbol
+}
+
+func (f orderFilters) invalid(filter SymbolFilterType, param string, value common.Decimal, reason string, args ...interface{}) error {
+    return &OrderFilterError{
+        Symbol: f.symbol.Symbol,
+        Filter: filter,
+        Param:  param,
+        Value:  value.String(),
+        Reason: fmt.Sprintf(reason, args...),
+    }
+}
+
+// lotSize return the lot size rules of the order type, MARKET_LOT_SIZE for
+// market orders when the symbol has one
+func (f orderFilters) lotSize(orderType OrderType) *LotSizeFilter {
+    if orderType == OrderTypeMarket && f.symbol.Filters.MarketLotSize != nil {
+        return f.symbol.Filters.MarketLotSize
+    }
+    return f.symbol.Filters.LotSize
+}
+
+// checkPrice check a price or stop price against PRICE_FILTER
+func (f orderFilters) checkPrice(param string, price common.Decimal) error {
+    filter := f.symbol.Filters.Price
+    if filter == nil {
+        return nil
+    }
+    min, err := parseDecimal("minPrice", filter.MinPrice)
+    if err != nil {
+        return err
+    }
+    max, err := parseDecimal("maxPrice", filter.MaxPrice)
+    if err != nil {
+        return err
+    }
+    tick, err := parseDecimal("tickSize", filter.TickSize)
+    if err != nil {
+        return err
+    }
+    switch {
+    case min.Sign() > 0 && price.LessThan(min):
+        return f.invalid(SymbolFilterTypePrice, param, price, "below min price %s", filter.MinPrice)
+    case max.Sign() > 0 && price.GreaterThan(max):
+        return f.invalid(SymbolFilterTypePrice, param, price, "above max price %s", filter.MaxPrice)
+    case tick.Sign() > 0 && !price.RoundToStep(tick, common.RoundDown).Equal(price):
+        return f.invalid(SymbolFilterTypePrice, param, price, "not a multiple of tick size %s", filter.TickSize)
+    }
+    return nil
+}
+
+// checkQuantity check a quantity against the lot size of the order type
+func (f orderFilters) checkQuantity(orderType OrderType, quantity common.Decimal) error {
+    filter := f.lotSize(orderType)
+    if filter == nil {
+        return nil
+    }
+    filterType := SymbolFilterTypeLotSize
+    if filter == f.symbol.Filters.MarketLotSize {
+        filterType = SymbolFilterTypeMarketLotSize
+    }
+    min, err := parseDecimal("minQty", filter.MinQuantity)
+    if err != nil {
+        return err
+    }
+    max, err := parseDecimal("maxQty", filter.MaxQuantity)
+    if err != nil {
+        return err
+    }
+    step, err := parseDecimal("stepSize", filter.StepSize)
+    if err != nil {
+        return err
+    }
+    switch {
+    case min.Sign() > 0 && quantity.LessThan(min):
+        return f.invalid(filterType, "quantity", quantity, "below min quantity %s", filter.MinQuantity)
+    case max.Sign() > 0 && quantity.GreaterThan(max):
+        return f.invalid(filterType, "quantity", quantity, "above max quantity %s", filter.MaxQuantity)
+    case step.Sign() > 0 && !quantity.RoundToStep(step, common.RoundDown).Equal(quantity):
+        return f.invalid(filterType, "quantity", quantity, "not a multiple of step size %s", filter.StepSize)
+    }
+    return nil
+}
+
+// checkNotional check the order value against MIN_NOTIONAL
+func (f orderFilters) checkNotional(param string, notional common.Decimal) error {
+    filter := f.symbol.Filters.MinNotional
+    if filter == nil {
+        return nil
+    }
+    min, err := parseDecimal("minNotional", filter.MinNotional)
+    if err != nil {
+        return err
+    }
+    if notional.LessThan(min) {
+        return f.invalid(SymbolFilterTypeMinNotional, param, notional, "below min notional %s", filter.MinNotional)
+    }
+    return nil
+}
+
+// roundPrice round a price to the tick size
+func (f orderFilters) roundPrice(price common.Decimal, mode common.RoundingMode) (common.Decimal, error) {
+    tick, err := f.symbol.TickSizeDecimal()
+    if err != nil {
+        return price, err
+    }
+    return price.RoundToStep(tick, mode), nil
+}
+
+// roundQuantity round a quantity to the step size of the order type
+func (f orderFilters) roundQuantity(orderType OrderType, quantity common.Decimal, mode common.RoundingMode) (common.Decimal, error) {
+    filter := f.lotSize(orderType)
+    if filter == nil {
+        return quantity, nil
+    }
+    step, err := parseDecimal("stepSize", filter.StepSize)
+    if err != nil {
+        return quantity, err
+    }
+    return quantity.RoundToStep(step, mode), nil
+}
+
+// FilterSymbol check the order against the trading rules of its symbol
+// before sending it, it then fails with an *OrderFilterError. The rules are
+// taken from the symbol cache of the client, see Client.SetSymbolCache.
+func (s *CreateOrderService) FilterSymbol() *CreateOrderService {
+    s.filterSymbol = true
+    s.rounding = nil
+    return s
+}
+
+// RoundToSymbol round the price, stop price and quantity to the tick and
+// step sizes of the symbol with rounding, then check the order like
+// FilterSymbol. It replaces common.AmountToLotSize.
+func (s *CreateOrderService) RoundToSymbol(rounding OrderRounding) *CreateOrderService {
+    s.filterSymbol = true
+    s.rounding = &rounding
+    return s
+}
+
+// applyFilters round the order if asked and check it against its symbol
+func (s *CreateOrderService) applyFilters(ctx context.Context) error {
+    // the cache may be shared, refresh it with the client sending the order
+    symbol, err := s.c.symbols().symbol(ctx, s.c, s.symbol)
+    if err != nil {
+        return err
+    }
+    f := orderFilters{symbol: symbol}
+    if !symbol.IsTrading() {
+        return &OrderFilterError{Symbol: symbol.Symbol, Param: "status", Value: string(symbol.Status), Reason: "not trading"}
+    }
+    orderType := s.sentType()
+    decimal := func(name string, v *string) (common.Decimal, error) {
+        if v == nil {
+            return common.Decimal{}, nil
+        }
+        return parseDecimal(name, *v)
+    }
+    price, err := decimal("price", s.price)
+    if err != nil {
+        return err
+    }
+    stopPrice, err := decimal("stopPrice", s.stopPrice)
+    if err != nil {
+        return err
+    }
+    quantity, err := decimal("quantity", s.quantity)
+    if err != nil {
+        return err
+    }
+    quoteOrderQty, err := decimal("quoteOrderQty", s.quoteOrderQty)
+    if err != nil {
+        return err
+    }
+
+    if s.rounding != nil {
+        priceMode, quantityMode := s.rounding.BuyPrice, s.rounding.BuyQuantity
+        if s.side == SideTypeSell {
+            priceMode, quantityMode = s.rounding.SellPrice, s.rounding.SellQuantity
+        }
+        if price, err = f.roundPrice(price, priceMode); err != nil {
+            return err
+        }
+        if stopPrice, err = f.roundPrice(stopPrice, priceMode); err != nil {
+            return err
+        }
+        if quantity, err = f.roundQuantity(orderType, quantity, quantityMode); err != nil {
+            return err
+        }
+        if s.price != nil {
+            s.PriceDecimal(price)
+        }
+        if s.stopPrice != nil {
+            s.StopPriceDecimal(stopPrice)
+        }
+        if s.quantity != nil {
+            s.QuantityDecimal(quantity)
+        }
+    }
+
+    if s.price != nil {
+        if err = f.checkPrice("price", price); err != nil {
+            return err
+        }
+    }
+    if s.stopPrice != nil {
+        if err = f.checkPrice("stopPrice", stopPrice); err != nil {
+            return err
+        }
+    }
+    if s.quantity != nil {
+        if err = f.checkQuantity(orderType, quantity); err != nil {
+            return err
+        }
+    }
+    // the value of market orders is only known from their quote quantity
+    switch {
+    case s.quoteOrderQty != nil:
+        return f.checkNotional("quoteOrderQty", quoteOrderQty)
+    case orderType == OrderTypeMarket:
+        return nil
+    case s.price != nil:
+        return f.checkNotional("notional", price.Mul(quantity))
+    default:
+        return f.checkNotional("notional", stopPrice.Mul(quantity))
+    }
+}
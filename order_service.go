@@ -1,20 +1,58 @@
 package bitnut
 
 import (
+    "bytes"
     "context"
+    "errors"
+    "fmt"
     "net/http"
+
+    "github.com/hardyzp/bitnut/common"
+    jsoniter "github.com/json-iterator/go"
 )
 
-// CreateOrderService create order
+// ErrInvalidOrder is matched by the errors of orders refused before they
+// are sent
+var ErrInvalidOrder = errors.New("bitnut: invalid order")
+
+// OrderParamsError is returned by CreateOrderService when a parameter is
+// missing, invalid or not allowed with the others
+type OrderParamsError struct {
+    Param  string
+    Reason string
+}
+
+// Error return the parameter and what is wrong with it
+func (e *OrderParamsError) Error() string {
+    return fmt.Sprintf("<OrderParamsError> param=%s, %s", e.Param, e.Reason)
+}
+
+// Unwrap make OrderParamsError match ErrInvalidOrder
+func (e *OrderParamsError) Unwrap() error {
+    return ErrInvalidOrder
+}
+
+// CreateOrderService create order.
+// A client order id is generated when none is set, so that a submission
+// which failed ambiguously can be looked up before it is sent again.
 type CreateOrderService struct {
     c                *Client
     symbol           string
     side             SideType
     orderType        OrderType
+    timeInForce      *TimeInForceType
     quantity         *string
     quoteOrderQty    *string
     price            *string
+    stopPrice        *string
+    icebergQty       *string
+    postOnly         bool
     newClientOrderID *string
+    newOrderRespType *NewOrderRespType
+    filterSymbol     bool
+    rounding         *OrderRounding
+    retryPolicy      *RetryPolicy
+    retryPolicySet   bool
 }
 
 // Symbol set symbol
@@ -41,34 +79,203 @@ func (s *CreateOrderService) Quantity(quantity string) *CreateOrderService {
     return s
 }
 
+// QuantityDecimal set quantity from a Decimal
+func (s *CreateOrderService) QuantityDecimal(quantity common.Decimal) *CreateOrderService {
+    return s.Quantity(quantity.String())
+}
+
 // QuoteOrderQty set quoteOrderQty
 func (s *CreateOrderService) QuoteOrderQty(quoteOrderQty string) *CreateOrderService {
     s.quoteOrderQty = &quoteOrderQty
     return s
 }
 
+// QuoteOrderQtyDecimal set quoteOrderQty from a Decimal
+func (s *CreateOrderService) QuoteOrderQtyDecimal(quoteOrderQty common.Decimal) *CreateOrderService {
+    return s.QuoteOrderQty(quoteOrderQty.String())
+}
+
 // Price set price
 func (s *CreateOrderService) Price(price string) *CreateOrderService {
     s.price = &price
     return s
 }
 
+// PriceDecimal set price from a Decimal
+func (s *CreateOrderService) PriceDecimal(price common.Decimal) *CreateOrderService {
+    return s.Price(price.String())
+}
+
+// TimeInForce set timeInForce
+func (s *CreateOrderService) TimeInForce(timeInForce TimeInForceType) *CreateOrderService {
+    s.timeInForce = &timeInForce
+    return s
+}
+
+// StopPrice set stopPrice, the trigger price of stop loss and take profit
+// orders
+func (s *CreateOrderService) StopPrice(stopPrice string) *CreateOrderService {
+    s.stopPrice = &stopPrice
+    return s
+}
+
+// StopPriceDecimal set stopPrice from a Decimal
+func (s *CreateOrderService) StopPriceDecimal(stopPrice common.Decimal) *CreateOrderService {
+    return s.StopPrice(stopPrice.String())
+}
+
+// IcebergQuantity set icebergQty, the visible quantity of a limit order
+func (s *CreateOrderService) IcebergQuantity(icebergQty string) *CreateOrderService {
+    s.icebergQty = &icebergQty
+    return s
+}
+
+// IcebergQuantityDecimal set icebergQty from a Decimal
+func (s *CreateOrderService) IcebergQuantityDecimal(icebergQty common.Decimal) *CreateOrderService {
+    return s.IcebergQuantity(icebergQty.String())
+}
+
+// PostOnly make a limit order maker only, it is sent as a LIMIT_MAKER order
+// and rejected by the exchange if it would trade immediately
+func (s *CreateOrderService) PostOnly(postOnly bool) *CreateOrderService {
+    s.postOnly = postOnly
+    return s
+}
+
+// NewOrderRespType set newOrderRespType: ACK returns the order ids, RESULT
+// adds the order state and FULL the immediate fills
+func (s *CreateOrderService) NewOrderRespType(newOrderRespType NewOrderRespType) *CreateOrderService {
+    s.newOrderRespType = &newOrderRespType
+    return s
+}
+
 // NewClientOrderID set newClientOrderID
 func (s *CreateOrderService) NewClientOrderID(newClientOrderID string) *CreateOrderService {
     s.newClientOrderID = &newClientOrderID
     return s
 }
 
-func (s *CreateOrderService) createOrder(ctx context.Context, endpoint string, opts ...RequestOption) (data []byte, err error) {
+// RetryPolicy override the client retry policy for this order,
+// pass nil to disable retries
+func (s *CreateOrderService) RetryPolicy(policy *RetryPolicy) *CreateOrderService {
+    s.retryPolicy = policy
+    s.retryPolicySet = true
+    return s
+}
+
+// sentType return the order type sent, post only limit orders are
+// LIMIT_MAKER orders
+func (s *CreateOrderService) sentType() OrderType {
+    if s.postOnly && s.orderType == OrderTypeLimit {
+        return OrderTypeLimitMaker
+    }
+    return s.orderType
+}
+
+// validate check that the parameters go together, the way the exchange
+// does for the order type
+func (s *CreateOrderService) validate() error {
+    invalid := func(param, reason string, args ...interface{}) error {
+        return &OrderParamsError{Param: param, Reason: fmt.Sprintf(reason, args...)}
+    }
+    if s.symbol == "" {
+        return invalid("symbol", "missing")
+    }
+    if s.side != SideTypeBuy && s.side != SideTypeSell {
+        return invalid("side", "invalid side %q", s.side)
+    }
+    orderType := s.sentType()
+    var needsPrice, needsStopPrice, limit bool
+    switch orderType {
+    case OrderTypeLimit, OrderTypeLimitMaker:
+        needsPrice, limit = true, true
+    case OrderTypeMarket:
+    case OrderTypeStopLoss, OrderTypeTakeProfit:
+        needsStopPrice = true
+    case OrderTypeStopLossLimit, OrderTypeTakeProfitLimit:
+        needsPrice, needsStopPrice, limit = true, true, true
+    default:
+        return invalid("type", "invalid order type %q", s.orderType)
+    }
+    if s.postOnly && orderType != OrderTypeLimitMaker {
+        return invalid("postOnly", "not allowed on %s orders", orderType)
+    }
+
+    switch {
+    case s.quoteOrderQty != nil && orderType != OrderTypeMarket:
+        return invalid("quoteOrderQty", "only allowed on MARKET orders")
+    case s.quoteOrderQty != nil && s.quantity != nil:
+        return invalid("quoteOrderQty", "not allowed with quantity")
+    case s.quantity == nil && s.quoteOrderQty == nil:
+        return invalid("quantity", "missing")
+    }
+    if needsPrice != (s.price != nil) {
+        if needsPrice {
+            return invalid("price", "%s orders need a price", orderType)
+        }
+        return invalid("price", "not allowed on %s orders", orderType)
+    }
+    if needsStopPrice != (s.stopPrice != nil) {
+        if needsStopPrice {
+            return invalid("stopPrice", "%s orders need a stop price", orderType)
+        }
+        return invalid("stopPrice", "not allowed on %s orders", orderType)
+    }
+
+    if s.timeInForce != nil {
+        switch *s.timeInForce {
+        case TimeInForceTypeGTC, TimeInForceTypeIOC, TimeInForceTypeFOK:
+        default:
+            return invalid("timeInForce", "invalid time in force %q", *s.timeInForce)
+        }
+        if !limit || orderType == OrderTypeLimitMaker {
+            return invalid("timeInForce", "not allowed on %s orders", orderType)
+        }
+    }
+    if s.icebergQty != nil {
+        if !limit {
+            return invalid("icebergQty", "not allowed on %s orders", orderType)
+        }
+        if s.timeInForce != nil && *s.timeInForce != TimeInForceTypeGTC {
+            return invalid("icebergQty", "needs GTC time in force")
+        }
+        iceberg, err := common.ParseDecimal(*s.icebergQty)
+        if err != nil {
+            return invalid("icebergQty", "%v", err)
+        }
+        quantity, err := common.ParseDecimal(*s.quantity)
+        if err != nil {
+            return invalid("quantity", "%v", err)
+        }
+        if iceberg.Sign() <= 0 || !iceberg.LessThan(quantity) {
+            return invalid("icebergQty", "%s must be positive and below quantity %s", *s.icebergQty, *s.quantity)
+        }
+    }
+    if s.newOrderRespType != nil {
+        switch *s.newOrderRespType {
+        case NewOrderRespTypeACK, NewOrderRespTypeRESULT, NewOrderRespTypeFULL:
+        default:
+            return invalid("newOrderRespType", "invalid response type %q", *s.newOrderRespType)
+        }
+    }
+    return nil
+}
+
+func (s *CreateOrderService) createOrder(ctx context.Context, endpoint string, clientOrderID string, opts ...RequestOption) (data []byte, err error) {
     r := &request{
         method:   http.MethodPost,
         endpoint: endpoint,
         secType:  secTypeSigned,
+        noRetry:  true,
+        trading:  true,
     }
     m := params{
         "symbol": s.symbol,
         "side":   s.side,
-        "type":   s.orderType,
+        "type":   s.sentType(),
+    }
+    if s.timeInForce != nil {
+        m["timeInForce"] = *s.timeInForce
     }
     if s.quantity != nil {
         m["quantity"] = *s.quantity
@@ -76,13 +283,19 @@ func (s *CreateOrderService) createOrder(ctx context.Context, endpoint string, o
     if s.quoteOrderQty != nil {
         m["quoteOrderQty"] = *s.quoteOrderQty
     }
-
     if s.price != nil {
         m["price"] = *s.price
     }
-    if s.newClientOrderID != nil {
-        m["clientOid"] = *s.newClientOrderID
+    if s.stopPrice != nil {
+        m["stopPrice"] = *s.stopPrice
+    }
+    if s.icebergQty != nil {
+        m["icebergQty"] = *s.icebergQty
+    }
+    if s.newOrderRespType != nil {
+        m["newOrderRespType"] = *s.newOrderRespType
     }
+    m["clientOid"] = clientOrderID
 
     r.setFormParams(m)
     data, err = s.c.callAPI(ctx, r, opts...)
@@ -92,25 +305,144 @@ func (s *CreateOrderService) createOrder(ctx context.Context, endpoint string, o
     return data, nil
 }
 
-// Do send request
+// Do send request. The parameters are checked first, an invalid order
+// returns an *OrderParamsError, or an *OrderFilterError with FilterSymbol,
+// without being sent. When the submission fails with a retryable error the
+// order is looked up by its client order id, and it is only submitted again
+// if the exchange does not know it. A submission that may have reached the
+// exchange without a known outcome returns an *AmbiguousOrderError.
 func (s *CreateOrderService) Do(ctx context.Context, opts ...RequestOption) (res *CreateOrderResponse, err error) {
-    data, err := s.createOrder(ctx, "/v1/trade/order", opts...)
+    err = s.validate()
     if err != nil {
         return nil, err
     }
-    res = new(CreateOrderResponse)
-    err = json.Unmarshal(data, res)
+    if s.filterSymbol {
+        err = s.applyFilters(ctx)
+        if err != nil {
+            return nil, err
+        }
+    }
+    clientOrderID := newClientOrderID()
+    if s.newClientOrderID != nil {
+        clientOrderID = *s.newClientOrderID
+    }
+    policy := s.c.RetryPolicy
+    if s.retryPolicySet {
+        policy = s.retryPolicy
+    }
+    var data []byte
+    for attempt := 1; ; attempt++ {
+        data, err = s.createOrder(ctx, "/v1/trade/order", clientOrderID, opts...)
+        if err == nil {
+            break
+        }
+        if attempt > 1 && errors.Is(err, common.ErrDuplicateOrder) {
+            // an earlier submission landed after the lookup missed it
+            order, lerr := s.c.NewGetOrderService().Symbol(s.symbol).OrigClientOrderID(clientOrderID).Do(ctx)
+            if lerr != nil {
+                return nil, &AmbiguousOrderError{ClientOrderID: clientOrderID, Err: err, LookupErr: lerr}
+            }
+            return orderResponse(order), nil
+        }
+        if !policy.shouldRetry(attempt, err) {
+            if isTransientError(err) {
+                return nil, &AmbiguousOrderError{ClientOrderID: clientOrderID, Err: err}
+            }
+            return nil, err
+        }
+        if werr := policy.wait(ctx, attempt); werr != nil {
+            return nil, &AmbiguousOrderError{ClientOrderID: clientOrderID, Err: err, LookupErr: werr}
+        }
+        order, lerr := s.c.NewGetOrderService().Symbol(s.symbol).OrigClientOrderID(clientOrderID).Do(ctx)
+        if lerr == nil {
+            return orderResponse(order), nil
+        }
+        if !errors.Is(lerr, common.ErrUnknownOrder) {
+            return nil, &AmbiguousOrderError{ClientOrderID: clientOrderID, Err: err, LookupErr: lerr}
+        }
+        s.c.log(LogLevelInfo, "order not found after failed submission, submitting again",
+            F("clientOid", clientOrderID),
+            F("error", err),
+        )
+    }
+    var raw jsoniter.RawMessage
+    code, msg, err := decodeResponse(data, &raw)
     if err != nil {
         return nil, err
     }
+    res = new(CreateOrderResponse)
+    if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+        // short acknowledgement, the order id alone
+        var ids []string
+        err = json.Unmarshal(trimmed, &ids)
+        if err != nil {
+            return nil, err
+        }
+        if len(ids) > 0 {
+            res.OrderID = ids[0]
+        }
+    } else if len(raw) > 0 {
+        err = json.Unmarshal(raw, res)
+        if err != nil {
+            return nil, err
+        }
+    }
+    res.Code, res.Msg = code, msg
+    if res.Symbol == "" {
+        res.Symbol = s.symbol
+    }
+    if res.ClientOrderID == "" {
+        res.ClientOrderID = clientOrderID
+    }
+    if res.Side == "" {
+        res.Side = s.side
+    }
+    if res.Type == "" {
+        res.Type = s.sentType()
+    }
+    for _, fill := range res.Fills {
+        fill.Symbol, fill.OrderID, fill.ClientOrderID, fill.Side = res.Symbol, res.OrderID, res.ClientOrderID, res.Side
+        if fill.Time == 0 {
+            fill.Time = res.TransactTime
+        }
+    }
     return res, nil
 }
 
-// CreateOrderResponse define create order response
+// CreateOrderResponse define create order response. ACK responses carry
+// the order ids, RESULT responses add the order state and FULL responses
+// the fills of the order at submission.
 type CreateOrderResponse struct {
-    Code int      `json:"code"`
-    Msg  string   `json:"msg"`
-    Data []string `json:"data"`
+    Code             int             `json:"code"`
+    Msg              string          `json:"msg"`
+    Symbol           string          `json:"symbol"`
+    OrderID          string          `json:"orderId"`
+    ClientOrderID    string          `json:"clientOrderId"`
+    TransactTime     int64           `json:"transactTime"`
+    Price            string          `json:"price"`
+    OrigQuantity     string          `json:"origQty"`
+    ExecutedQuantity string          `json:"executedQty"`
+    Status           OrderStatusType `json:"status"`
+    TimeInForce      TimeInForceType `json:"timeInForce"`
+    Type             OrderType       `json:"type"`
+    Side             SideType        `json:"side"`
+    Fills            []*OrderFill    `json:"fills"`
+}
+
+// orderResponse return the response of an order found after a failed
+// submission, its fills are not known
+func orderResponse(o *Order) *CreateOrderResponse {
+    return &CreateOrderResponse{
+        Symbol:           o.Symbol,
+        OrderID:          o.OrderID,
+        ClientOrderID:    o.ClientOrderID,
+        TransactTime:     o.Time,
+        Price:            o.Price,
+        OrigQuantity:     o.OrigQuantity,
+        ExecutedQuantity: o.ExecutedQuantity,
+        Status:           o.Status,
+        Side:             o.Side,
+    }
 }
 
 // GetOrderService get an order
@@ -160,18 +492,12 @@ func (s *GetOrderService) Do(ctx context.Context, opts ...RequestOption) (res *O
     if err != nil {
         return nil, err
     }
-    ret := new(orderResponse)
-    err = json.Unmarshal(data, &ret)
+    res = new(Order)
+    _, _, err = decodeResponse(data, res)
     if err != nil {
         return nil, err
     }
-    return &ret.Data, nil
-}
-
-type orderResponse struct {
-    Code int    `json:"code"`
-    Msg  string `json:"msg"`
-    Data Order  `json:"data"`
+    return res, nil
 }
 
 // Order define order info
@@ -263,14 +589,11 @@ func (s *ListOrdersService) Do(ctx context.Context, opts ...RequestOption) (res
     if err != nil {
         return ListOrderResponse{}, err
     }
-    //res = make([]*Order, 0)
-    var ret ListOrderResponse
-
-    err = json.Unmarshal(data, &ret)
+    res.Code, res.Msg, err = decodeResponse(data, &res.Data)
     if err != nil {
         return ListOrderResponse{}, err
     }
-    return ret, nil
+    return res, nil
 }
 
 // CancelOrderService cancel an order
@@ -305,6 +628,7 @@ func (s *CancelOrderService) Do(ctx context.Context, opts ...RequestOption) (res
         method:   http.MethodPost,
         endpoint: "/v1/trade/cancel",
         secType:  secTypeSigned,
+        trading:  true,
     }
     r.setFormParam("symbol", s.symbol)
     if s.orderId != nil {
@@ -315,7 +639,7 @@ func (s *CancelOrderService) Do(ctx context.Context, opts ...RequestOption) (res
         return nil, err
     }
     res = new(CancelOrderResponse)
-    err = json.Unmarshal(data, res)
+    res.Code, res.Msg, err = decodeResponse(data, &res.Data)
     if err != nil {
         return nil, err
     }
@@ -340,6 +664,7 @@ func (s *CancelOpenOrdersService) Do(ctx context.Context, opts ...RequestOption)
         method:   http.MethodPost,
         endpoint: "/v1/trade/open-cancel",
         secType:  secTypeSigned,
+        trading:  true,
     }
     r.setFormParam("symbol", s.symbol)
     data, err := s.c.callAPI(ctx, r, opts...)
@@ -347,7 +672,7 @@ func (s *CancelOpenOrdersService) Do(ctx context.Context, opts ...RequestOption)
         return nil, err
     }
     res = new(CancelOrderResponse)
-    err = json.Unmarshal(data, res)
+    res.Code, res.Msg, err = decodeResponse(data, &res.Data)
     if err != nil {
         return nil, err
     }
@@ -367,3 +692,18 @@ type ListOrderResponse struct {
     Msg  string  `json:"msg"`
     Data []Order `json:"data"`
 }
+
+// PriceDecimal return price as a Decimal, empty parses as zero
+func (o *Order) PriceDecimal() (common.Decimal, error) {
+    return parseDecimal("price", o.Price)
+}
+
+// OrigQuantityDecimal return orig quantity as a Decimal, empty parses as zero
+func (o *Order) OrigQuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("origQty", o.OrigQuantity)
+}
+
+// ExecutedQuantityDecimal return executed quantity as a Decimal, empty parses as zero
+func (o *Order) ExecutedQuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("executedQty", o.ExecutedQuantity)
+}
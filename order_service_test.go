@@ -0,0 +1,209 @@
+package bitnut_test
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/hardyzp/bitnut"
+    "github.com/hardyzp/bitnut/bitnuttest"
+    "github.com/hardyzp/bitnut/common"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestCreateOrderValidation(t *testing.T) {
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    c := srv.Client()
+    limit := func() *bitnut.CreateOrderService {
+        return c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeLimit).Quantity("1")
+    }
+    market := func() *bitnut.CreateOrderService {
+        return c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeSell).Type(bitnut.OrderTypeMarket)
+    }
+    tests := []struct {
+        name  string
+        order *bitnut.CreateOrderService
+        param string
+    }{
+        {"missing side", c.NewCreateOrderService().Symbol("BTCUSDT").Type(bitnut.OrderTypeMarket).Quantity("1"), "side"},
+        {"unknown type", limit().Type("OCO"), "type"},
+        {"limit without price", limit(), "price"},
+        {"market with price", market().Quantity("1").Price("10"), "price"},
+        {"missing quantity", market(), "quantity"},
+        {"quote quantity on limit", limit().Price("10").QuoteOrderQty("10"), "quoteOrderQty"},
+        {"quote quantity with quantity", market().Quantity("1").QuoteOrderQty("10"), "quoteOrderQty"},
+        {"stop loss without stop price", market().Type(bitnut.OrderTypeStopLoss).Quantity("1"), "stopPrice"},
+        {"stop price on limit", limit().Price("10").StopPrice("9"), "stopPrice"},
+        {"time in force on market", market().Quantity("1").TimeInForce(bitnut.TimeInForceTypeIOC), "timeInForce"},
+        {"unknown time in force", limit().Price("10").TimeInForce("GTD"), "timeInForce"},
+        {"post only market", market().Quantity("1").PostOnly(true), "postOnly"},
+        {"post only with time in force", limit().Price("10").PostOnly(true).TimeInForce(bitnut.TimeInForceTypeGTC), "timeInForce"},
+        {"iceberg on market", market().Quantity("1").IcebergQuantity("0.1"), "icebergQty"},
+        {"iceberg with IOC", limit().Price("10").IcebergQuantity("0.1").TimeInForce(bitnut.TimeInForceTypeIOC), "icebergQty"},
+        {"iceberg above quantity", limit().Price("10").IcebergQuantity("2"), "icebergQty"},
+        {"unknown response type", limit().Price("10").NewOrderRespType("SHORT"), "newOrderRespType"},
+    }
+    for _, test := range tests {
+        _, err := test.order.Do(context.Background())
+        var paramsErr *bitnut.OrderParamsError
+        if assert.True(t, errors.As(err, &paramsErr), test.name) {
+            assert.Equal(t, test.param, paramsErr.Param, test.name)
+            assert.ErrorIs(t, err, bitnut.ErrInvalidOrder, test.name)
+        }
+    }
+    assert.Equal(t, 0, srv.Requests("/v1/trade/order"))
+}
+
+func TestCreateOrderResponses(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    c := srv.Client()
+    ctx := context.Background()
+    srv.SetBalance("USDT", "100000", "0")
+    srv.SetTicker(&bitnut.SymbolTicker{Symbol: "BTCUSDT", LastPrice: "40000"})
+
+    res, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeMarket).
+        Quantity("0.5").NewClientOrderID("market").Do(ctx)
+    assert.NoError(err)
+    assert.Equal(bitnut.OrderStatusTypeFilled, res.Status)
+    assert.Equal("0.5", res.ExecutedQuantity)
+    assert.Len(res.Fills, 1)
+    assert.Equal(&bitnut.OrderFill{
+        Symbol: "BTCUSDT", OrderID: res.OrderID, ClientOrderID: "market", TradeID: 1, Side: bitnut.SideTypeBuy,
+        Price: "40000", Quantity: "0.5", Commission: "0", CommissionAsset: "USDT", Time: res.TransactTime,
+    }, res.Fills[0])
+
+    res, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeLimit).
+        Quantity("0.5").Price("39000").PostOnly(true).NewOrderRespType(bitnut.NewOrderRespTypeACK).Do(ctx)
+    assert.NoError(err)
+    assert.NotEmpty(res.OrderID)
+    assert.NotEmpty(res.ClientOrderID)
+    assert.Equal(bitnut.OrderTypeLimitMaker, res.Type)
+    assert.Empty(res.Status)
+
+    res, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeLimit).
+        Quantity("0.5").Price("39000").TimeInForce(bitnut.TimeInForceTypeIOC).NewOrderRespType(bitnut.NewOrderRespTypeRESULT).Do(ctx)
+    assert.NoError(err)
+    assert.Equal(bitnut.OrderStatusTypeExpired, res.Status)
+    assert.Equal(bitnut.TimeInForceTypeIOC, res.TimeInForce)
+    // only the post only order still holds funds
+    assert.Equal("60500", srv.Balance("USDT").Free)
+
+    res, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeStopLossLimit).
+        Quantity("0.1").Price("41000").StopPrice("40500").IcebergQuantity("0.05").NewOrderRespType(bitnut.NewOrderRespTypeRESULT).Do(ctx)
+    assert.NoError(err)
+    assert.Equal(bitnut.OrderStatusTypeNew, res.Status)
+    assert.Equal(bitnut.OrderTypeStopLossLimit, res.Type)
+    assert.Equal("56400", srv.Balance("USDT").Free)
+
+    // a market order sized in quote coin gets the quantity it buys at the ticker price
+    res, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeMarket).
+        QuoteOrderQty("1000.01").Do(ctx)
+    assert.NoError(err)
+    assert.Equal(bitnut.OrderStatusTypeFilled, res.Status)
+    assert.Equal("0.025", res.ExecutedQuantity)
+    assert.Equal("55400", srv.Balance("USDT").Free)
+}
+
+func TestCreateOrderFilters(t *testing.T) {
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    c := srv.Client()
+    limit := func(side bitnut.SideType, price, quantity string) *bitnut.CreateOrderService {
+        return c.NewCreateOrderService().Symbol("BTCUSDT").Side(side).Type(bitnut.OrderTypeLimit).
+            Price(price).Quantity(quantity).FilterSymbol()
+    }
+    tests := []struct {
+        name   string
+        order  *bitnut.CreateOrderService
+        filter bitnut.SymbolFilterType
+        param  string
+    }{
+        {"price off tick", limit(bitnut.SideTypeBuy, "40000.005", "0.1"), bitnut.SymbolFilterTypePrice, "price"},
+        {"quantity off step", limit(bitnut.SideTypeBuy, "40000", "0.1000001"), bitnut.SymbolFilterTypeLotSize, "quantity"},
+        {"quantity above max", limit(bitnut.SideTypeBuy, "1", "9001"), bitnut.SymbolFilterTypeLotSize, "quantity"},
+        {"below min notional", limit(bitnut.SideTypeBuy, "40000", "0.0001"), bitnut.SymbolFilterTypeMinNotional, "notional"},
+        {"rounded below min quantity", limit(bitnut.SideTypeSell, "40000", "0.0000001").RoundToSymbol(bitnut.DefaultOrderRounding()), bitnut.SymbolFilterTypeLotSize, "quantity"},
+        {"market quote below min notional", c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeMarket).QuoteOrderQty("1").FilterSymbol(), bitnut.SymbolFilterTypeMinNotional, "quoteOrderQty"},
+    }
+    for _, test := range tests {
+        _, err := test.order.Do(context.Background())
+        var filterErr *bitnut.OrderFilterError
+        if assert.True(t, errors.As(err, &filterErr), test.name) {
+            assert.Equal(t, test.filter, filterErr.Filter, test.name)
+            assert.Equal(t, test.param, filterErr.Param, test.name)
+            assert.ErrorIs(t, err, bitnut.ErrInvalidOrder, test.name)
+        }
+    }
+    // the symbols were fetched on the first check, the others hit the cache
+    assert.Equal(t, 1, srv.Requests("/v1/exchangeInfo"))
+
+    // a symbol missing from the cache is fetched again once
+    _, err := c.NewCreateOrderService().Symbol("ETHUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeMarket).
+        Quantity("1").FilterSymbol().Do(context.Background())
+    assert.ErrorIs(t, err, common.ErrInvalidSymbol)
+    assert.Equal(t, 2, srv.Requests("/v1/exchangeInfo"))
+    assert.Equal(t, 0, srv.Requests("/v1/trade/order"))
+    // and then known missing until the next refresh
+    _, err = c.NewCreateOrderService().Symbol("ETHUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeMarket).
+        Quantity("1").FilterSymbol().Do(context.Background())
+    assert.ErrorIs(t, err, common.ErrInvalidSymbol)
+    assert.Equal(t, 2, srv.Requests("/v1/exchangeInfo"))
+
+    // a cache filled by the caller is used without fetching
+    info, err := c.NewExchangeInfoService().Do(context.Background())
+    assert.NoError(t, err)
+    cache := c.NewSymbolCache(time.Hour)
+    cache.Set(info)
+    other := srv.Client().SetSymbolCache(cache)
+    fetched := srv.Requests("/v1/exchangeInfo")
+    _, err = other.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeLimit).
+        Price("40000.005").Quantity("0.1").FilterSymbol().Do(context.Background())
+    assert.ErrorIs(t, err, bitnut.ErrInvalidOrder)
+    assert.Equal(t, fetched, srv.Requests("/v1/exchangeInfo"))
+
+    // a shared cache is refreshed by the client sending the order
+    elsewhere := bitnuttest.NewServer()
+    defer elsewhere.Close()
+    shared := elsewhere.Client().SetSymbolCache(c.NewSymbolCache(time.Hour))
+    _, err = shared.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeLimit).
+        Price("40000.005").Quantity("0.1").FilterSymbol().Do(context.Background())
+    assert.ErrorIs(t, err, bitnut.ErrInvalidOrder)
+    assert.Equal(t, 1, elsewhere.Requests("/v1/exchangeInfo"))
+    assert.Equal(t, fetched, srv.Requests("/v1/exchangeInfo"))
+}
+
+func TestCreateOrderRounding(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    c := srv.Client()
+    ctx := context.Background()
+    srv.SetBalance("USDT", "100000", "0")
+    srv.SetBalance("BTC", "10", "0")
+
+    res, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeLimit).
+        Price("39999.999").Quantity("0.1234567").RoundToSymbol(bitnut.DefaultOrderRounding()).
+        NewOrderRespType(bitnut.NewOrderRespTypeRESULT).Do(ctx)
+    assert.NoError(err)
+    assert.Equal("39999.99", res.Price)
+    assert.Equal("0.123456", res.OrigQuantity)
+
+    res, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeSell).Type(bitnut.OrderTypeLimit).
+        Price("40000.001").Quantity("0.1234567").RoundToSymbol(bitnut.DefaultOrderRounding()).
+        NewOrderRespType(bitnut.NewOrderRespTypeRESULT).Do(ctx)
+    assert.NoError(err)
+    assert.Equal("40000.01", res.Price)
+    assert.Equal("0.123456", res.OrigQuantity)
+
+    rounding := bitnut.DefaultOrderRounding()
+    rounding.BuyQuantity = common.RoundUp
+    res, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).Type(bitnut.OrderTypeLimit).
+        Price("30000").Quantity("0.0001661").RoundToSymbol(rounding).
+        NewOrderRespType(bitnut.NewOrderRespTypeRESULT).Do(ctx)
+    assert.NoError(err)
+    assert.Equal("0.000167", res.OrigQuantity)
+}
@@ -0,0 +1,282 @@
+package bitnut
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/hardyzp/bitnut/common"
+)
+
+// RateLimitBucket define which budget a request is counted against
+type RateLimitBucket int
+
+// Rate limit buckets
+const (
+    RateLimitBucketGeneral RateLimitBucket = iota
+    RateLimitBucketOrder
+)
+
+// RateLimit define how much weight a bucket may spend per interval
+type RateLimit struct {
+    Limit    int
+    Interval time.Duration
+}
+
+// EndpointWeight define the cost of one call to an endpoint
+type EndpointWeight struct {
+    Weight int
+    Bucket RateLimitBucket
+}
+
+// RateLimiterConfig define the limits and endpoint weights of a RateLimiter
+type RateLimiterConfig struct {
+    General RateLimit
+    Order   RateLimit
+    // Weights of the known endpoints, endpoints missing here cost
+    // DefaultWeight on the general bucket.
+    Weights       map[string]EndpointWeight
+    DefaultWeight int
+    // Response headers reporting the weight already used in the current
+    // window, as counted by the server. Empty names are ignored.
+    UsedWeightHeader string
+    OrderCountHeader string
+}
+
+// DefaultRateLimiterConfig return the limits the exchange documents for an API key
+func DefaultRateLimiterConfig() RateLimiterConfig {
+    return RateLimiterConfig{
+        General: RateLimit{Limit: 1200, Interval: time.Minute},
+        Order:   RateLimit{Limit: 50, Interval: 10 * time.Second},
+        Weights: map[string]EndpointWeight{
+            "/v1/time":                  {Weight: 1},
+            "/v1/exchangeInfo":          {Weight: 10},
+            "/v1/tick/depth":            {Weight: 5},
+            "/v1/tick/kline":            {Weight: 1},
+            "/v1/tick/24info":           {Weight: 1},
+            "/v1/tick/trades":           {Weight: 1},
+            "/v1/tick/historicalTrades": {Weight: 5},
+            "/v1/tick/aggTrades":        {Weight: 1},
+            "/v1/trade/order":           {Weight: 1, Bucket: RateLimitBucketOrder},
+            "/v1/trade/cancel":          {Weight: 1},
+            "/v1/trade/open-cancel":     {Weight: 1},
+            "/v1/spot/user/order":       {Weight: 5},
+            "/v1/spot/user/orderInfo":   {Weight: 2},
+            "/v1/spot/user/trades":      {Weight: 10},
+            "/v1/asset/balance":         {Weight: 5},
+        },
+        DefaultWeight:    1,
+        UsedWeightHeader: "BU-USED-WEIGHT",
+        OrderCountHeader: "BU-ORDER-COUNT",
+    }
+}
+
+// RateLimitError is returned when a request would exceed the client-side
+// rate limit and the context does not allow waiting for it, or when its
+// weight is over the whole limit of its bucket and it can never be sent
+type RateLimitError struct {
+    Endpoint   string
+    RetryAfter time.Duration
+    // Weight and Limit are set when the weight is over the limit
+    Weight int
+    Limit  int
+}
+
+// Error return the endpoint and the wait that was refused
+func (e *RateLimitError) Error() string {
+    if e.Limit > 0 {
+        return fmt.Sprintf("<RateLimitError> endpoint=%s, weight %d over limit %d", e.Endpoint, e.Weight, e.Limit)
+    }
+    return fmt.Sprintf("<RateLimitError> endpoint=%s, retry after %s", e.Endpoint, e.RetryAfter)
+}
+
+// Unwrap make RateLimitError match common.ErrRateLimited
+func (e *RateLimitError) Unwrap() error {
+    return common.ErrRateLimited
+}
+
+type noWaitKey struct{}
+
+// WithoutRateLimitWait return a context in which requests that would exceed
+// the rate limit fail with a *RateLimitError instead of blocking
+func WithoutRateLimitWait(ctx context.Context) context.Context {
+    return context.WithValue(ctx, noWaitKey{}, true)
+}
+
+type rateBucket struct {
+    limit       RateLimit
+    windowStart time.Time
+    used        int
+}
+
+func (b *rateBucket) roll(now time.Time) {
+    start := now.Truncate(b.limit.Interval)
+    if !start.Equal(b.windowStart) {
+        b.windowStart = start
+        b.used = 0
+    }
+}
+
+// RateLimiter count request weights in fixed windows and delays requests
+// that would go over the limits of their bucket
+type RateLimiter struct {
+    mu           sync.Mutex
+    config       RateLimiterConfig
+    buckets      map[RateLimitBucket]*rateBucket
+    blockedUntil time.Time
+    now          func() time.Time
+}
+
+// NewRateLimiter init a rate limiter with config
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+    weights := make(map[string]EndpointWeight, len(config.Weights))
+    for k, v := range config.Weights {
+        weights[k] = v
+    }
+    config.Weights = weights
+    return &RateLimiter{
+        config: config,
+        buckets: map[RateLimitBucket]*rateBucket{
+            RateLimitBucketGeneral: {limit: config.General},
+            RateLimitBucketOrder:   {limit: config.Order},
+        },
+        now: time.Now,
+    }
+}
+
+// SetWeight set the weight of an endpoint
+func (l *RateLimiter) SetWeight(endpoint string, weight EndpointWeight) *RateLimiter {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.config.Weights[endpoint] = weight
+    return l
+}
+
+// Weight return the weight of an endpoint
+func (l *RateLimiter) Weight(endpoint string) EndpointWeight {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.weight(endpoint)
+}
+
+func (l *RateLimiter) weight(endpoint string) EndpointWeight {
+    if w, ok := l.config.Weights[endpoint]; ok {
+        return w
+    }
+    return EndpointWeight{Weight: l.config.DefaultWeight}
+}
+
+// Used return the weight spent in the current window of a bucket
+func (l *RateLimiter) Used(bucket RateLimitBucket) int {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    b, ok := l.buckets[bucket]
+    if !ok {
+        return 0
+    }
+    b.roll(l.now())
+    return b.used
+}
+
+// reserve take the weight of endpoint if it fits, otherwise it return how
+// long to wait before trying again
+func (l *RateLimiter) reserve(endpoint string) (time.Duration, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    now := l.now()
+    if now.Before(l.blockedUntil) {
+        return l.blockedUntil.Sub(now), nil
+    }
+    w := l.weight(endpoint)
+    b, ok := l.buckets[w.Bucket]
+    if !ok || b.limit.Limit <= 0 || b.limit.Interval <= 0 {
+        return 0, nil
+    }
+    if w.Weight > b.limit.Limit {
+        return 0, &RateLimitError{Endpoint: endpoint, Weight: w.Weight, Limit: b.limit.Limit}
+    }
+    b.roll(now)
+    if b.used+w.Weight > b.limit.Limit {
+        return b.windowStart.Add(b.limit.Interval).Sub(now), nil
+    }
+    b.used += w.Weight
+    return 0, nil
+}
+
+// Wait block until endpoint may be called. It return a *RateLimitError
+// without waiting when ctx was made with WithoutRateLimitWait, when ctx
+// expires before the wait would end or when the weight of endpoint is over
+// the limit of its bucket.
+func (l *RateLimiter) Wait(ctx context.Context, endpoint string) error {
+    for {
+        delay, err := l.reserve(endpoint)
+        if err != nil || delay <= 0 {
+            return err
+        }
+        if noWait, _ := ctx.Value(noWaitKey{}).(bool); noWait {
+            return &RateLimitError{Endpoint: endpoint, RetryAfter: delay}
+        }
+        if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+            return &RateLimitError{Endpoint: endpoint, RetryAfter: delay}
+        }
+        timer := time.NewTimer(delay)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return ctx.Err()
+        case <-timer.C:
+        }
+    }
+}
+
+// Update raise the local counts to the usage the server reports in res,
+// and honours its Retry-After header
+func (l *RateLimiter) Update(res *http.Response) {
+    if res == nil {
+        return
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    now := l.now()
+    correct := func(bucket RateLimitBucket, header string) {
+        if header == "" {
+            return
+        }
+        used, err := strconv.Atoi(res.Header.Get(header))
+        if err != nil {
+            return
+        }
+        b := l.buckets[bucket]
+        b.roll(now)
+        // the server has not counted the requests still in flight, so its
+        // count only ever raises the local one
+        if used > b.used {
+            b.used = used
+        }
+    }
+    correct(RateLimitBucketGeneral, l.config.UsedWeightHeader)
+    correct(RateLimitBucketOrder, l.config.OrderCountHeader)
+
+    if retryAfter := parseRetryAfter(res.Header.Get("Retry-After"), now); retryAfter > 0 {
+        if until := now.Add(retryAfter); until.After(l.blockedUntil) {
+            l.blockedUntil = until
+        }
+    }
+}
+
+// parseRetryAfter parse a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(v string, now time.Time) time.Duration {
+    if v == "" {
+        return 0
+    }
+    if secs, err := strconv.Atoi(v); err == nil {
+        return time.Duration(secs) * time.Second
+    }
+    if t, err := http.ParseTime(v); err == nil {
+        return t.Sub(now)
+    }
+    return 0
+}
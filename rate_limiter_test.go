@@ -0,0 +1,104 @@
+package bitnut
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "strconv"
+    "testing"
+    "time"
+
+    "github.com/hardyzp/bitnut/common"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+    assert := assert.New(t)
+    now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+    l := NewRateLimiter(RateLimiterConfig{
+        General: RateLimit{Limit: 10, Interval: time.Minute},
+        Order:   RateLimit{Limit: 2, Interval: 10 * time.Second},
+        Weights: map[string]EndpointWeight{
+            "/v1/tick/depth":  {Weight: 5},
+            "/v1/trade/order": {Weight: 1, Bucket: RateLimitBucketOrder},
+        },
+        DefaultWeight:    1,
+        UsedWeightHeader: "BU-USED-WEIGHT",
+    })
+    l.now = func() time.Time { return now }
+    ctx := WithoutRateLimitWait(context.Background())
+
+    assert.NoError(l.Wait(ctx, "/v1/tick/depth"))
+    assert.NoError(l.Wait(ctx, "/v1/tick/depth"))
+    err := l.Wait(ctx, "/v1/tick/depth")
+    assert.True(errors.Is(err, common.ErrRateLimited))
+    var rlErr *RateLimitError
+    assert.True(errors.As(err, &rlErr))
+    assert.Equal(time.Minute, rlErr.RetryAfter)
+
+    // the order bucket is counted separately
+    assert.NoError(l.Wait(ctx, "/v1/trade/order"))
+    assert.NoError(l.Wait(ctx, "/v1/trade/order"))
+    assert.Error(l.Wait(ctx, "/v1/trade/order"))
+    assert.Equal(2, l.Used(RateLimitBucketOrder))
+
+    // the server count raises the local one
+    now = now.Add(time.Minute)
+    res := &http.Response{Header: http.Header{}}
+    res.Header.Set("BU-USED-WEIGHT", "9")
+    l.Update(res)
+    assert.Equal(9, l.Used(RateLimitBucketGeneral))
+    // but never lowers it, requests in flight are not counted by the server yet
+    res.Header.Set("BU-USED-WEIGHT", "2")
+    l.Update(res)
+    assert.Equal(9, l.Used(RateLimitBucketGeneral))
+    assert.Error(l.Wait(ctx, "/v1/tick/depth"))
+    assert.NoError(l.Wait(ctx, "/v1/time"))
+
+    // Retry-After blocks every bucket
+    res.Header.Set("Retry-After", "30")
+    l.Update(res)
+    err = l.Wait(ctx, "/v1/trade/order")
+    assert.True(errors.As(err, &rlErr))
+    assert.Equal(30*time.Second, rlErr.RetryAfter)
+
+    // a deadline shorter than the wait fails fast
+    deadline, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    assert.True(errors.Is(l.Wait(deadline, "/v1/time"), common.ErrRateLimited))
+
+    // a weight over the whole limit is refused rather than sent
+    l.SetWeight("/v1/tick/heavy", EndpointWeight{Weight: 11})
+    now = now.Add(time.Hour)
+    err = l.Wait(context.Background(), "/v1/tick/heavy")
+    if assert.True(errors.As(err, &rlErr)) {
+        assert.Equal(11, rlErr.Weight)
+        assert.Equal(10, rlErr.Limit)
+    }
+    assert.True(errors.Is(err, common.ErrRateLimited))
+    assert.Equal(0, l.Used(RateLimitBucketGeneral))
+}
+
+func TestRateLimiterWaitsBeforeSigning(t *testing.T) {
+    assert := assert.New(t)
+    var stamps []time.Time
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        ts, err := strconv.ParseInt(req.URL.Query().Get(timestampKey), 10, 64)
+        if err != nil {
+            return nil, err
+        }
+        stamps = append(stamps, time.Unix(0, ts*int64(time.Millisecond)))
+        res := jsonResponse(`{"code":0,"msg":"","data":[]}`)
+        res.Header.Set("Retry-After", "1")
+        return res, nil
+    })
+    c.RateLimiter = NewRateLimiter(RateLimiterConfig{})
+    for i := 0; i < 2; i++ {
+        _, err := c.NewListOrdersService().Symbol("BTCUSDT").Do(context.Background())
+        assert.NoError(err)
+    }
+    // the second call is held back by Retry-After and only stamped after
+    if assert.Len(stamps, 2) {
+        assert.True(stamps[1].Sub(stamps[0]) >= 900*time.Millisecond, stamps[1].Sub(stamps[0]))
+    }
+}
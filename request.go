@@ -28,6 +28,15 @@ type request struct {
     header   http.Header
     body     io.Reader
     fullURL  string
+
+    retryPolicy    *RetryPolicy
+    retryPolicySet bool
+    // noRetry is set on requests that must never be sent twice blindly
+    noRetry bool
+    // trading is set on requests that place or cancel orders
+    trading bool
+    // recvWindow override Client.RecvWindow
+    recvWindow *int64
 }
 
 // addParam add param with key/value to query string
@@ -94,6 +103,13 @@ func (r *request) validate() (err error) {
 // RequestOption define option type for request
 type RequestOption func(*request)
 
+// WithRecvWindow set the recvWindow of a signed request, in milliseconds
+func WithRecvWindow(recvWindow int64) RequestOption {
+    return func(r *request) {
+        r.recvWindow = &recvWindow
+    }
+}
+
 // WithHeader set or add a header value to the request
 func WithHeader(key, value string, replace bool) RequestOption {
     return func(r *request) {
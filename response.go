@@ -0,0 +1,66 @@
+package bitnut
+
+import (
+    "bytes"
+    "fmt"
+
+    "github.com/hardyzp/bitnut/common"
+    jsoniter "github.com/json-iterator/go"
+)
+
+// Response codes reported by the exchange for a successful call
+const (
+    codeOK     int64 = 0
+    codeOKHTTP int64 = 200
+)
+
+// envelope define the code/msg/data wrapper shared by the v1 endpoints
+type envelope struct {
+    Code *int64              `json:"code"`
+    Msg  string              `json:"msg"`
+    Data jsoniter.RawMessage `json:"data"`
+}
+
+// decodeResponse check the response envelope and unmarshal its data into v.
+// A non-success code is returned as a *common.APIError. Payloads that are not
+// wrapped in an envelope are unmarshalled into v as they are.
+// v may be nil when the caller only cares about the code.
+func decodeResponse(data []byte, v interface{}) (code int, msg string, err error) {
+    payload := data
+    trimmed := bytes.TrimSpace(data)
+    if len(trimmed) > 0 && trimmed[0] == '{' {
+        env := new(envelope)
+        err = json.Unmarshal(trimmed, env)
+        if err != nil {
+            return 0, "", err
+        }
+        if env.Code != nil {
+            code, msg = int(*env.Code), env.Msg
+            if *env.Code != codeOK && *env.Code != codeOKHTTP {
+                return code, msg, &common.APIError{Code: *env.Code, Message: msg}
+            }
+            payload = env.Data
+        }
+    }
+    if v == nil || len(payload) == 0 || string(payload) == "null" {
+        return code, msg, nil
+    }
+    err = json.Unmarshal(payload, v)
+    if err != nil {
+        return code, msg, err
+    }
+    return code, msg, nil
+}
+
+// parseDecimal parse the numeric field name of a response, empty values
+// parse as zero
+func parseDecimal(name, raw string) (common.Decimal, error) {
+    if raw == "" {
+        return common.Decimal{}, nil
+    }
+    d, err := common.ParseDecimal(raw)
+    if err != nil {
+        return common.Decimal{}, fmt.Errorf("bitnut: invalid %s: %w", name, err)
+    }
+    return d, nil
+}
@@ -0,0 +1,59 @@
+package bitnut
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "testing"
+
+    "github.com/hardyzp/bitnut/common"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestDecodeResponseCodes(t *testing.T) {
+    assert := assert.New(t)
+    tests := []struct {
+        name   string
+        body   string
+        code   int
+        target error
+    }{
+        {"ok", `{"code":0,"msg":"","data":{"ts":1}}`, 0, nil},
+        {"ok as http code", `{"code":200,"msg":"success","data":{"ts":1}}`, 200, nil},
+        {"insufficient balance", `{"code":-2010,"msg":"balance not enough"}`, -2010, common.ErrInsufficientBalance},
+        {"unknown order", `{"code":-2013,"msg":"Order does not exist."}`, -2013, common.ErrUnknownOrder},
+        {"unregistered code", `{"code":-9999,"msg":"unknown"}`, -9999, nil},
+    }
+    for _, test := range tests {
+        var v serverTimeResponse
+        code, _, err := decodeResponse([]byte(test.body), &v)
+        assert.Equal(test.code, code, test.name)
+        if test.code == 0 || test.code == 200 {
+            assert.NoError(err, test.name)
+            assert.Equal(int64(1), v.Timestamp, test.name)
+            continue
+        }
+        var apiErr *common.APIError
+        if assert.True(errors.As(err, &apiErr), test.name) {
+            assert.Equal(int64(test.code), apiErr.Code, test.name)
+        }
+        if test.target != nil {
+            assert.ErrorIs(err, test.target, test.name)
+        }
+    }
+}
+
+func TestCallAPIReturnsEnvelopeErrors(t *testing.T) {
+    assert := assert.New(t)
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        return jsonResponse(`{"code":-2010,"msg":"balance not enough"}`), nil
+    })
+    _, err := c.NewGetBalanceService().Do(context.Background())
+    var apiErr *common.APIError
+    if assert.True(errors.As(err, &apiErr)) {
+        assert.Equal(common.CodeInsufficientBalance, apiErr.Code)
+        assert.Equal("balance not enough", apiErr.Message)
+        assert.Equal(http.StatusOK, apiErr.StatusCode)
+    }
+    assert.ErrorIs(err, common.ErrInsufficientBalance)
+}
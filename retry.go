@@ -0,0 +1,154 @@
+package bitnut
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    mrand "math/rand"
+    "net"
+    "net/http"
+    "time"
+
+    "github.com/hardyzp/bitnut/common"
+)
+
+// RetryPolicy define how failed requests are retried
+type RetryPolicy struct {
+    // MaxAttempts is the total number of tries, values below 2 disable retries
+    MaxAttempts int
+    // BaseDelay is the wait before the first retry, it doubles on every
+    // further attempt up to MaxDelay
+    BaseDelay time.Duration
+    MaxDelay  time.Duration
+    // Jitter randomises each delay by up to this fraction of it, in [0, 1]
+    Jitter float64
+    // Retryable classify errors, IsRetryableError is used when nil
+    Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy return the retry policy installed by NewClient
+func DefaultRetryPolicy() *RetryPolicy {
+    return &RetryPolicy{
+        MaxAttempts: 3,
+        BaseDelay:   200 * time.Millisecond,
+        MaxDelay:    2 * time.Second,
+        Jitter:      0.2,
+    }
+}
+
+// Backoff return the delay to wait before the given retry, starting at 1
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+    if retry < 1 {
+        retry = 1
+    }
+    delay := float64(p.BaseDelay) * math.Pow(2, float64(retry-1))
+    if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+        delay = float64(p.MaxDelay)
+    }
+    if p.Jitter > 0 {
+        delay += delay * p.Jitter * (2*mrand.Float64() - 1)
+    }
+    return time.Duration(delay)
+}
+
+func (p *RetryPolicy) shouldRetry(attempt int, err error) bool {
+    if p == nil || attempt >= p.MaxAttempts {
+        return false
+    }
+    if p.Retryable != nil {
+        return p.Retryable(err)
+    }
+    return IsRetryableError(err)
+}
+
+// wait sleep before the given retry, it return early with the context error
+func (p *RetryPolicy) wait(ctx context.Context, retry int) error {
+    timer := time.NewTimer(p.Backoff(retry))
+    defer timer.Stop()
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-timer.C:
+        return nil
+    }
+}
+
+// IsRetryableError report whether a request that failed with err may be
+// sent again: transport errors, timeouts, 5xx responses and server-side
+// rate limiting. Client-side rate limit refusals and canceled contexts are
+// not retryable.
+func IsRetryableError(err error) bool {
+    if err == nil {
+        return false
+    }
+    if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+        return false
+    }
+    var rlErr *RateLimitError
+    if errors.As(err, &rlErr) {
+        return false
+    }
+    return errors.Is(err, common.ErrRateLimited) || isTransientError(err)
+}
+
+// isTransientError report whether err is a transport error, a timeout or a
+// 5xx response, after which the request may or may not have been processed.
+// Errors the exchange answered otherwise and refusals before sending are
+// definite.
+func isTransientError(err error) bool {
+    var apiErr *common.APIError
+    if errors.As(err, &apiErr) {
+        return apiErr.StatusCode >= http.StatusInternalServerError
+    }
+    var netErr net.Error
+    if errors.As(err, &netErr) {
+        return true
+    }
+    return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// AmbiguousOrderError is returned by CreateOrderService when an order
+// submission failed in a way that may have reached the exchange and it is
+// unknown whether the order exists: no retry was left, or the follow-up
+// lookup failed too. The order can be reconciled by its client order id.
+type AmbiguousOrderError struct {
+    ClientOrderID string
+    Err           error
+    LookupErr     error
+}
+
+// Error return the client order id and the underlying errors
+func (e *AmbiguousOrderError) Error() string {
+    if e.LookupErr == nil {
+        return fmt.Sprintf("<AmbiguousOrderError> clientOid=%s, err=%v", e.ClientOrderID, e.Err)
+    }
+    return fmt.Sprintf("<AmbiguousOrderError> clientOid=%s, err=%v, lookup err=%v", e.ClientOrderID, e.Err, e.LookupErr)
+}
+
+// Unwrap return the submission error
+func (e *AmbiguousOrderError) Unwrap() error {
+    return e.Err
+}
+
+// newClientOrderID generate a random client order id
+func newClientOrderID() string {
+    b := make([]byte, 16)
+    _, err := rand.Read(b)
+    if err != nil {
+        return fmt.Sprintf("%x", time.Now().UnixNano())
+    }
+    return hex.EncodeToString(b)
+}
+
+// WithRetryPolicy override the client retry policy for one request,
+// pass nil to disable retries
+func WithRetryPolicy(policy *RetryPolicy) RequestOption {
+    return func(r *request) {
+        r.retryPolicy = policy
+        r.retryPolicySet = true
+    }
+}
@@ -0,0 +1,165 @@
+package bitnut
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/hardyzp/bitnut/common"
+    "github.com/stretchr/testify/assert"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func newTestClient(do doFunc) *Client {
+    c := NewClient("key", "secret")
+    c.SetEnvironment(CustomEnvironment("test", "http://localhost", ""))
+    c.RateLimiter = nil
+    c.Logger = NopLogger()
+    c.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+    c.do = do
+    return c
+}
+
+func jsonResponse(body string) *http.Response {
+    return &http.Response{
+        StatusCode: http.StatusOK,
+        Header:     http.Header{},
+        Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+    }
+}
+
+func TestCreateOrderResubmitsUnknownOrder(t *testing.T) {
+    assert := assert.New(t)
+    var calls []string
+    var clientOids []string
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        calls = append(calls, req.URL.Path)
+        switch req.URL.Path {
+        case "/v1/trade/order":
+            body, _ := ioutil.ReadAll(req.Body)
+            clientOids = append(clientOids, string(body))
+            if len(clientOids) == 1 {
+                return nil, timeoutError{}
+            }
+            return jsonResponse(`{"code":0,"msg":"","data":["42"]}`), nil
+        default:
+            return jsonResponse(`{"code":-2013,"msg":"Order does not exist."}`), nil
+        }
+    })
+    res, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(SideTypeBuy).
+        Type(OrderTypeLimit).Quantity("1").Price("10").Do(context.Background())
+    assert.NoError(err)
+    assert.Equal("42", res.OrderID)
+    assert.Contains(clientOids[0], "clientOid="+res.ClientOrderID)
+    assert.Equal([]string{"/v1/trade/order", "/v1/spot/user/orderInfo", "/v1/trade/order"}, calls)
+    assert.Len(clientOids, 2)
+    assert.Equal(clientOids[0], clientOids[1])
+    assert.Contains(clientOids[0], "clientOid=")
+}
+
+func TestCreateOrderFoundAfterTimeout(t *testing.T) {
+    assert := assert.New(t)
+    submissions := 0
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        switch req.URL.Path {
+        case "/v1/trade/order":
+            submissions++
+            return nil, timeoutError{}
+        default:
+            return jsonResponse(`{"code":0,"msg":"","data":{"orderId":"7","clientOrderId":"abc","status":"NEW"}}`), nil
+        }
+    })
+    res, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(SideTypeBuy).
+        Type(OrderTypeLimit).Quantity("1").Price("10").NewClientOrderID("abc").Do(context.Background())
+    assert.NoError(err)
+    assert.Equal("7", res.OrderID)
+    assert.Equal("abc", res.ClientOrderID)
+    assert.Equal(OrderStatusTypeNew, res.Status)
+    assert.Equal(1, submissions)
+}
+
+func TestCallAPIRetriesServerErrors(t *testing.T) {
+    assert := assert.New(t)
+    calls := 0
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        calls++
+        if calls < 3 {
+            res := jsonResponse(`{"code":500,"msg":"busy"}`)
+            res.StatusCode = http.StatusBadGateway
+            return res, nil
+        }
+        return jsonResponse(`{"code":0,"msg":"","data":{"ts":1}}`), nil
+    })
+    ts, err := c.NewServerTimeService().Do(context.Background())
+    assert.NoError(err)
+    assert.Equal(int64(1), ts)
+    assert.Equal(3, calls)
+
+    calls = 0
+    _, err = c.NewServerTimeService().Do(context.Background(), WithRetryPolicy(nil))
+    assert.Error(err)
+    assert.Equal(1, calls)
+}
+
+func TestCreateOrderAmbiguousWithoutRetries(t *testing.T) {
+    assert := assert.New(t)
+    var bodies []string
+    fail := func() (*http.Response, error) { return nil, timeoutError{} }
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        switch req.URL.Path {
+        case "/v1/trade/order":
+            body, _ := ioutil.ReadAll(req.Body)
+            bodies = append(bodies, string(body))
+            return fail()
+        default:
+            return jsonResponse(`{"code":-2013,"msg":"Order does not exist."}`), nil
+        }
+    })
+    c.RetryPolicy = nil
+    create := func() error {
+        _, err := c.NewCreateOrderService().Symbol("BTCUSDT").Side(SideTypeBuy).
+            Type(OrderTypeLimit).Quantity("1").Price("10").Do(context.Background())
+        return err
+    }
+
+    // a timeout may have placed the order under the generated client order id
+    err := create()
+    var ambiguous *AmbiguousOrderError
+    if assert.True(errors.As(err, &ambiguous)) {
+        assert.NotEmpty(ambiguous.ClientOrderID)
+        assert.Contains(bodies[0], "clientOid="+ambiguous.ClientOrderID)
+        assert.Nil(ambiguous.LookupErr)
+    }
+
+    // so may a 5xx response
+    fail = func() (*http.Response, error) {
+        res := jsonResponse(`{"code":500,"msg":"busy"}`)
+        res.StatusCode = http.StatusBadGateway
+        return res, nil
+    }
+    assert.True(errors.As(create(), &ambiguous))
+
+    // a rejection is definite
+    fail = func() (*http.Response, error) {
+        return jsonResponse(`{"code":-2010,"msg":"balance not enough"}`), nil
+    }
+    err = create()
+    assert.False(errors.As(err, &ambiguous))
+    assert.True(errors.Is(err, common.ErrInsufficientBalance))
+    assert.Len(bodies, 3)
+
+    // a timeout on the last attempt is ambiguous too
+    c.RetryPolicy = &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+    fail = func() (*http.Response, error) { return nil, timeoutError{} }
+    assert.True(errors.As(create(), &ambiguous))
+    assert.Len(bodies, 5)
+}
@@ -20,12 +20,12 @@ func (s *ServerTimeService) Do(ctx context.Context, opts ...RequestOption) (serv
     if err != nil {
         return 0, err
     }
-    j, err := newJSON(data)
+    res := new(serverTimeResponse)
+    _, _, err = decodeResponse(data, res)
     if err != nil {
         return 0, err
     }
-    serverTime = j.Get("data").Get("ts").MustInt64()
-    return serverTime, nil
+    return res.Timestamp, nil
 }
 
 // SetServerTimeService set server time
@@ -40,6 +40,10 @@ func (s *SetServerTimeService) Do(ctx context.Context, opts ...RequestOption) (t
         return 0, err
     }
     timeOffset = currentTimestamp() - serverTime
-    s.c.TimeOffset = timeOffset
+    s.c.setTimeOffset(timeOffset)
     return timeOffset, nil
 }
+
+type serverTimeResponse struct {
+    Timestamp int64 `json:"ts"`
+}
@@ -0,0 +1,140 @@
+package bitnut
+
+import (
+    "crypto"
+    "crypto/ed25519"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/pem"
+    "errors"
+    "fmt"
+)
+
+// SignPayload define the canonical parts of a signed request
+type SignPayload struct {
+    Method   string
+    Endpoint string
+    // Query is the encoded query string, it carries the timestamp
+    Query string
+    // Body is the encoded form body
+    Body string
+}
+
+// queryAndBody return the query string followed by the body
+func (p *SignPayload) queryAndBody() string {
+    return fmt.Sprintf("%s%s", p.Query, p.Body)
+}
+
+// Signer compute the BU-ACCESS-SIGN header of signed requests
+type Signer interface {
+    Sign(p *SignPayload) (string, error)
+}
+
+// SignerFunc adapt a function to the Signer interface
+type SignerFunc func(p *SignPayload) (string, error)
+
+// Sign call f(p)
+func (f SignerFunc) Sign(p *SignPayload) (string, error) {
+    return f(p)
+}
+
+// HMACSigner sign requests with HMAC-SHA256 and base64 encode the digest
+type HMACSigner struct {
+    secret       []byte
+    includeQuery bool
+}
+
+// NewHMACSigner init the default signer, which covers the form body only
+func NewHMACSigner(secretKey string) *HMACSigner {
+    return &HMACSigner{secret: []byte(secretKey)}
+}
+
+// NewHMACQuerySigner init a HMAC signer covering the query string followed
+// by the form body
+func NewHMACQuerySigner(secretKey string) *HMACSigner {
+    return &HMACSigner{secret: []byte(secretKey), includeQuery: true}
+}
+
+// Sign sign the payload
+func (s *HMACSigner) Sign(p *SignPayload) (string, error) {
+    raw := p.Body
+    if s.includeQuery {
+        raw = p.queryAndBody()
+    }
+    mac := hmac.New(sha256.New, s.secret)
+    _, err := mac.Write([]byte(raw))
+    if err != nil {
+        return "", err
+    }
+    return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Ed25519Signer sign the query string followed by the form body with an
+// Ed25519 private key
+type Ed25519Signer struct {
+    key ed25519.PrivateKey
+}
+
+// NewEd25519Signer init an Ed25519 signer
+func NewEd25519Signer(key ed25519.PrivateKey) *Ed25519Signer {
+    return &Ed25519Signer{key: key}
+}
+
+// Sign sign the payload
+func (s *Ed25519Signer) Sign(p *SignPayload) (string, error) {
+    sig := ed25519.Sign(s.key, []byte(p.queryAndBody()))
+    return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// RSASigner sign the query string followed by the form body with
+// RSASSA-PKCS1-v1_5 over SHA-256
+type RSASigner struct {
+    key *rsa.PrivateKey
+}
+
+// NewRSASigner init a RSA signer
+func NewRSASigner(key *rsa.PrivateKey) *RSASigner {
+    return &RSASigner{key: key}
+}
+
+// Sign sign the payload
+func (s *RSASigner) Sign(p *SignPayload) (string, error) {
+    digest := sha256.Sum256([]byte(p.queryAndBody()))
+    sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+    if err != nil {
+        return "", err
+    }
+    return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// NewSignerFromPEM init an Ed25519 or RSA signer from a PEM encoded private
+// key, in PKCS #8 or, for RSA, PKCS #1 form
+func NewSignerFromPEM(pemBytes []byte) (Signer, error) {
+    block, _ := pem.Decode(pemBytes)
+    if block == nil {
+        return nil, errors.New("bitnut: no PEM data found")
+    }
+    if block.Type == "RSA PRIVATE KEY" {
+        key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+        if err != nil {
+            return nil, err
+        }
+        return NewRSASigner(key), nil
+    }
+    key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+    if err != nil {
+        return nil, err
+    }
+    switch k := key.(type) {
+    case ed25519.PrivateKey:
+        return NewEd25519Signer(k), nil
+    case *rsa.PrivateKey:
+        return NewRSASigner(k), nil
+    default:
+        return nil, fmt.Errorf("bitnut: unsupported private key type %T", key)
+    }
+}
@@ -0,0 +1,770 @@
+package bitnut
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gorilla/websocket"
+    "github.com/hardyzp/bitnut/common"
+    jsoniter "github.com/json-iterator/go"
+)
+
+// Stream defaults
+const (
+    defaultStreamPingInterval = 30 * time.Second
+    defaultStreamPongWait     = 60 * time.Second
+    defaultStreamBuffer       = 256
+    streamWriteWait           = 10 * time.Second
+)
+
+// Market stream event types
+const (
+    StreamEventTrade       = "trade"
+    StreamEventKline       = "kline"
+    StreamEventDepthUpdate = "depthUpdate"
+    StreamEventTicker      = "24hrTicker"
+)
+
+// Stream errors
+var (
+    ErrStreamClosed    = errors.New("bitnut: stream closed")
+    ErrStreamConnected = errors.New("bitnut: stream already connected")
+    // ErrSlowConsumer is reported when an event is dropped because the
+    // channel of its subscription is full
+    ErrSlowConsumer = errors.New("bitnut: stream event dropped, subscription channel full")
+)
+
+// WsTradeEvent define websocket trade event
+type WsTradeEvent struct {
+    Event        string `json:"e"`
+    Time         int64  `json:"E"`
+    Symbol       string `json:"s"`
+    TradeID      int64  `json:"t"`
+    Price        string `json:"p"`
+    Quantity     string `json:"q"`
+    TradeTime    int64  `json:"T"`
+    IsBuyerMaker bool   `json:"m"`
+    Placeholder  bool   `json:"M"` // add this field to avoid case insensitive unmarshaling
+}
+
+// Trade return the event as a Trade, e.g. for a candle builder
+func (e *WsTradeEvent) Trade() *Trade {
+    return &Trade{
+        ID:           e.TradeID,
+        Price:        e.Price,
+        Quantity:     e.Quantity,
+        Time:         e.TradeTime,
+        IsBuyerMaker: e.IsBuyerMaker,
+    }
+}
+
+// WsKlineEvent define websocket kline event
+type WsKlineEvent struct {
+    Event  string  `json:"e"`
+    Time   int64   `json:"E"`
+    Symbol string  `json:"s"`
+    Kline  WsKline `json:"k"`
+}
+
+// WsKline define websocket kline, IsFinal is set on the last update of a
+// kline, when it closes
+type WsKline struct {
+    StartTime            int64         `json:"t"`
+    EndTime              int64         `json:"T"`
+    Symbol               string        `json:"s"`
+    Interval             KlineInterval `json:"i"`
+    FirstTradeID         int64         `json:"f"`
+    LastTradeID          int64         `json:"L"`
+    Open                 string        `json:"o"`
+    Close                string        `json:"c"`
+    High                 string        `json:"h"`
+    Low                  string        `json:"l"`
+    Volume               string        `json:"v"`
+    TradeNum             int64         `json:"n"`
+    IsFinal              bool          `json:"x"`
+    QuoteVolume          string        `json:"q"`
+    ActiveBuyVolume      string        `json:"V"`
+    ActiveBuyQuoteVolume string        `json:"Q"`
+}
+
+// Kline return the websocket kline as the Kline returned by KlinesService
+func (k *WsKline) Kline() *Kline {
+    return &Kline{
+        OpenTime:                 k.StartTime,
+        Open:                     k.Open,
+        High:                     k.High,
+        Low:                      k.Low,
+        Close:                    k.Close,
+        Volume:                   k.Volume,
+        CloseTime:                k.EndTime,
+        QuoteAssetVolume:         k.QuoteVolume,
+        TradeNum:                 k.TradeNum,
+        TakerBuyBaseAssetVolume:  k.ActiveBuyVolume,
+        TakerBuyQuoteAssetVolume: k.ActiveBuyQuoteVolume,
+    }
+}
+
+// WsDepthEvent define websocket depth diff event. It carries the levels
+// changed by the updates FirstUpdateID to LastUpdateID, a zero quantity
+// removes a level.
+type WsDepthEvent struct {
+    Event         string      `json:"e"`
+    Time          int64       `json:"E"`
+    Symbol        string      `json:"s"`
+    FirstUpdateID int64       `json:"U"`
+    LastUpdateID  int64       `json:"u"`
+    Bids          [][2]string `json:"b"`
+    Asks          [][2]string `json:"a"`
+}
+
+// WsBookTickerEvent define websocket best bid and ask event
+type WsBookTickerEvent struct {
+    UpdateID     int64  `json:"u"`
+    Symbol       string `json:"s"`
+    BestBidPrice string `json:"b"`
+    BestBidQty   string `json:"B"`
+    BestAskPrice string `json:"a"`
+    BestAskQty   string `json:"A"`
+}
+
+// WsTickerEvent define websocket 24h ticker event
+type WsTickerEvent struct {
+    Event              string `json:"e"`
+    Time               int64  `json:"E"`
+    Symbol             string `json:"s"`
+    PriceChange        string `json:"p"`
+    PriceChangePercent string `json:"P"`
+    WeightedAvgPrice   string `json:"w"`
+    LastPrice          string `json:"c"`
+    LastQty            string `json:"Q"`
+    OpenPrice          string `json:"o"`
+    HighPrice          string `json:"h"`
+    LowPrice           string `json:"l"`
+    BaseVolume         string `json:"v"`
+    QuoteVolume        string `json:"q"`
+    OpenTime           int64  `json:"O"`
+    CloseTime          int64  `json:"C"`
+    FirstID            int64  `json:"F"`
+    LastID             int64  `json:"L"`
+    Count              int64  `json:"n"`
+}
+
+// Stream is a market data websocket client. Subscriptions are multiplexed
+// on one connection, which is kept alive with pings and reconnected with
+// backoff when it breaks, restoring every subscription.
+type Stream struct {
+    URL    string
+    Dialer *websocket.Dialer
+    Header http.Header
+    // PingInterval is how often a ping is sent, the connection is dropped
+    // when nothing is received for PongWait
+    PingInterval time.Duration
+    PongWait     time.Duration
+    // Reconnect is the backoff between reconnection attempts, MaxAttempts
+    // limits the attempts per outage when positive. Nil disables reconnects.
+    Reconnect *RetryPolicy
+    // Buffer is the channel size of subscriptions without handler
+    Buffer int
+    Logger Logger
+
+    // private streams carry the user data of the connection, subscriptions
+    // are not sent to the server
+    private bool
+    // prepare is called before every dial, e.g. to refresh a listen key
+    prepare func(ctx context.Context) error
+
+    mu          sync.Mutex
+    conn        *websocket.Conn
+    subs        map[string][]*Subscription
+    nextID      int64
+    onError     func(error)
+    onReconnect func()
+    cancel      context.CancelFunc
+    done        chan struct{}
+
+    writeMu sync.Mutex
+}
+
+// NewStream init a market stream on the environment of the client
+func (c *Client) NewStream() *Stream {
+    s := NewStream(c.Environment.StreamURL)
+    s.Logger = c.Logger
+    return s
+}
+
+// NewStream init a market stream on url
+func NewStream(url string) *Stream {
+    return &Stream{
+        URL:          url,
+        Dialer:       websocket.DefaultDialer,
+        PingInterval: defaultStreamPingInterval,
+        PongWait:     defaultStreamPongWait,
+        Reconnect: &RetryPolicy{
+            BaseDelay: 500 * time.Millisecond,
+            MaxDelay:  30 * time.Second,
+            Jitter:    0.2,
+        },
+        Buffer: defaultStreamBuffer,
+        Logger: NopLogger(),
+        subs:   map[string][]*Subscription{},
+    }
+}
+
+// OnError set the handler of connection, decoding and subscription errors
+func (s *Stream) OnError(fn func(error)) *Stream {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.onError = fn
+    return s
+}
+
+// OnReconnect set a function called after the connection was restored, once
+// the subscriptions were sent again. Events may have been missed meanwhile.
+func (s *Stream) OnReconnect(fn func()) *Stream {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.onReconnect = fn
+    return s
+}
+
+// MarketStream is the source of market events, a Stream or a Watcher
+type MarketStream interface {
+    SubscribeTicker(symbol string, handler func(*WsTickerEvent)) (*Subscription, error)
+    SubscribeDepth(symbol string, handler func(*WsDepthEvent)) (*Subscription, error)
+    Connect(ctx context.Context) error
+    Close() error
+    Done() <-chan struct{}
+}
+
+// UserDataStream is the source of account events, a UserStream or a Watcher
+type UserDataStream interface {
+    SubscribeOrderUpdates(handler func(*WsOrderUpdateEvent)) (*Subscription, error)
+    SubscribeBalanceUpdates(handler func(*WsBalanceUpdateEvent)) (*Subscription, error)
+    Connect(ctx context.Context) error
+    Close() error
+    Done() <-chan struct{}
+}
+
+var (
+    _ MarketStream   = (*Stream)(nil)
+    _ MarketStream   = (*Watcher)(nil)
+    _ UserDataStream = (*UserStream)(nil)
+    _ UserDataStream = (*Watcher)(nil)
+)
+
+// subscriber is the source of subscriptions, a Stream or a Watcher
+type subscriber interface {
+    unsubscribe(sub *Subscription) error
+}
+
+// Subscription define a subscription to a stream, see the Subscribe
+// methods of Stream and Watcher
+type Subscription struct {
+    // Name is the stream name, e.g. btcusdt@trade
+    Name    string
+    s       subscriber
+    decode  func(data []byte) (interface{}, error)
+    handler func(event interface{})
+    events  chan interface{}
+
+    mu     sync.Mutex
+    closed bool
+}
+
+// Events return the channel of a subscription made without handler. Events
+// are dropped with ErrSlowConsumer when it is full. It is closed on
+// Unsubscribe and when the stream ends.
+func (sub *Subscription) Events() <-chan interface{} {
+    return sub.events
+}
+
+// Unsubscribe stop the subscription
+func (sub *Subscription) Unsubscribe() error {
+    return sub.s.unsubscribe(sub)
+}
+
+func (sub *Subscription) close() {
+    sub.mu.Lock()
+    defer sub.mu.Unlock()
+    if !sub.closed && sub.events != nil {
+        close(sub.events)
+    }
+    sub.closed = true
+}
+
+func (sub *Subscription) deliver(event interface{}) error {
+    sub.mu.Lock()
+    if sub.closed {
+        sub.mu.Unlock()
+        return nil
+    }
+    if sub.handler != nil {
+        // the handler may unsubscribe, it is called without the lock
+        sub.mu.Unlock()
+        sub.handler(event)
+        return nil
+    }
+    defer sub.mu.Unlock()
+    select {
+    case sub.events <- event:
+        return nil
+    default:
+        return fmt.Errorf("%w: %s", ErrSlowConsumer, sub.Name)
+    }
+}
+
+func streamName(symbol, stream string) string {
+    return strings.ToLower(symbol) + "@" + stream
+}
+
+func decoder(newEvent func() interface{}) func(data []byte) (interface{}, error) {
+    return func(data []byte) (interface{}, error) {
+        e := newEvent()
+        err := json.Unmarshal(data, e)
+        return e, err
+    }
+}
+
+// SubscribeTrades subscribe to the trades of symbol. Events are
+// *WsTradeEvent, passed to handler or sent to the subscription channel when
+// handler is nil.
+func (s *Stream) SubscribeTrades(symbol string, handler func(*WsTradeEvent)) (*Subscription, error) {
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*WsTradeEvent)) }
+    }
+    return s.subscribe(streamName(symbol, "trade"), decoder(func() interface{} { return new(WsTradeEvent) }), h)
+}
+
+// SubscribeKlines subscribe to the klines of symbol, events are *WsKlineEvent
+func (s *Stream) SubscribeKlines(symbol string, interval KlineInterval, handler func(*WsKlineEvent)) (*Subscription, error) {
+    err := interval.Validate()
+    if err != nil {
+        return nil, err
+    }
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*WsKlineEvent)) }
+    }
+    return s.subscribe(streamName(symbol, "kline_"+string(interval)), decoder(func() interface{} { return new(WsKlineEvent) }), h)
+}
+
+// SubscribeDepth subscribe to the order book diffs of symbol, events are
+// *WsDepthEvent
+func (s *Stream) SubscribeDepth(symbol string, handler func(*WsDepthEvent)) (*Subscription, error) {
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*WsDepthEvent)) }
+    }
+    return s.subscribe(streamName(symbol, "depth"), decoder(func() interface{} { return new(WsDepthEvent) }), h)
+}
+
+// SubscribeBookTicker subscribe to the best bid and ask of symbol, events
+// are *WsBookTickerEvent
+func (s *Stream) SubscribeBookTicker(symbol string, handler func(*WsBookTickerEvent)) (*Subscription, error) {
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*WsBookTickerEvent)) }
+    }
+    return s.subscribe(streamName(symbol, "bookTicker"), decoder(func() interface{} { return new(WsBookTickerEvent) }), h)
+}
+
+// SubscribeTicker subscribe to the 24h ticker of symbol, events are
+// *WsTickerEvent
+func (s *Stream) SubscribeTicker(symbol string, handler func(*WsTickerEvent)) (*Subscription, error) {
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*WsTickerEvent)) }
+    }
+    return s.subscribe(streamName(symbol, "ticker"), decoder(func() interface{} { return new(WsTickerEvent) }), h)
+}
+
+func (s *Stream) subscribe(name string, decode func([]byte) (interface{}, error), handler func(interface{})) (*Subscription, error) {
+    sub := &Subscription{Name: name, s: s, decode: decode, handler: handler}
+    if handler == nil {
+        buffer := s.Buffer
+        if buffer <= 0 {
+            buffer = defaultStreamBuffer
+        }
+        sub.events = make(chan interface{}, buffer)
+    }
+    s.mu.Lock()
+    first := len(s.subs[name]) == 0
+    s.subs[name] = append(s.subs[name], sub)
+    conn := s.conn
+    s.mu.Unlock()
+    if first && conn != nil && !s.private {
+        err := s.send(conn, "SUBSCRIBE", name)
+        if err != nil {
+            return nil, err
+        }
+    }
+    return sub, nil
+}
+
+func (s *Stream) unsubscribe(sub *Subscription) error {
+    s.mu.Lock()
+    subs := s.subs[sub.Name]
+    for i, other := range subs {
+        if other == sub {
+            subs = append(subs[:i:i], subs[i+1:]...)
+            break
+        }
+    }
+    if len(subs) == 0 {
+        delete(s.subs, sub.Name)
+    } else {
+        s.subs[sub.Name] = subs
+    }
+    conn := s.conn
+    s.mu.Unlock()
+    sub.close()
+    if len(subs) == 0 && conn != nil && !s.private {
+        return s.send(conn, "UNSUBSCRIBE", sub.Name)
+    }
+    return nil
+}
+
+// streamRequest define a subscription request sent to the server
+type streamRequest struct {
+    Method string   `json:"method"`
+    Params []string `json:"params"`
+    ID     int64    `json:"id"`
+}
+
+func (s *Stream) send(conn *websocket.Conn, method string, names ...string) error {
+    s.mu.Lock()
+    s.nextID++
+    req := &streamRequest{Method: method, Params: names, ID: s.nextID}
+    s.mu.Unlock()
+    data, err := json.Marshal(req)
+    if err != nil {
+        return err
+    }
+    s.writeMu.Lock()
+    defer s.writeMu.Unlock()
+    _ = conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+    return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Connect open the connection and send the current subscriptions. The
+// stream then runs in the background until ctx is done or Close is called.
+// A stream is connected only once, its subscriptions end with it.
+func (s *Stream) Connect(ctx context.Context) error {
+    s.mu.Lock()
+    if s.done != nil {
+        s.mu.Unlock()
+        return ErrStreamConnected
+    }
+    s.done = make(chan struct{})
+    s.mu.Unlock()
+    conn, err := s.dial(ctx)
+    if err != nil {
+        s.mu.Lock()
+        s.done = nil
+        s.mu.Unlock()
+        return err
+    }
+    ctx, cancel := context.WithCancel(ctx)
+    s.mu.Lock()
+    s.cancel = cancel
+    s.mu.Unlock()
+    go s.run(ctx, conn)
+    return nil
+}
+
+// Close stop the stream and wait for it to end
+func (s *Stream) Close() error {
+    s.mu.Lock()
+    cancel, done := s.cancel, s.done
+    s.mu.Unlock()
+    if cancel == nil {
+        return ErrStreamClosed
+    }
+    cancel()
+    <-done
+    return nil
+}
+
+// Done return a channel closed when the stream ended, after Close, when its
+// context is done or when reconnecting gave up
+func (s *Stream) Done() <-chan struct{} {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.done
+}
+
+// dial connect and send the current subscriptions
+func (s *Stream) dial(ctx context.Context) (*websocket.Conn, error) {
+    if s.prepare != nil {
+        err := s.prepare(ctx)
+        if err != nil {
+            return nil, err
+        }
+    }
+    dialer := s.Dialer
+    if dialer == nil {
+        dialer = websocket.DefaultDialer
+    }
+    conn, _, err := dialer.DialContext(ctx, s.URL, s.Header)
+    if err != nil {
+        return nil, fmt.Errorf("bitnut: stream dial: %w", err)
+    }
+    s.mu.Lock()
+    s.conn = conn
+    names := make([]string, 0, len(s.subs))
+    for name := range s.subs {
+        names = append(names, name)
+    }
+    s.mu.Unlock()
+    if len(names) > 0 && !s.private {
+        err = s.send(conn, "SUBSCRIBE", names...)
+        if err != nil {
+            conn.Close()
+            return nil, err
+        }
+    }
+    s.log(LogLevelInfo, "stream connected", F("url", s.logURL()), F("subscriptions", len(names)))
+    return conn, nil
+}
+
+func (s *Stream) run(ctx context.Context, conn *websocket.Conn) {
+    defer s.finish()
+    go func() {
+        <-ctx.Done()
+        s.mu.Lock()
+        if s.conn != nil {
+            s.conn.Close()
+        }
+        s.mu.Unlock()
+    }()
+    for {
+        err := s.read(conn)
+        if ctx.Err() != nil {
+            return
+        }
+        s.report(err)
+        conn, err = s.reconnect(ctx)
+        if err != nil {
+            if ctx.Err() == nil {
+                s.report(err)
+            }
+            return
+        }
+        s.mu.Lock()
+        onReconnect := s.onReconnect
+        s.mu.Unlock()
+        if onReconnect != nil {
+            onReconnect()
+        }
+    }
+}
+
+// finish release the connection and close the subscription channels
+func (s *Stream) finish() {
+    s.mu.Lock()
+    s.cancel()
+    if s.conn != nil {
+        s.conn.Close()
+        s.conn = nil
+    }
+    subs := s.subs
+    s.subs = map[string][]*Subscription{}
+    done := s.done
+    s.mu.Unlock()
+    for _, list := range subs {
+        for _, sub := range list {
+            sub.close()
+        }
+    }
+    close(done)
+}
+
+func (s *Stream) reconnect(ctx context.Context) (*websocket.Conn, error) {
+    s.mu.Lock()
+    s.conn = nil
+    s.mu.Unlock()
+    policy := s.Reconnect
+    if policy == nil {
+        return nil, ErrStreamClosed
+    }
+    for attempt := 1; ; attempt++ {
+        err := policy.wait(ctx, attempt)
+        if err != nil {
+            return nil, err
+        }
+        conn, err := s.dial(ctx)
+        if err == nil {
+            return conn, nil
+        }
+        s.report(err)
+        if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+            return nil, fmt.Errorf("bitnut: stream reconnect gave up after %d attempts: %w", attempt, err)
+        }
+    }
+}
+
+// read dispatch the messages of conn until it fails, pinging it meanwhile
+func (s *Stream) read(conn *websocket.Conn) error {
+    pongWait := s.PongWait
+    if pongWait <= 0 {
+        pongWait = defaultStreamPongWait
+    }
+    _ = conn.SetReadDeadline(time.Now().Add(pongWait))
+    conn.SetPongHandler(func(string) error {
+        return conn.SetReadDeadline(time.Now().Add(pongWait))
+    })
+    stop := make(chan struct{})
+    defer close(stop)
+    go s.ping(conn, stop)
+    for {
+        _, data, err := conn.ReadMessage()
+        if err != nil {
+            return fmt.Errorf("bitnut: stream read: %w", err)
+        }
+        _ = conn.SetReadDeadline(time.Now().Add(pongWait))
+        s.dispatch(data)
+    }
+}
+
+func (s *Stream) ping(conn *websocket.Conn, stop chan struct{}) {
+    interval := s.PingInterval
+    if interval <= 0 {
+        interval = defaultStreamPingInterval
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-stop:
+            return
+        case <-ticker.C:
+            s.writeMu.Lock()
+            err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait))
+            s.writeMu.Unlock()
+            if err != nil {
+                return
+            }
+        }
+    }
+}
+
+// streamMessage define the fields needed to route a stream message. Both
+// cases of the ambiguous keys are declared so that they don't match each
+// other case-insensitively.
+type streamMessage struct {
+    Stream string              `json:"stream"`
+    Data   jsoniter.RawMessage `json:"data"`
+    ID     *int64              `json:"id"`
+    Error  *common.APIError    `json:"error"`
+    Event  string              `json:"e"`
+    Time   jsoniter.RawMessage `json:"E"`
+    Symbol string              `json:"s"`
+    S      jsoniter.RawMessage `json:"S"`
+    Kline  *struct {
+        Interval string              `json:"i"`
+        I        jsoniter.RawMessage `json:"I"`
+    } `json:"k"`
+    K        jsoniter.RawMessage `json:"K"`
+    UpdateID jsoniter.RawMessage `json:"u"`
+    U        jsoniter.RawMessage `json:"U"`
+    Bid      jsoniter.RawMessage `json:"b"`
+    BidQty   jsoniter.RawMessage `json:"B"`
+}
+
+// route return the stream name of a raw event
+func (m *streamMessage) route() string {
+    switch m.Event {
+    case StreamEventTrade:
+        return streamName(m.Symbol, "trade")
+    case StreamEventKline:
+        if m.Kline != nil {
+            return streamName(m.Symbol, "kline_"+m.Kline.Interval)
+        }
+    case StreamEventDepthUpdate:
+        return streamName(m.Symbol, "depth")
+    case StreamEventTicker:
+        return streamName(m.Symbol, "ticker")
+    case "":
+        if m.UpdateID != nil && m.BidQty != nil {
+            return streamName(m.Symbol, "bookTicker")
+        }
+        return ""
+    }
+    // user data events are routed by type
+    return m.Event
+}
+
+func (s *Stream) dispatch(data []byte) {
+    msg := new(streamMessage)
+    err := json.Unmarshal(data, msg)
+    if err != nil {
+        s.report(fmt.Errorf("bitnut: invalid stream message: %w", err))
+        return
+    }
+    if msg.Error != nil {
+        s.report(msg.Error)
+        return
+    }
+    name, payload := msg.Stream, jsoniter.RawMessage(data)
+    if name != "" {
+        payload = msg.Data
+    } else if msg.ID != nil {
+        // subscription acknowledgement
+        return
+    } else {
+        name = msg.route()
+    }
+    s.mu.Lock()
+    subs := append([]*Subscription(nil), s.subs[name]...)
+    s.mu.Unlock()
+    if len(subs) == 0 {
+        s.log(LogLevelDebug, "stream message without subscription", F("stream", name))
+        return
+    }
+    for _, sub := range subs {
+        // a decoder return a nil event to skip it
+        event, err := sub.decode(payload)
+        if err != nil {
+            // the other subscriptions of the stream may still decode it
+            s.report(fmt.Errorf("bitnut: invalid %s event: %w", name, err))
+            continue
+        }
+        if event == nil {
+            continue
+        }
+        err = sub.deliver(event)
+        if err != nil {
+            s.report(err)
+        }
+    }
+}
+
+func (s *Stream) report(err error) {
+    s.log(LogLevelWarn, "stream error", F("error", err))
+    s.mu.Lock()
+    onError := s.onError
+    s.mu.Unlock()
+    if onError != nil {
+        onError(err)
+    }
+}
+
+func (s *Stream) log(level LogLevel, msg string, fields ...Field) {
+    if s.Logger != nil {
+        s.Logger.Log(level, msg, fields...)
+    }
+}
+
+// logURL return the URL to log, private stream URLs carry a listen key
+func (s *Stream) logURL() string {
+    if s.private {
+        return redacted
+    }
+    return s.URL
+}
@@ -0,0 +1,29 @@
+package bitnut
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestStreamDispatchDecodeError(t *testing.T) {
+    assert := assert.New(t)
+    var errs []error
+    s := NewStream("ws://localhost").OnError(func(err error) { errs = append(errs, err) })
+    failing, err := s.subscribe("executionReport", func([]byte) (interface{}, error) {
+        return nil, errors.New("bad event")
+    }, nil)
+    assert.NoError(err)
+    // subscribed after the failing decoder, it still gets the event
+    orders, err := (&UserStream{Conn: s}).SubscribeOrderUpdates(nil)
+    assert.NoError(err)
+
+    s.dispatch([]byte(`{"e":"executionReport","E":1,"s":"BTCUSDT"}`))
+    assert.Len(errs, 1)
+    assert.Len(failing.Events(), 0)
+    if assert.Len(orders.Events(), 1) {
+        e := (<-orders.Events()).(*WsOrderUpdateEvent)
+        assert.Equal("BTCUSDT", e.Symbol)
+    }
+}
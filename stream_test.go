@@ -0,0 +1,182 @@
+package bitnut_test
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/hardyzp/bitnut"
+    "github.com/hardyzp/bitnut/bitnuttest"
+    "github.com/hardyzp/bitnut/common"
+    "github.com/stretchr/testify/assert"
+)
+
+func testStream(srv *bitnuttest.Server) *bitnut.Stream {
+    s := srv.Client().NewStream()
+    s.Reconnect = &bitnut.RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+    return s
+}
+
+func receive(t *testing.T, ch <-chan interface{}) interface{} {
+    select {
+    case e, ok := <-ch:
+        if !ok {
+            t.Fatal("subscription channel closed")
+        }
+        return e
+    case <-time.After(2 * time.Second):
+        t.Fatal("no stream event")
+    }
+    return nil
+}
+
+func TestStreamMarketData(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    s := testStream(srv)
+
+    trades := make(chan *bitnut.WsTradeEvent, 1)
+    _, err := s.SubscribeTrades("BTCUSDT", func(e *bitnut.WsTradeEvent) { trades <- e })
+    assert.NoError(err)
+    klines, err := s.SubscribeKlines("BTCUSDT", bitnut.KlineInterval1m, nil)
+    assert.NoError(err)
+    _, err = s.SubscribeKlines("BTCUSDT", "7m", nil)
+    assert.Error(err)
+    assert.NoError(s.Connect(context.Background()))
+    defer s.Close()
+    assert.ErrorIs(s.Connect(context.Background()), bitnut.ErrStreamConnected)
+
+    // subscriptions made once connected are sent right away
+    depth, err := s.SubscribeDepth("BTCUSDT", nil)
+    assert.NoError(err)
+    book, err := s.SubscribeBookTicker("BTCUSDT", nil)
+    assert.NoError(err)
+    ticker, err := s.SubscribeTicker("BTCUSDT", nil)
+    assert.NoError(err)
+    for _, name := range []string{"btcusdt@trade", "btcusdt@kline_1m", "btcusdt@depth", "btcusdt@bookTicker", "btcusdt@ticker"} {
+        name := name
+        assert.Eventually(func() bool { return srv.StreamSubscribers(name) == 1 }, time.Second, time.Millisecond, name)
+    }
+
+    srv.Publish("btcusdt@trade", &bitnut.WsTradeEvent{Event: "trade", Symbol: "BTCUSDT", TradeID: 7, Price: "40000.10", Quantity: "0.5", TradeTime: 1650000000123})
+    select {
+    case e := <-trades:
+        assert.Equal(&bitnut.Trade{ID: 7, Price: "40000.10", Quantity: "0.5", Time: 1650000000123}, e.Trade())
+    case <-time.After(2 * time.Second):
+        t.Fatal("no trade")
+    }
+
+    srv.Publish("btcusdt@kline_1m", &bitnut.WsKlineEvent{Event: "kline", Symbol: "BTCUSDT", Kline: bitnut.WsKline{
+        StartTime: 1650000000000, EndTime: 1650000059999, Interval: bitnut.KlineInterval1m,
+        Open: "1", High: "3", Low: "1", Close: "2", Volume: "10", TradeNum: 4, IsFinal: true,
+        QuoteVolume: "20", ActiveBuyVolume: "6", ActiveBuyQuoteVolume: "12",
+    }})
+    k := receive(t, klines.Events()).(*bitnut.WsKlineEvent)
+    assert.True(k.Kline.IsFinal)
+    assert.Equal(&bitnut.Kline{
+        OpenTime: 1650000000000, Open: "1", High: "3", Low: "1", Close: "2", Volume: "10",
+        CloseTime: 1650000059999, QuoteAssetVolume: "20", TradeNum: 4,
+        TakerBuyBaseAssetVolume: "6", TakerBuyQuoteAssetVolume: "12",
+    }, k.Kline.Kline())
+
+    srv.Publish("btcusdt@depth", &bitnut.WsDepthEvent{Event: "depthUpdate", Symbol: "BTCUSDT", FirstUpdateID: 10, LastUpdateID: 12,
+        Bids: [][2]string{{"39999", "0"}}, Asks: [][2]string{{"40001", "1.5"}}})
+    d := receive(t, depth.Events()).(*bitnut.WsDepthEvent)
+    assert.Equal(int64(10), d.FirstUpdateID)
+    assert.Equal(int64(12), d.LastUpdateID)
+    assert.Equal([][2]string{{"40001", "1.5"}}, d.Asks)
+
+    srv.Publish("btcusdt@bookTicker", &bitnut.WsBookTickerEvent{UpdateID: 13, Symbol: "BTCUSDT", BestBidPrice: "40000", BestBidQty: "1", BestAskPrice: "40001", BestAskQty: "2"})
+    assert.Equal("2", receive(t, book.Events()).(*bitnut.WsBookTickerEvent).BestAskQty)
+
+    srv.Publish("btcusdt@ticker", &bitnut.WsTickerEvent{Event: "24hrTicker", Symbol: "BTCUSDT", LastPrice: "40000.5", LastQty: "0.1", Count: 99})
+    tick := receive(t, ticker.Events()).(*bitnut.WsTickerEvent)
+    assert.Equal("40000.5", tick.LastPrice)
+    assert.Equal("0.1", tick.LastQty)
+    assert.Equal(int64(99), tick.Count)
+
+    // combined stream frames are routed by stream name
+    srv.PublishRaw([]byte(`{"stream":"btcusdt@ticker","data":{"e":"24hrTicker","E":1,"s":"BTCUSDT","c":"41000"}}`))
+    assert.Equal("41000", receive(t, ticker.Events()).(*bitnut.WsTickerEvent).LastPrice)
+
+    assert.NoError(ticker.Unsubscribe())
+    assert.Eventually(func() bool { return srv.StreamSubscribers("btcusdt@ticker") == 0 }, time.Second, time.Millisecond)
+    _, ok := <-ticker.Events()
+    assert.False(ok)
+
+    assert.NoError(s.Close())
+    <-s.Done()
+    _, ok = <-depth.Events()
+    assert.False(ok)
+}
+
+func TestStreamReconnect(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    s := testStream(srv)
+    s.PingInterval = 20 * time.Millisecond
+    s.PongWait = 100 * time.Millisecond
+
+    var mu sync.Mutex
+    var errs []error
+    reconnected := make(chan struct{}, 1)
+    s.OnError(func(err error) {
+        mu.Lock()
+        errs = append(errs, err)
+        mu.Unlock()
+    }).OnReconnect(func() { reconnected <- struct{}{} })
+    trades, err := s.SubscribeTrades("ETHUSDT", nil)
+    assert.NoError(err)
+    assert.NoError(s.Connect(context.Background()))
+    defer s.Close()
+    assert.Eventually(func() bool { return srv.StreamSubscribers("ethusdt@trade") == 1 }, time.Second, time.Millisecond)
+
+    // pings keep the idle connection past PongWait
+    time.Sleep(300 * time.Millisecond)
+    assert.Equal(1, srv.StreamConnections())
+
+    srv.DropStreams()
+    select {
+    case <-reconnected:
+    case <-time.After(2 * time.Second):
+        t.Fatal("no reconnect")
+    }
+    assert.Equal(2, srv.StreamConnections())
+    assert.Eventually(func() bool { return srv.StreamSubscribers("ethusdt@trade") == 1 }, time.Second, time.Millisecond)
+    srv.Publish("ethusdt@trade", &bitnut.WsTradeEvent{Event: "trade", Symbol: "ETHUSDT", TradeID: 1})
+    assert.Equal(int64(1), receive(t, trades.Events()).(*bitnut.WsTradeEvent).TradeID)
+
+    srv.PublishRaw([]byte(`{"error":{"code":-1121,"msg":"Invalid symbol."},"id":3}`))
+    assert.Eventually(func() bool {
+        mu.Lock()
+        defer mu.Unlock()
+        for _, err := range errs {
+            if errors.Is(err, common.ErrInvalidSymbol) {
+                return true
+            }
+        }
+        return false
+    }, time.Second, time.Millisecond)
+}
+
+func TestStreamGiveUp(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    s := testStream(srv)
+    s.Reconnect.MaxAttempts = 2
+    _, err := s.SubscribeTrades("BTCUSDT", nil)
+    assert.NoError(err)
+    assert.NoError(s.Connect(context.Background()))
+    srv.Close()
+    select {
+    case <-s.Done():
+    case <-time.After(2 * time.Second):
+        t.Fatal("stream still running")
+    }
+
+    assert.Error(bitnut.NewStream(srv.StreamURL()).Connect(context.Background()))
+}
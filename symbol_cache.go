@@ -0,0 +1,115 @@
+package bitnut
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/hardyzp/bitnut/common"
+)
+
+const defaultSymbolCacheTTL = 30 * time.Minute
+
+// SymbolCache keep the exchange info of every symbol for TTL, it is used by
+// CreateOrderService to check orders against the trading rules
+type SymbolCache struct {
+    c   *Client
+    ttl time.Duration
+
+    mu      sync.Mutex
+    symbols map[string]Symbol
+    // missing are the symbols not listed by the last refresh
+    missing   map[string]bool
+    fetchedAt time.Time
+    now       func() time.Time
+}
+
+// NewSymbolCache init a symbol cache refreshed after ttl, attach it with
+// SetSymbolCache for the order filter checks to use it
+func (c *Client) NewSymbolCache(ttl time.Duration) *SymbolCache {
+    return &SymbolCache{c: c, ttl: ttl, now: time.Now}
+}
+
+// SetSymbolCache set the symbol cache used by the order filter checks, a
+// default one refreshed every 30 minutes is used when none is set
+func (c *Client) SetSymbolCache(sc *SymbolCache) *Client {
+    c.attachMu.Lock()
+    defer c.attachMu.Unlock()
+    c.symbolCache = sc
+    return c
+}
+
+// symbols return the symbol cache of the client, a default one is set on
+// first use
+func (c *Client) symbols() *SymbolCache {
+    c.attachMu.Lock()
+    defer c.attachMu.Unlock()
+    if c.symbolCache == nil {
+        c.symbolCache = c.NewSymbolCache(defaultSymbolCacheTTL)
+    }
+    return c.symbolCache
+}
+
+// Symbol return the metadata of symbol, from the cache when it is fresh.
+// A symbol missing from the cache triggers one refresh before
+// common.ErrInvalidSymbol is returned, it is then reported missing without
+// refresh until the cache expires.
+func (sc *SymbolCache) Symbol(ctx context.Context, symbol string) (*Symbol, error) {
+    return sc.symbol(ctx, sc.c, symbol)
+}
+
+// symbol return the metadata of symbol, refreshing through c
+func (sc *SymbolCache) symbol(ctx context.Context, c *Client, symbol string) (*Symbol, error) {
+    sc.mu.Lock()
+    s, ok := sc.symbols[symbol]
+    fresh := sc.symbols != nil && (sc.ttl <= 0 || sc.now().Sub(sc.fetchedAt) < sc.ttl)
+    missing := fresh && sc.missing[symbol]
+    sc.mu.Unlock()
+    if ok && fresh {
+        return &s, nil
+    }
+    if !missing {
+        err := sc.refresh(ctx, c)
+        if err != nil {
+            return nil, err
+        }
+        sc.mu.Lock()
+        s, ok = sc.symbols[symbol]
+        if !ok {
+            sc.missing[symbol] = true
+        }
+        sc.mu.Unlock()
+    }
+    if !ok {
+        return nil, fmt.Errorf("bitnut: symbol %s is not listed: %w", symbol, common.ErrInvalidSymbol)
+    }
+    return &s, nil
+}
+
+// Refresh fetch the exchange info of every symbol
+func (sc *SymbolCache) Refresh(ctx context.Context) error {
+    return sc.refresh(ctx, sc.c)
+}
+
+func (sc *SymbolCache) refresh(ctx context.Context, c *Client) error {
+    info, err := c.NewExchangeInfoService().Do(ctx)
+    if err != nil {
+        return err
+    }
+    sc.Set(info)
+    return nil
+}
+
+// Set replace the cached symbols, e.g. with exchange info fetched elsewhere
+func (sc *SymbolCache) Set(info *ExchangeInfo) {
+    symbols := make(map[string]Symbol, len(info.Symbols))
+    for _, s := range info.Symbols {
+        symbols[s.Symbol] = s
+    }
+    sc.mu.Lock()
+    defer sc.mu.Unlock()
+    sc.symbols = symbols
+    sc.missing = map[string]bool{}
+    sc.fetchedAt = sc.now()
+}
@@ -5,6 +5,7 @@ import (
     "net/http"
 
     "github.com/hardyzp/bitnut/common"
+    jsoniter "github.com/json-iterator/go"
 )
 
 type ListSymbolTickerService struct {
@@ -47,14 +48,53 @@ func (s *ListSymbolTickerService) Do(ctx context.Context, opts ...RequestOption)
     }
 
     data, err := s.c.callAPI(ctx, r, opts...)
-    data = common.ToJSONList(data)
+    if err != nil {
+        return []*SymbolTicker{}, err
+    }
+    var raw jsoniter.RawMessage
+    _, _, err = decodeResponse(data, &raw)
     if err != nil {
         return []*SymbolTicker{}, err
     }
     res = make([]*SymbolTicker, 0)
-    err = json.Unmarshal(data, &res)
+    err = json.Unmarshal(common.ToJSONList(raw), &res)
     if err != nil {
         return []*SymbolTicker{}, err
     }
     return res, nil
 }
+
+// PriceChangeDecimal return price change as a Decimal, empty parses as zero
+func (t *SymbolTicker) PriceChangeDecimal() (common.Decimal, error) {
+    return parseDecimal("priceChange", t.PriceChange)
+}
+
+// PriceChangePercentDecimal return price change percent as a Decimal, empty parses as zero
+func (t *SymbolTicker) PriceChangePercentDecimal() (common.Decimal, error) {
+    return parseDecimal("priceChangePercent", t.PriceChangePercent)
+}
+
+// HighPriceDecimal return high price as a Decimal, empty parses as zero
+func (t *SymbolTicker) HighPriceDecimal() (common.Decimal, error) {
+    return parseDecimal("highPrice", t.HighPrice)
+}
+
+// LowPriceDecimal return low price as a Decimal, empty parses as zero
+func (t *SymbolTicker) LowPriceDecimal() (common.Decimal, error) {
+    return parseDecimal("lowPrice", t.LowPrice)
+}
+
+// LastPriceDecimal return last price as a Decimal, empty parses as zero
+func (t *SymbolTicker) LastPriceDecimal() (common.Decimal, error) {
+    return parseDecimal("lastPrice", t.LastPrice)
+}
+
+// VolumeDecimal return volume as a Decimal, empty parses as zero
+func (t *SymbolTicker) VolumeDecimal() (common.Decimal, error) {
+    return parseDecimal("volume", t.Volume)
+}
+
+// QuoteVolumeDecimal return quote volume as a Decimal, empty parses as zero
+func (t *SymbolTicker) QuoteVolumeDecimal() (common.Decimal, error) {
+    return parseDecimal("quoteVolume", t.QuoteVolume)
+}
@@ -0,0 +1,209 @@
+package bitnut
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// timeOffset return the clock offset used to sign requests
+func (c *Client) timeOffset() int64 {
+    return atomic.LoadInt64(&c.TimeOffset)
+}
+
+// setTimeOffset set the clock offset used to sign requests
+func (c *Client) setTimeOffset(offset int64) {
+    atomic.StoreInt64(&c.TimeOffset, offset)
+}
+
+// resyncTime measure the clock offset again, with the attached TimeSyncer
+// when there is one
+func (c *Client) resyncTime(ctx context.Context) error {
+    c.attachMu.Lock()
+    syncer := c.timeSyncer
+    c.attachMu.Unlock()
+    if syncer != nil {
+        _, err := syncer.Sync(ctx)
+        return err
+    }
+    _, err := c.NewSetServerTimeService().Do(ctx)
+    return err
+}
+
+// defaultTimeSyncInterval is used by time syncers created without a
+// positive interval
+const defaultTimeSyncInterval = 5 * time.Minute
+
+// DriftStats define the state of the server time synchronisation
+type DriftStats struct {
+    // Offset is the local clock minus the server clock, in milliseconds
+    Offset int64
+    // RoundTrip is the round-trip time of the sample the offset comes from
+    RoundTrip time.Duration
+    // Drift is the change of the offset since the previous sync, in milliseconds
+    Drift int64
+    // MaxDrift is the largest absolute drift seen so far, in milliseconds
+    MaxDrift  int64
+    Syncs     int
+    Failures  int
+    LastSync  time.Time
+    LastError error
+}
+
+// TimeSyncer keep Client.TimeOffset in line with the server clock by polling
+// /v1/time. Each sync takes several samples and keeps the one with the
+// shortest round trip, assuming the server read its clock half way through.
+type TimeSyncer struct {
+    c        *Client
+    interval time.Duration
+    samples  int
+
+    mu     sync.Mutex
+    stats  DriftStats
+    cancel context.CancelFunc
+    done   chan struct{}
+}
+
+// NewTimeSyncer init a time syncer polling every interval, every 5 minutes
+// when interval is not positive. It is attached to the client, which then
+// uses it to resync after timestamp errors.
+func (c *Client) NewTimeSyncer(interval time.Duration) *TimeSyncer {
+    if interval <= 0 {
+        interval = defaultTimeSyncInterval
+    }
+    t := &TimeSyncer{c: c, interval: interval, samples: 3}
+    c.attachMu.Lock()
+    c.timeSyncer = t
+    c.attachMu.Unlock()
+    return t
+}
+
+// Samples set the number of round trips taken on each sync
+func (t *TimeSyncer) Samples(samples int) *TimeSyncer {
+    if samples > 0 {
+        t.samples = samples
+    }
+    return t
+}
+
+// Sync measure the offset now and store it in the client
+func (t *TimeSyncer) Sync(ctx context.Context) (DriftStats, error) {
+    var (
+        bestOffset int64
+        bestRTT    time.Duration = -1
+        err        error
+    )
+    for i := 0; i < t.samples; i++ {
+        var serverTime int64
+        sent := time.Now()
+        serverTime, err = t.c.NewServerTimeService().Do(ctx, WithRetryPolicy(nil))
+        received := time.Now()
+        if err != nil {
+            if ctx.Err() != nil {
+                break
+            }
+            continue
+        }
+        rtt := received.Sub(sent)
+        if bestRTT < 0 || rtt < bestRTT {
+            midpoint := FormatTimestamp(sent.Add(rtt / 2))
+            bestOffset, bestRTT = midpoint-serverTime, rtt
+        }
+    }
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if bestRTT < 0 {
+        if err == nil {
+            err = errors.New("bitnut: no server time sample")
+        }
+        t.stats.Failures++
+        t.stats.LastError = err
+        return t.stats, err
+    }
+    if t.stats.Syncs > 0 {
+        t.stats.Drift = bestOffset - t.stats.Offset
+        if abs := absInt64(t.stats.Drift); abs > t.stats.MaxDrift {
+            t.stats.MaxDrift = abs
+        }
+    }
+    t.stats.Offset = bestOffset
+    t.stats.RoundTrip = bestRTT
+    t.stats.Syncs++
+    t.stats.LastSync = time.Now()
+    t.stats.LastError = nil
+    t.c.setTimeOffset(bestOffset)
+    return t.stats, nil
+}
+
+// Stats return the latest synchronisation statistics
+func (t *TimeSyncer) Stats() DriftStats {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    return t.stats
+}
+
+// Start sync once, then keep syncing in the background until Stop is
+// called or ctx is done. The error of the first sync is returned, the
+// background loop keeps running regardless. It can be started again once
+// stopped.
+func (t *TimeSyncer) Start(ctx context.Context) error {
+    t.mu.Lock()
+    if t.cancel != nil {
+        // the loop also ends with the ctx of the previous Start
+        select {
+        case <-t.done:
+            t.cancel()
+        default:
+            t.mu.Unlock()
+            return errors.New("bitnut: time syncer already started")
+        }
+    }
+    ctx, cancel := context.WithCancel(ctx)
+    done := make(chan struct{})
+    t.cancel = cancel
+    t.done = done
+    t.mu.Unlock()
+
+    _, err := t.Sync(ctx)
+    go t.run(ctx, done)
+    return err
+}
+
+func (t *TimeSyncer) run(ctx context.Context, done chan struct{}) {
+    defer close(done)
+    ticker := time.NewTicker(t.interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if _, err := t.Sync(ctx); err != nil {
+                t.c.log(LogLevelWarn, "server time sync failed", F("error", err))
+            }
+        }
+    }
+}
+
+// Stop end the background loop and wait for it to exit
+func (t *TimeSyncer) Stop() {
+    t.mu.Lock()
+    cancel, done := t.cancel, t.done
+    t.cancel = nil
+    t.mu.Unlock()
+    if cancel == nil {
+        return
+    }
+    cancel()
+    <-done
+}
+
+func absInt64(v int64) int64 {
+    if v < 0 {
+        return -v
+    }
+    return v
+}
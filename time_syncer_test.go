@@ -0,0 +1,82 @@
+package bitnut
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func skewedTimeResponse(skew time.Duration) *http.Response {
+    return jsonResponse(fmt.Sprintf(`{"code":0,"msg":"","data":{"ts":%d}}`, FormatTimestamp(time.Now().Add(skew))))
+}
+
+func TestTimeSyncer(t *testing.T) {
+    assert := assert.New(t)
+    var mu sync.Mutex
+    skew := -5 * time.Second
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        mu.Lock()
+        defer mu.Unlock()
+        return skewedTimeResponse(skew), nil
+    })
+    syncer := c.NewTimeSyncer(10 * time.Millisecond).Samples(2)
+    assert.NoError(syncer.Start(context.Background()))
+    defer syncer.Stop()
+    assert.InDelta(5000, c.timeOffset(), 50)
+
+    mu.Lock()
+    skew = -3 * time.Second
+    mu.Unlock()
+    assert.Eventually(func() bool {
+        stats := syncer.Stats()
+        return stats.Offset < 3100 && stats.MaxDrift >= 1900
+    }, time.Second, 5*time.Millisecond)
+    assert.Error(syncer.Start(context.Background()))
+    syncer.Stop()
+
+    // a loop ended by its ctx can be started again
+    ctx, cancel := context.WithCancel(context.Background())
+    assert.NoError(syncer.Start(ctx))
+    done := syncer.done
+    cancel()
+    <-done
+    assert.NoError(syncer.Start(context.Background()))
+}
+
+func TestTimeSyncerDefaultInterval(t *testing.T) {
+    assert := assert.New(t)
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        return skewedTimeResponse(0), nil
+    })
+    syncer := c.NewTimeSyncer(0)
+    assert.Equal(defaultTimeSyncInterval, syncer.interval)
+    assert.NoError(syncer.Start(context.Background()))
+    syncer.Stop()
+    assert.Equal(defaultTimeSyncInterval, c.NewTimeSyncer(-time.Second).interval)
+}
+
+func TestResyncOnTimestampError(t *testing.T) {
+    assert := assert.New(t)
+    var balanceCalls []string
+    c := newTestClient(func(req *http.Request) (*http.Response, error) {
+        if req.URL.Path == "/v1/time" {
+            return skewedTimeResponse(-time.Minute), nil
+        }
+        balanceCalls = append(balanceCalls, req.URL.RawQuery)
+        if len(balanceCalls) == 1 {
+            return jsonResponse(`{"code":-1021,"msg":"Timestamp for this request is outside of the recvWindow."}`), nil
+        }
+        return jsonResponse(`{"code":0,"msg":"","data":{"coin":"BTC","free":"1","freeze":"0"}}`), nil
+    })
+    c.RecvWindow = 5000
+    _, err := c.NewGetBalanceService().SetCoin("BTC").Do(context.Background(), WithRecvWindow(3000))
+    assert.NoError(err)
+    assert.Len(balanceCalls, 2)
+    assert.Contains(balanceCalls[1], "recvWindow=3000")
+    assert.InDelta(60000, c.timeOffset(), 100)
+}
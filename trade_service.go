@@ -3,16 +3,20 @@ package bitnut
 import (
     "context"
     "net/http"
+    "strconv"
+
+    "github.com/hardyzp/bitnut/common"
 )
 
-// ListTradesService list trades
+// ListTradesService list the trades of the account
 type ListTradesService struct {
     c         *Client
     symbol    string
     startTime *int64
     endTime   *int64
     limit     *int
-    orderId   *int64
+    orderID   *string
+    fromId    *int64
 }
 
 // Symbol set symbol
@@ -39,50 +43,81 @@ func (s *ListTradesService) Limit(limit int) *ListTradesService {
     return s
 }
 
+// OrderID set orderId, to list the trades of one order
+func (s *ListTradesService) OrderID(orderID string) *ListTradesService {
+    s.orderID = &orderID
+    return s
+}
+
 // OrderId set OrderId
+//
+// Deprecated: order ids are strings, use OrderID.
 func (s *ListTradesService) OrderId(OrderId int64) *ListTradesService {
-    s.orderId = &OrderId
+    return s.OrderID(strconv.FormatInt(OrderId, 10))
+}
+
+// FromID set fromId, the trade id to list from
+func (s *ListTradesService) FromID(fromID int64) *ListTradesService {
+    s.fromId = &fromID
     return s
 }
 
 // Do send request
-func (s *ListTradesService) Do(ctx context.Context, opts ...RequestOption) (res []*Trade, err error) {
+func (s *ListTradesService) Do(ctx context.Context, opts ...RequestOption) (res []*AccountTrade, err error) {
     r := &request{
-        method:   http.MethodGet,
-        endpoint: "/api/v3/myTrades",
+        method:   http.MethodPost,
+        endpoint: "/v1/spot/user/trades",
         secType:  secTypeSigned,
     }
-    r.setParam("symbol", s.symbol)
+    r.setFormParam("symbol", s.symbol)
     if s.limit != nil {
-        r.setParam("limit", *s.limit)
+        r.setFormParam("limit", *s.limit)
     }
     if s.startTime != nil {
-        r.setParam("startTime", *s.startTime)
+        r.setFormParam("startTime", *s.startTime)
     }
     if s.endTime != nil {
-        r.setParam("endTime", *s.endTime)
+        r.setFormParam("endTime", *s.endTime)
     }
-
-    if s.orderId != nil {
-        r.setParam("orderId", *s.orderId)
+    if s.orderID != nil {
+        r.setFormParam("orderId", *s.orderID)
+    }
+    if s.fromId != nil {
+        r.setFormParam("fromId", *s.fromId)
     }
     data, err := s.c.callAPI(ctx, r, opts...)
     if err != nil {
-        return []*Trade{}, err
+        return []*AccountTrade{}, err
     }
-    res = make([]*Trade, 0)
-    err = json.Unmarshal(data, &res)
+    res = make([]*AccountTrade, 0)
+    _, _, err = decodeResponse(data, &res)
     if err != nil {
-        return []*Trade{}, err
+        return []*AccountTrade{}, err
     }
     return res, nil
 }
 
-// HistoricalTradesService trades
+// AccountTrade define a trade of the account, with the fee paid on it
+type AccountTrade struct {
+    Symbol          string `json:"symbol"`
+    ID              int64  `json:"id"`
+    OrderID         string `json:"orderId"`
+    Price           string `json:"price"`
+    Quantity        string `json:"qty"`
+    QuoteQuantity   string `json:"quoteQty"`
+    Commission      string `json:"commission"`
+    CommissionAsset string `json:"commissionAsset"`
+    Time            int64  `json:"time"`
+    IsBuyer         bool   `json:"isBuyer"`
+    IsMaker         bool   `json:"isMaker"`
+}
+
+// HistoricalTradesService list older trades of a symbol, page with FromID
 type HistoricalTradesService struct {
     c      *Client
     symbol string
     limit  *int
+    fromId *int64
 }
 
 // Symbol set symbol
@@ -97,28 +132,36 @@ func (s *HistoricalTradesService) Limit(limit int) *HistoricalTradesService {
     return s
 }
 
+// FromID set fromId, the trade id to list from
+func (s *HistoricalTradesService) FromID(fromID int64) *HistoricalTradesService {
+    s.fromId = &fromID
+    return s
+}
+
 // Do send request
 func (s *HistoricalTradesService) Do(ctx context.Context, opts ...RequestOption) (res []*Trade, err error) {
     r := &request{
         method:   http.MethodGet,
-        endpoint: "/api/v3/historicalTrades",
+        endpoint: "/v1/tick/historicalTrades",
         secType:  secTypeAPIKey,
     }
     r.setParam("symbol", s.symbol)
     if s.limit != nil {
         r.setParam("limit", *s.limit)
     }
-
+    if s.fromId != nil {
+        r.setParam("fromId", *s.fromId)
+    }
     data, err := s.c.callAPI(ctx, r, opts...)
     if err != nil {
-        return
+        return []*Trade{}, err
     }
     res = make([]*Trade, 0)
-    err = json.Unmarshal(data, &res)
+    _, _, err = decodeResponse(data, &res)
     if err != nil {
-        return
+        return []*Trade{}, err
     }
-    return
+    return res, nil
 }
 
 // Trade define trade info
@@ -138,6 +181,7 @@ type AggTradesService struct {
     startTime *int64
     endTime   *int64
     limit     *int
+    fromId    *int64
 }
 
 // Symbol set symbol
@@ -164,13 +208,22 @@ func (s *AggTradesService) Limit(limit int) *AggTradesService {
     return s
 }
 
+// FromID set fromId, the aggregate trade id to list from
+func (s *AggTradesService) FromID(fromID int64) *AggTradesService {
+    s.fromId = &fromID
+    return s
+}
+
 // Do send request
 func (s *AggTradesService) Do(ctx context.Context, opts ...RequestOption) (res []*AggTrade, err error) {
     r := &request{
         method:   http.MethodGet,
-        endpoint: "/api/v3/aggTrades",
+        endpoint: "/v1/tick/aggTrades",
     }
     r.setParam("symbol", s.symbol)
+    if s.fromId != nil {
+        r.setParam("fromId", *s.fromId)
+    }
     if s.startTime != nil {
         r.setParam("startTime", *s.startTime)
     }
@@ -185,7 +238,7 @@ func (s *AggTradesService) Do(ctx context.Context, opts ...RequestOption) (res [
         return []*AggTrade{}, err
     }
     res = make([]*AggTrade, 0)
-    err = json.Unmarshal(data, &res)
+    _, _, err = decodeResponse(data, &res)
     if err != nil {
         return []*AggTrade{}, err
     }
@@ -226,7 +279,7 @@ func (s *RecentTradesService) Limit(limit int) *RecentTradesService {
 func (s *RecentTradesService) Do(ctx context.Context, opts ...RequestOption) (res []*Trade, err error) {
     r := &request{
         method:   http.MethodGet,
-        endpoint: "/api/v1/trades",
+        endpoint: "/v1/tick/trades",
     }
     r.setParam("symbol", s.symbol)
     if s.limit != nil {
@@ -237,9 +290,54 @@ func (s *RecentTradesService) Do(ctx context.Context, opts ...RequestOption) (re
         return []*Trade{}, err
     }
     res = make([]*Trade, 0)
-    err = json.Unmarshal(data, &res)
+    _, _, err = decodeResponse(data, &res)
     if err != nil {
         return []*Trade{}, err
     }
     return res, nil
 }
+
+// PriceDecimal return price as a Decimal, empty parses as zero
+func (t *Trade) PriceDecimal() (common.Decimal, error) {
+    return parseDecimal("price", t.Price)
+}
+
+// QuantityDecimal return quantity as a Decimal, empty parses as zero
+func (t *Trade) QuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("qty", t.Quantity)
+}
+
+// QuoteQuantityDecimal return quote quantity as a Decimal, empty parses as zero
+func (t *Trade) QuoteQuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("quoteQty", t.QuoteQuantity)
+}
+
+// PriceDecimal return price as a Decimal, empty parses as zero
+func (t *AggTrade) PriceDecimal() (common.Decimal, error) {
+    return parseDecimal("price", t.Price)
+}
+
+// QuantityDecimal return quantity as a Decimal, empty parses as zero
+func (t *AggTrade) QuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("qty", t.Quantity)
+}
+
+// PriceDecimal return price as a Decimal, empty parses as zero
+func (t *AccountTrade) PriceDecimal() (common.Decimal, error) {
+    return parseDecimal("price", t.Price)
+}
+
+// QuantityDecimal return quantity as a Decimal, empty parses as zero
+func (t *AccountTrade) QuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("qty", t.Quantity)
+}
+
+// QuoteQuantityDecimal return quote quantity as a Decimal, empty parses as zero
+func (t *AccountTrade) QuoteQuantityDecimal() (common.Decimal, error) {
+    return parseDecimal("quoteQty", t.QuoteQuantity)
+}
+
+// CommissionDecimal return commission as a Decimal, empty parses as zero
+func (t *AccountTrade) CommissionDecimal() (common.Decimal, error) {
+    return parseDecimal("commission", t.Commission)
+}
@@ -0,0 +1,273 @@
+package bitnut
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+)
+
+const defaultUserStreamKeepAlive = 30 * time.Minute
+
+// WsOrderUpdateEvent define an execution report, sent on every change of an
+// order. ExecutionType tells what changed, the last fill fields are set
+// when it is ExecutionTypeTrade.
+type WsOrderUpdateEvent struct {
+    Event             UserDataEventType `json:"e"`
+    Time              int64             `json:"E"`
+    Symbol            string            `json:"s"`
+    ClientOrderID     string            `json:"c"`
+    Side              SideType          `json:"S"`
+    Type              OrderType         `json:"o"`
+    TimeInForce       TimeInForceType   `json:"f"`
+    Quantity          string            `json:"q"`
+    Price             string            `json:"p"`
+    StopPrice         string            `json:"P"`
+    IcebergQuantity   string            `json:"F"`
+    OrigClientOrderID string            `json:"C"`
+    ExecutionType     ExecutionType     `json:"x"`
+    Status            OrderStatusType   `json:"X"`
+    RejectReason      string            `json:"r"`
+    OrderID           string            `json:"i"`
+    LastFilledQty     string            `json:"l"`
+    ExecutedQuantity  string            `json:"z"`
+    LastFilledPrice   string            `json:"L"`
+    Commission        string            `json:"n"`
+    CommissionAsset   string            `json:"N"`
+    TransactionTime   int64             `json:"T"`
+    TradeID           int64             `json:"t"`
+    IsMaker           bool              `json:"m"`
+    CreateTime        int64             `json:"O"`
+    QuoteVolume       string            `json:"Z"`
+    LastQuoteQty      string            `json:"Y"`
+    Placeholder       bool              `json:"M"` // add this field to avoid case insensitive unmarshaling
+    Ignore            int64             `json:"I"` // add this field to avoid case insensitive unmarshaling
+}
+
+// Order return the order as reported by GetOrderService after the update
+func (e *WsOrderUpdateEvent) Order() *Order {
+    return &Order{
+        Symbol:           e.Symbol,
+        OrderID:          e.OrderID,
+        ClientOrderID:    e.ClientOrderID,
+        Price:            e.Price,
+        OrigQuantity:     e.Quantity,
+        ExecutedQuantity: e.ExecutedQuantity,
+        Status:           e.Status,
+        Side:             e.Side,
+        Time:             e.CreateTime,
+        UpdateTime:       e.TransactionTime,
+    }
+}
+
+// Fill return the fill of a trade execution report, nil for other reports
+func (e *WsOrderUpdateEvent) Fill() *OrderFill {
+    if e.ExecutionType != ExecutionTypeTrade {
+        return nil
+    }
+    return &OrderFill{
+        Symbol:          e.Symbol,
+        OrderID:         e.OrderID,
+        ClientOrderID:   e.ClientOrderID,
+        TradeID:         e.TradeID,
+        Side:            e.Side,
+        Price:           e.LastFilledPrice,
+        Quantity:        e.LastFilledQty,
+        Commission:      e.Commission,
+        CommissionAsset: e.CommissionAsset,
+        IsMaker:         e.IsMaker,
+        Time:            e.TransactionTime,
+    }
+}
+
+// OrderFill define a single execution of an order, as streamed or
+// returned by CreateOrderService
+type OrderFill struct {
+    Symbol          string   `json:"symbol"`
+    OrderID         string   `json:"orderId"`
+    ClientOrderID   string   `json:"clientOrderId"`
+    TradeID         int64    `json:"tradeId"`
+    Side            SideType `json:"side"`
+    Price           string   `json:"price"`
+    Quantity        string   `json:"qty"`
+    Commission      string   `json:"commission"`
+    CommissionAsset string   `json:"commissionAsset"`
+    IsMaker         bool     `json:"isMaker"`
+    Time            int64    `json:"time"`
+}
+
+// WsBalanceUpdateEvent define a change of the balance of a coin, Delta is
+// the change of the free amount
+type WsBalanceUpdateEvent struct {
+    Event  UserDataEventType `json:"e"`
+    Time   int64             `json:"E"`
+    Coin   string            `json:"a"`
+    Delta  string            `json:"d"`
+    Free   string            `json:"f"`
+    Freeze string            `json:"l"`
+}
+
+// Balance return the balance after the update
+func (e *WsBalanceUpdateEvent) Balance() *Balance {
+    return &Balance{Coin: e.Coin, Free: e.Free, Freeze: e.Freeze}
+}
+
+// UserStream is the private stream of the order, fill and balance updates
+// of the account. The connection is authenticated with the API key. When
+// the exchange uses listen keys, one is created before every connection and
+// kept alive while connected.
+type UserStream struct {
+    // Conn is the underlying connection, for its heartbeat and reconnect
+    // settings and its error and reconnect handlers
+    Conn *Stream
+    // KeepAlive is the interval of the listen key keepalives
+    KeepAlive time.Duration
+    // UseListenKey connect with a listen key, true by default
+    UseListenKey bool
+
+    c         *Client
+    mu        sync.Mutex
+    baseURL   string
+    listenKey string
+}
+
+// NewUserStream init a user data stream on the environment of the client
+func (c *Client) NewUserStream() *UserStream {
+    u := &UserStream{
+        Conn:         c.NewStream(),
+        KeepAlive:    defaultUserStreamKeepAlive,
+        UseListenKey: true,
+        c:            c,
+        baseURL:      c.Environment.UserStreamURL,
+    }
+    u.Conn.URL = u.baseURL
+    u.Conn.private = true
+    u.Conn.prepare = u.prepare
+    return u
+}
+
+// prepare authenticate the next connection
+func (u *UserStream) prepare(ctx context.Context) error {
+    header := u.Conn.Header.Clone()
+    if header == nil {
+        header = http.Header{}
+    }
+    header.Set("BU-ACCESS-KEY", u.c.APIKey)
+    u.Conn.Header = header
+    if !u.UseListenKey {
+        return nil
+    }
+    // the exchange returns the current key while it is alive
+    key, err := u.c.NewStartUserStreamService().Do(ctx)
+    if err != nil {
+        return err
+    }
+    if key == "" {
+        return errors.New("bitnut: empty listen key")
+    }
+    u.mu.Lock()
+    u.listenKey = key
+    u.mu.Unlock()
+    u.Conn.URL = strings.TrimSuffix(u.baseURL, "/") + "/" + key
+    return nil
+}
+
+// ListenKey return the current listen key
+func (u *UserStream) ListenKey() string {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    return u.listenKey
+}
+
+// Connect open the stream and keep the listen key alive until the stream
+// ends
+func (u *UserStream) Connect(ctx context.Context) error {
+    err := u.Conn.Connect(ctx)
+    if err != nil {
+        return err
+    }
+    if u.UseListenKey && u.KeepAlive > 0 {
+        go u.keepAlive(u.Conn.Done())
+    }
+    return nil
+}
+
+func (u *UserStream) keepAlive(done <-chan struct{}) {
+    ticker := time.NewTicker(u.KeepAlive)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-done:
+            return
+        case <-ticker.C:
+            ctx, cancel := context.WithTimeout(context.Background(), u.KeepAlive)
+            err := u.c.NewKeepaliveUserStreamService().ListenKey(u.ListenKey()).Do(ctx)
+            cancel()
+            if err != nil {
+                u.Conn.report(err)
+            }
+        }
+    }
+}
+
+// Close stop the stream and delete the listen key
+func (u *UserStream) Close() error {
+    err := u.Conn.Close()
+    if err != nil {
+        return err
+    }
+    key := u.ListenKey()
+    if !u.UseListenKey || key == "" {
+        return nil
+    }
+    return u.c.NewCloseUserStreamService().ListenKey(key).Do(context.Background())
+}
+
+// Done return a channel closed when the stream ended
+func (u *UserStream) Done() <-chan struct{} {
+    return u.Conn.Done()
+}
+
+// SubscribeOrderUpdates receive every execution report, events are
+// *WsOrderUpdateEvent passed to handler or sent to the subscription channel
+// when handler is nil
+func (u *UserStream) SubscribeOrderUpdates(handler func(*WsOrderUpdateEvent)) (*Subscription, error) {
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*WsOrderUpdateEvent)) }
+    }
+    return u.Conn.subscribe(string(UserDataEventTypeExecutionReport), decoder(func() interface{} { return new(WsOrderUpdateEvent) }), h)
+}
+
+// SubscribeFills receive the fills of the orders with their fees, events
+// are *OrderFill
+func (u *UserStream) SubscribeFills(handler func(*OrderFill)) (*Subscription, error) {
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*OrderFill)) }
+    }
+    decode := func(data []byte) (interface{}, error) {
+        e := new(WsOrderUpdateEvent)
+        err := json.Unmarshal(data, e)
+        if err != nil {
+            return nil, err
+        }
+        if fill := e.Fill(); fill != nil {
+            return fill, nil
+        }
+        return nil, nil
+    }
+    return u.Conn.subscribe(string(UserDataEventTypeExecutionReport), decode, h)
+}
+
+// SubscribeBalanceUpdates receive the balance changes, events are
+// *WsBalanceUpdateEvent
+func (u *UserStream) SubscribeBalanceUpdates(handler func(*WsBalanceUpdateEvent)) (*Subscription, error) {
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*WsBalanceUpdateEvent)) }
+    }
+    return u.Conn.subscribe(string(UserDataEventTypeBalanceUpdate), decoder(func() interface{} { return new(WsBalanceUpdateEvent) }), h)
+}
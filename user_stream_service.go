@@ -0,0 +1,92 @@
+package bitnut
+
+import (
+    "context"
+    "net/http"
+)
+
+const userDataStreamEndpoint = "/v1/userDataStream"
+
+// StartUserStreamService create listen key for user stream service
+type StartUserStreamService struct {
+    c *Client
+}
+
+// Do send request
+func (s *StartUserStreamService) Do(ctx context.Context, opts ...RequestOption) (listenKey string, err error) {
+    r := &request{
+        method:   http.MethodPost,
+        endpoint: userDataStreamEndpoint,
+        secType:  secTypeAPIKey,
+    }
+    data, err := s.c.callAPI(ctx, r, opts...)
+    if err != nil {
+        return "", err
+    }
+    res := new(listenKeyResponse)
+    _, _, err = decodeResponse(data, res)
+    if err != nil {
+        return "", err
+    }
+    return res.ListenKey, nil
+}
+
+type listenKeyResponse struct {
+    ListenKey string `json:"listenKey"`
+}
+
+// KeepaliveUserStreamService update listen key
+type KeepaliveUserStreamService struct {
+    c         *Client
+    listenKey string
+}
+
+// ListenKey set listen key
+func (s *KeepaliveUserStreamService) ListenKey(listenKey string) *KeepaliveUserStreamService {
+    s.listenKey = listenKey
+    return s
+}
+
+// Do send request
+func (s *KeepaliveUserStreamService) Do(ctx context.Context, opts ...RequestOption) (err error) {
+    r := &request{
+        method:   http.MethodPut,
+        endpoint: userDataStreamEndpoint,
+        secType:  secTypeAPIKey,
+    }
+    r.setFormParam("listenKey", s.listenKey)
+    data, err := s.c.callAPI(ctx, r, opts...)
+    if err != nil {
+        return err
+    }
+    _, _, err = decodeResponse(data, nil)
+    return err
+}
+
+// CloseUserStreamService delete listen key
+type CloseUserStreamService struct {
+    c         *Client
+    listenKey string
+}
+
+// ListenKey set listen key
+func (s *CloseUserStreamService) ListenKey(listenKey string) *CloseUserStreamService {
+    s.listenKey = listenKey
+    return s
+}
+
+// Do send request
+func (s *CloseUserStreamService) Do(ctx context.Context, opts ...RequestOption) (err error) {
+    r := &request{
+        method:   http.MethodDelete,
+        endpoint: userDataStreamEndpoint,
+        secType:  secTypeAPIKey,
+    }
+    r.setFormParam("listenKey", s.listenKey)
+    data, err := s.c.callAPI(ctx, r, opts...)
+    if err != nil {
+        return err
+    }
+    _, _, err = decodeResponse(data, nil)
+    return err
+}
@@ -0,0 +1,82 @@
+package bitnut_test
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/hardyzp/bitnut"
+    "github.com/hardyzp/bitnut/bitnuttest"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestUserStream(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    c := srv.Client()
+    ctx := context.Background()
+
+    u := c.NewUserStream()
+    u.Conn.Reconnect = &bitnut.RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+    orders, err := u.SubscribeOrderUpdates(nil)
+    assert.NoError(err)
+    fills, err := u.SubscribeFills(nil)
+    assert.NoError(err)
+    balances, err := u.SubscribeBalanceUpdates(nil)
+    assert.NoError(err)
+    assert.NoError(u.Connect(ctx))
+    assert.Equal([]string{u.ListenKey()}, srv.ListenKeys())
+
+    _, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+        Type(bitnut.OrderTypeLimit).Quantity("0.5").Price("20000").Do(ctx)
+    assert.Error(err)
+    rejected := receive(t, orders.Events()).(*bitnut.WsOrderUpdateEvent)
+    assert.Equal(bitnut.ExecutionTypeRejected, rejected.ExecutionType)
+    assert.Equal(bitnut.OrderStatusTypeRejected, rejected.Status)
+
+    srv.SetBalance("USDT", "15000", "0")
+    _, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+        Type(bitnut.OrderTypeLimit).Quantity("0.5").Price("20000").NewClientOrderID("my-order").Do(ctx)
+    assert.NoError(err)
+    created := receive(t, orders.Events()).(*bitnut.WsOrderUpdateEvent)
+    assert.Equal(bitnut.ExecutionTypeNew, created.ExecutionType)
+    assert.Nil(created.Fill())
+    order, err := c.NewGetOrderService().Symbol("BTCUSDT").OrigClientOrderID("my-order").Do(ctx)
+    assert.NoError(err)
+    assert.Equal(order, created.Order())
+    assert.Equal(&bitnut.Balance{Coin: "USDT", Free: "5000", Freeze: "10000"},
+        receive(t, balances.Events()).(*bitnut.WsBalanceUpdateEvent).Balance())
+
+    assert.NoError(srv.FillOrder(order.OrderID, "0.2"))
+    update := receive(t, orders.Events()).(*bitnut.WsOrderUpdateEvent)
+    assert.Equal(bitnut.OrderStatusTypePartiallyFilled, update.Status)
+    assert.Equal("0.2", update.ExecutedQuantity)
+    fill := receive(t, fills.Events()).(*bitnut.OrderFill)
+    assert.Equal(order.OrderID, fill.OrderID)
+    assert.Equal("my-order", fill.ClientOrderID)
+    assert.Equal("20000", fill.Price)
+    assert.Equal("0.2", fill.Quantity)
+    assert.Equal("0", fill.Commission)
+    assert.Equal("USDT", fill.CommissionAsset)
+    btc := receive(t, balances.Events()).(*bitnut.WsBalanceUpdateEvent)
+    assert.Equal("BTC", btc.Coin)
+    assert.Equal("0.2", btc.Delta)
+    assert.Equal("6000", receive(t, balances.Events()).(*bitnut.WsBalanceUpdateEvent).Balance().Freeze)
+
+    // a new connection keeps the listen key alive
+    srv.DropStreams()
+    assert.Eventually(func() bool { return srv.StreamConnections() == 2 }, 2*time.Second, time.Millisecond)
+
+    _, err = c.NewCancelOrderService().Symbol("BTCUSDT").OrderID(order.OrderID).Do(ctx)
+    assert.NoError(err)
+    canceled := receive(t, orders.Events()).(*bitnut.WsOrderUpdateEvent)
+    assert.Equal(bitnut.ExecutionTypeCanceled, canceled.ExecutionType)
+    assert.Equal(bitnut.OrderStatusTypeCanceled, canceled.Status)
+    released := receive(t, balances.Events()).(*bitnut.WsBalanceUpdateEvent)
+    assert.Equal("6000", released.Delta)
+    assert.Equal(&bitnut.Balance{Coin: "USDT", Free: "11000", Freeze: "0"}, released.Balance())
+
+    assert.NoError(u.Close())
+    assert.Empty(srv.ListenKeys())
+}
@@ -0,0 +1,569 @@
+package bitnut
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "time"
+)
+
+// Watcher defaults
+const (
+    defaultWatchTickerInterval  = 2 * time.Second
+    defaultWatchDepthInterval   = time.Second
+    defaultWatchOrderInterval   = 2 * time.Second
+    defaultWatchBalanceInterval = 5 * time.Second
+    defaultWatchMaxInterval     = 30 * time.Second
+    defaultWatchDepthLimit      = 100
+)
+
+// Watcher polls the REST API and emits the changes it sees as the events
+// of the streams, for deployments that can't hold a websocket open. It is
+// a MarketStream and a UserDataStream, so code written against them falls
+// back to polling without changes. Every
+// subscription is polled on its own interval. The interval grows while
+// nothing changes and after errors, up to MaxInterval, and goes back to its
+// base on the next change. Polls never block on the rate limiter, a poll
+// that would exceed it is postponed.
+type Watcher struct {
+    // Base poll intervals of ticker, depth, order and balance subscriptions
+    TickerInterval  time.Duration
+    DepthInterval   time.Duration
+    OrderInterval   time.Duration
+    BalanceInterval time.Duration
+    // MaxInterval bounds the interval of idle and failing polls
+    MaxInterval time.Duration
+    // DepthLimit is the number of levels polled per side, levels falling
+    // out of it are reported as removed
+    DepthLimit int
+    // Symbols and Coins are the orders and balances polled by
+    // SubscribeOrderUpdates and SubscribeBalanceUpdates, which the user data
+    // stream reports for the whole account
+    Symbols []string
+    Coins   []string
+    // Buffer is the channel size of subscriptions without handler
+    Buffer int
+    Logger Logger
+
+    c       *Client
+    mu      sync.Mutex
+    subs    map[string][]*Subscription
+    pollers map[string]*poller
+    onError func(error)
+    ctx     context.Context
+    cancel  context.CancelFunc
+    done    chan struct{}
+    wg      sync.WaitGroup
+}
+
+// NewWatcher init a watcher polling with the client
+func (c *Client) NewWatcher() *Watcher {
+    return &Watcher{
+        TickerInterval:  defaultWatchTickerInterval,
+        DepthInterval:   defaultWatchDepthInterval,
+        OrderInterval:   defaultWatchOrderInterval,
+        BalanceInterval: defaultWatchBalanceInterval,
+        MaxInterval:     defaultWatchMaxInterval,
+        DepthLimit:      defaultWatchDepthLimit,
+        Buffer:          defaultStreamBuffer,
+        Logger:          c.Logger,
+        c:               c,
+        subs:            map[string][]*Subscription{},
+        pollers:         map[string]*poller{},
+    }
+}
+
+// OnError set the handler of poll and subscription errors
+func (w *Watcher) OnError(fn func(error)) *Watcher {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.onError = fn
+    return w
+}
+
+// poll fetch the current state of a subscription and emit its changes, it
+// return whether anything changed
+type poll func(ctx context.Context, emit func(event interface{})) (bool, error)
+
+// SubscribeTicker poll the 24h ticker of symbol, events are *WsTickerEvent
+// sent on the first poll and whenever the last price changes
+func (w *Watcher) SubscribeTicker(symbol string, handler func(*WsTickerEvent)) (*Subscription, error) {
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*WsTickerEvent)) }
+    }
+    var last string
+    first := true
+    p := func(ctx context.Context, emit func(interface{})) (bool, error) {
+        res, err := w.c.NewListSymbolTickerService().Symbol(symbol).Do(ctx)
+        if err != nil {
+            return false, err
+        }
+        if len(res) == 0 || (!first && res[0].LastPrice == last) {
+            return false, nil
+        }
+        t := res[0]
+        first, last = false, t.LastPrice
+        emit(&WsTickerEvent{
+            Event:              StreamEventTicker,
+            Time:               FormatTimestamp(time.Now()),
+            Symbol:             t.Symbol,
+            PriceChange:        t.PriceChange,
+            PriceChangePercent: t.PriceChangePercent,
+            LastPrice:          t.LastPrice,
+            HighPrice:          t.HighPrice,
+            LowPrice:           t.LowPrice,
+            BaseVolume:         t.Volume,
+            QuoteVolume:        t.QuoteVolume,
+        })
+        return true, nil
+    }
+    return w.subscribe(streamName(symbol, "ticker"), &w.TickerInterval, p, h)
+}
+
+// SubscribeDepth poll the order book of symbol, events are *WsDepthEvent
+// carrying the levels changed since the previous poll. The first poll is
+// not reported, take a snapshot with DepthService as with the stream.
+func (w *Watcher) SubscribeDepth(symbol string, handler func(*WsDepthEvent)) (*Subscription, error) {
+    var h func(interface{})
+    if handler != nil {
+        h = func(e interface{}) { handler(e.(*WsDepthEvent)) }
+    }
+    var prev *Depth
+    var seq int64
+    p := func(ctx context.Context, emit func(interface{})) (bool, error) {
+        cur, err := w.c.NewDepthService().Symbol(symbol).Limit(w.DepthLimit).Do(ctx)
+        if err != nil {
+            return false, err
+        }
+        last := prev
+        prev = cur
+        if last == nil {
+            return false, nil
+        }
+        bids, asks := diffLevels(last.Bids, cur.Bids), diffLevels(last.Asks, cur.Asks)
+        if len(bids) == 0 && len(asks) == 0 {
+            return false, nil
+        }
+        e := &WsDepthEvent{
+            Event:         StreamEventDepthUpdate,
+            Time:          FormatTimestamp(time.Now()),
+            Symbol:        symbol,
+            FirstUpdateID: last.LastUpdateID + 1,
+            LastUpdateID:  cur.LastUpdateID,
+            Bids:          bids,
+            Asks:          asks,
+        }
+        if cur.LastUpdateID == 0 {
+            // the book has no update ids, number the diffs
+            seq++
+            e.FirstUpdateID, e.LastUpdateID = seq, seq
+        }
+        emit(e)
+        return true, nil
+    }
+    return w.subscribe(streamName(symbol, "depth"), &w.DepthInterval, p, h)
+}
+
+// diffLevels return the levels of cur that differ from prev, levels gone
+// from cur have a zero quantity
+func diffLevels(prev, cur [][2]string) [][2]string {
+    before := make(map[string]string, len(prev))
+    for _, l := range prev {
+        before[l[0]] = l[1]
+    }
+    res := make([][2]string, 0)
+    for _, l := range cur {
+        if qty, ok := before[l[0]]; !ok || qty != l[1] {
+            res = append(res, l)
+        }
+        delete(before, l[0])
+    }
+    for _, l := range prev {
+        if _, ok := before[l[0]]; ok {
+            res = append(res, [2]string{l[0], "0"})
+        }
+    }
+    return res
+}
+
+// SubscribeOrderUpdates poll the orders of every symbol of Symbols, events
+// are *WsOrderUpdateEvent sent when an order appears, its status changes or
+// it gets filled. The orders of the first poll are not reported. Fills are
+// reported at the order price, and without trade id and commission, which
+// polling doesn't see.
+func (w *Watcher) SubscribeOrderUpdates(handler func(*WsOrderUpdateEvent)) (*Subscription, error) {
+    if len(w.Symbols) == 0 {
+        return nil, errors.New("bitnut: watcher has no Symbols to poll the orders of")
+    }
+    polls := make([]poll, 0, len(w.Symbols))
+    for _, symbol := range w.Symbols {
+        polls = append(polls, w.pollOrders(symbol))
+    }
+    return w.subscribe(string(UserDataEventTypeExecutionReport), &w.OrderInterval, pollAll(polls), orderUpdateHandler(handler))
+}
+
+// SubscribeSymbolOrderUpdates poll the orders of symbol only, events are
+// those of SubscribeOrderUpdates
+func (w *Watcher) SubscribeSymbolOrderUpdates(symbol string, handler func(*WsOrderUpdateEvent)) (*Subscription, error) {
+    return w.subscribe(streamName(symbol, string(UserDataEventTypeExecutionReport)), &w.OrderInterval, w.pollOrders(symbol), orderUpdateHandler(handler))
+}
+
+func orderUpdateHandler(handler func(*WsOrderUpdateEvent)) func(interface{}) {
+    if handler == nil {
+        return nil
+    }
+    return func(e interface{}) { handler(e.(*WsOrderUpdateEvent)) }
+}
+
+// pollOrders return the poll of the order updates of symbol
+func (w *Watcher) pollOrders(symbol string) poll {
+    var known map[string]Order
+    return func(ctx context.Context, emit func(interface{})) (bool, error) {
+        res, err := w.c.NewListOrdersService().Symbol(symbol).Do(ctx)
+        if err != nil {
+            return false, err
+        }
+        orders := make(map[string]Order, len(res.Data))
+        changed := false
+        for _, o := range res.Data {
+            orders[o.OrderID] = o
+            if known == nil {
+                continue
+            }
+            prev, ok := known[o.OrderID]
+            if !ok {
+                prev = Order{ExecutedQuantity: "0"}
+            } else if prev.Status == o.Status && prev.ExecutedQuantity == o.ExecutedQuantity {
+                continue
+            }
+            e, err := orderUpdate(&prev, &o)
+            if err != nil {
+                return changed, err
+            }
+            emit(e)
+            changed = true
+        }
+        known = orders
+        return changed, nil
+    }
+}
+
+// pollAll return a poll running polls in turn, it stops at the first error
+// and the next round starts over
+func pollAll(polls []poll) poll {
+    return func(ctx context.Context, emit func(interface{})) (bool, error) {
+        changed := false
+        for _, p := range polls {
+            c, err := p(ctx, emit)
+            changed = changed || c
+            if err != nil {
+                return changed, err
+            }
+        }
+        return changed, nil
+    }
+}
+
+// orderUpdate return the execution report of the change of an order from
+// prev to o
+func orderUpdate(prev, o *Order) (*WsOrderUpdateEvent, error) {
+    executed, err := o.ExecutedQuantityDecimal()
+    if err != nil {
+        return nil, err
+    }
+    before, err := prev.ExecutedQuantityDecimal()
+    if err != nil {
+        return nil, err
+    }
+    e := &WsOrderUpdateEvent{
+        Event:            UserDataEventTypeExecutionReport,
+        Time:             FormatTimestamp(time.Now()),
+        Symbol:           o.Symbol,
+        ClientOrderID:    o.ClientOrderID,
+        Side:             o.Side,
+        Quantity:         o.OrigQuantity,
+        Price:            o.Price,
+        Status:           o.Status,
+        OrderID:          o.OrderID,
+        LastFilledQty:    "0",
+        ExecutedQuantity: o.ExecutedQuantity,
+        LastFilledPrice:  "0",
+        TransactionTime:  o.UpdateTime,
+        TradeID:          -1,
+        CreateTime:       o.Time,
+    }
+    switch {
+    case executed.GreaterThan(before):
+        e.ExecutionType = ExecutionTypeTrade
+        e.LastFilledQty = executed.Sub(before).String()
+        e.LastFilledPrice = o.Price
+        e.TradeID = 0
+    case o.Status == OrderStatusTypeCanceled:
+        e.ExecutionType = ExecutionTypeCanceled
+    case o.Status == OrderStatusTypeRejected:
+        e.ExecutionType = ExecutionTypeRejected
+    case o.Status == OrderStatusTypeExpired:
+        e.ExecutionType = ExecutionTypeExpired
+    default:
+        e.ExecutionType = ExecutionTypeNew
+    }
+    return e, nil
+}
+
+// SubscribeBalanceUpdates poll the balance of every coin of Coins, events
+// are *WsBalanceUpdateEvent sent when a balance changes after the first poll
+func (w *Watcher) SubscribeBalanceUpdates(handler func(*WsBalanceUpdateEvent)) (*Subscription, error) {
+    if len(w.Coins) == 0 {
+        return nil, errors.New("bitnut: watcher has no Coins to poll the balances of")
+    }
+    polls := make([]poll, 0, len(w.Coins))
+    for _, coin := range w.Coins {
+        polls = append(polls, w.pollBalance(coin))
+    }
+    return w.subscribe(string(UserDataEventTypeBalanceUpdate), &w.BalanceInterval, pollAll(polls), balanceUpdateHandler(handler))
+}
+
+// SubscribeCoinBalanceUpdates poll the balance of coin only, events are
+// those of SubscribeBalanceUpdates
+func (w *Watcher) SubscribeCoinBalanceUpdates(coin string, handler func(*WsBalanceUpdateEvent)) (*Subscription, error) {
+    return w.subscribe(streamName(coin, string(UserDataEventTypeBalanceUpdate)), &w.BalanceInterval, w.pollBalance(coin), balanceUpdateHandler(handler))
+}
+
+func balanceUpdateHandler(handler func(*WsBalanceUpdateEvent)) func(interface{}) {
+    if handler == nil {
+        return nil
+    }
+    return func(e interface{}) { handler(e.(*WsBalanceUpdateEvent)) }
+}
+
+// pollBalance return the poll of the balance updates of coin
+func (w *Watcher) pollBalance(coin string) poll {
+    var prev *Balance
+    return func(ctx context.Context, emit func(interface{})) (bool, error) {
+        cur, err := w.c.NewGetBalanceService().SetCoin(coin).Do(ctx)
+        if err != nil {
+            return false, err
+        }
+        last := prev
+        prev = cur
+        if last == nil || (last.Free == cur.Free && last.Freeze == cur.Freeze) {
+            return false, nil
+        }
+        free, err := cur.FreeDecimal()
+        if err != nil {
+            return false, err
+        }
+        before, err := last.FreeDecimal()
+        if err != nil {
+            return false, err
+        }
+        emit(&WsBalanceUpdateEvent{
+            Event:  UserDataEventTypeBalanceUpdate,
+            Time:   FormatTimestamp(time.Now()),
+            Coin:   coin,
+            Delta:  free.Sub(before).String(),
+            Free:   cur.Free,
+            Freeze: cur.Freeze,
+        })
+        return true, nil
+    }
+}
+
+func (w *Watcher) subscribe(name string, interval *time.Duration, p poll, handler func(interface{})) (*Subscription, error) {
+    sub := &Subscription{Name: name, s: w, handler: handler}
+    if handler == nil {
+        buffer := w.Buffer
+        if buffer <= 0 {
+            buffer = defaultStreamBuffer
+        }
+        sub.events = make(chan interface{}, buffer)
+    }
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if w.done != nil && w.ctx.Err() != nil {
+        return nil, ErrStreamClosed
+    }
+    w.subs[name] = append(w.subs[name], sub)
+    if _, ok := w.pollers[name]; !ok {
+        pl := &poller{name: name, interval: interval, poll: p}
+        w.pollers[name] = pl
+        if w.ctx != nil {
+            w.start(pl)
+        }
+    }
+    return sub, nil
+}
+
+func (w *Watcher) unsubscribe(sub *Subscription) error {
+    w.mu.Lock()
+    subs := w.subs[sub.Name]
+    for i, other := range subs {
+        if other == sub {
+            subs = append(subs[:i:i], subs[i+1:]...)
+            break
+        }
+    }
+    if len(subs) == 0 {
+        delete(w.subs, sub.Name)
+        if pl, ok := w.pollers[sub.Name]; ok && pl.cancel != nil {
+            pl.cancel()
+        }
+        delete(w.pollers, sub.Name)
+    } else {
+        w.subs[sub.Name] = subs
+    }
+    w.mu.Unlock()
+    sub.close()
+    return nil
+}
+
+// poller define the polling of one subscription name, interval points at
+// the setting of the Watcher, read when polling starts
+type poller struct {
+    name     string
+    interval *time.Duration
+    poll     poll
+    cancel   context.CancelFunc
+}
+
+// Connect start polling the subscriptions in the background until ctx is
+// done or Close is called. Subscriptions made later are polled right away.
+func (w *Watcher) Connect(ctx context.Context) error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if w.done != nil {
+        return ErrStreamConnected
+    }
+    w.ctx, w.cancel = context.WithCancel(ctx)
+    w.done = make(chan struct{})
+    for _, pl := range w.pollers {
+        w.start(pl)
+    }
+    go w.finish()
+    return nil
+}
+
+// start run pl in the background, it is called with w.mu held
+func (w *Watcher) start(pl *poller) {
+    var ctx context.Context
+    ctx, pl.cancel = context.WithCancel(w.ctx)
+    w.wg.Add(1)
+    go w.run(ctx, pl)
+}
+
+// Close stop polling and wait for the pollers to end
+func (w *Watcher) Close() error {
+    w.mu.Lock()
+    cancel, done := w.cancel, w.done
+    w.mu.Unlock()
+    if cancel == nil {
+        return ErrStreamClosed
+    }
+    cancel()
+    <-done
+    return nil
+}
+
+// Done return a channel closed when the watcher stopped
+func (w *Watcher) Done() <-chan struct{} {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.done
+}
+
+// finish wait for the watcher to stop and close the subscription channels
+func (w *Watcher) finish() {
+    <-w.ctx.Done()
+    w.wg.Wait()
+    w.mu.Lock()
+    subs := w.subs
+    w.subs = map[string][]*Subscription{}
+    w.pollers = map[string]*poller{}
+    w.mu.Unlock()
+    for _, list := range subs {
+        for _, sub := range list {
+            sub.close()
+        }
+    }
+    close(w.done)
+}
+
+// run poll until ctx is done, adapting the delay between polls
+func (w *Watcher) run(ctx context.Context, pl *poller) {
+    defer w.wg.Done()
+    interval := *pl.interval
+    if interval <= 0 {
+        interval = time.Second
+    }
+    maxInterval := w.MaxInterval
+    if maxInterval < interval {
+        maxInterval = interval
+    }
+    emit := func(event interface{}) {
+        w.mu.Lock()
+        subs := append([]*Subscription(nil), w.subs[pl.name]...)
+        w.mu.Unlock()
+        for _, sub := range subs {
+            if err := sub.deliver(event); err != nil {
+                w.report(err)
+            }
+        }
+    }
+    var delay time.Duration
+    for {
+        if delay > 0 {
+            timer := time.NewTimer(delay)
+            select {
+            case <-ctx.Done():
+                timer.Stop()
+                return
+            case <-timer.C:
+            }
+        }
+        changed, err := pl.poll(WithoutRateLimitWait(ctx), emit)
+        if ctx.Err() != nil {
+            return
+        }
+        var limited *RateLimitError
+        switch {
+        case errors.As(err, &limited):
+            // over the local rate limit, wait for the budget to free up
+            delay = limited.RetryAfter
+            if delay < interval {
+                delay = interval
+            }
+        case err != nil:
+            w.report(err)
+            delay = backoff(delay, interval, 2, maxInterval)
+        case changed:
+            delay = interval
+        default:
+            delay = backoff(delay, interval, 1.5, maxInterval)
+        }
+    }
+}
+
+// backoff return delay grown by factor, within [interval, max]
+func backoff(delay, interval time.Duration, factor float64, max time.Duration) time.Duration {
+    delay = time.Duration(float64(delay) * factor)
+    if delay < interval {
+        delay = interval
+    }
+    if delay > max {
+        delay = max
+    }
+    return delay
+}
+
+func (w *Watcher) report(err error) {
+    if w.Logger != nil {
+        w.Logger.Log(LogLevelWarn, "watcher error", F("error", err))
+    }
+    w.mu.Lock()
+    onError := w.onError
+    w.mu.Unlock()
+    if onError != nil {
+        onError(err)
+    }
+}
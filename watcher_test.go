@@ -0,0 +1,150 @@
+package bitnut_test
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/hardyzp/bitnut"
+    "github.com/hardyzp/bitnut/bitnuttest"
+    "github.com/stretchr/testify/assert"
+)
+
+func testWatcher(c *bitnut.Client) *bitnut.Watcher {
+    w := c.NewWatcher()
+    w.TickerInterval = 5 * time.Millisecond
+    w.DepthInterval = 5 * time.Millisecond
+    w.OrderInterval = 5 * time.Millisecond
+    w.BalanceInterval = 5 * time.Millisecond
+    w.MaxInterval = 20 * time.Millisecond
+    return w
+}
+
+func TestWatcher(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    c := srv.Client()
+    ctx := context.Background()
+    srv.SetTicker(&bitnut.SymbolTicker{Symbol: "BTCUSDT", LastPrice: "40000"})
+    srv.SetDepth("BTCUSDT", &bitnut.Depth{LastUpdateID: 10, Bids: [][2]string{{"39999", "1"}, {"39998", "2"}}, Asks: [][2]string{{"40001", "1"}}})
+    srv.SetBalance("USDT", "15000", "0")
+
+    w := testWatcher(c)
+    w.OnError(func(err error) { t.Error(err) })
+    _, noSymbols := w.SubscribeOrderUpdates(nil)
+    assert.Error(noSymbols)
+    w.Symbols = []string{"BTCUSDT"}
+    w.Coins = []string{"USDT"}
+    tickers, err := w.SubscribeTicker("BTCUSDT", nil)
+    assert.NoError(err)
+    depth, err := w.SubscribeDepth("BTCUSDT", nil)
+    assert.NoError(err)
+    orders, err := w.SubscribeOrderUpdates(nil)
+    assert.NoError(err)
+    balances, err := w.SubscribeBalanceUpdates(nil)
+    assert.NoError(err)
+    assert.NoError(w.Connect(ctx))
+    defer w.Close()
+    assert.ErrorIs(w.Connect(ctx), bitnut.ErrStreamConnected)
+
+    assert.Equal("40000", receive(t, tickers.Events()).(*bitnut.WsTickerEvent).LastPrice)
+    srv.SetTicker(&bitnut.SymbolTicker{Symbol: "BTCUSDT", LastPrice: "40010"})
+    assert.Equal("40010", receive(t, tickers.Events()).(*bitnut.WsTickerEvent).LastPrice)
+
+    // the first polls are the baseline, a second poll means it was taken
+    for _, endpoint := range []string{"/v1/tick/depth", "/v1/spot/user/order", "/v1/asset/balance"} {
+        endpoint := endpoint
+        assert.Eventually(func() bool { return srv.Requests(endpoint) > 1 }, time.Second, time.Millisecond, endpoint)
+    }
+
+    srv.UpdateDepth("BTCUSDT", [][2]string{{"39998", "0"}, {"39997", "4"}}, [][2]string{{"40001", "3"}})
+    d := receive(t, depth.Events()).(*bitnut.WsDepthEvent)
+    assert.Equal(int64(11), d.FirstUpdateID)
+    assert.Equal(int64(11), d.LastUpdateID)
+    assert.ElementsMatch([][2]string{{"39997", "4"}, {"39998", "0"}}, d.Bids)
+    assert.Equal([][2]string{{"40001", "3"}}, d.Asks)
+
+    _, err = c.NewCreateOrderService().Symbol("BTCUSDT").Side(bitnut.SideTypeBuy).
+        Type(bitnut.OrderTypeLimit).Quantity("0.5").Price("20000").Do(ctx)
+    assert.NoError(err)
+    created := receive(t, orders.Events()).(*bitnut.WsOrderUpdateEvent)
+    assert.Equal(bitnut.ExecutionTypeNew, created.ExecutionType)
+    assert.Equal(&bitnut.WsBalanceUpdateEvent{
+        Event: bitnut.UserDataEventTypeBalanceUpdate, Coin: "USDT", Delta: "-10000", Free: "5000", Freeze: "10000",
+    }, withoutTime(receive(t, balances.Events()).(*bitnut.WsBalanceUpdateEvent)))
+
+    assert.NoError(srv.FillOrder(created.OrderID, "0.2"))
+    filled := receive(t, orders.Events()).(*bitnut.WsOrderUpdateEvent)
+    assert.Equal(bitnut.ExecutionTypeTrade, filled.ExecutionType)
+    assert.Equal(bitnut.OrderStatusTypePartiallyFilled, filled.Status)
+    assert.Equal("0.2", filled.Fill().Quantity)
+    assert.Equal("20000", filled.Fill().Price)
+
+    _, err = c.NewCancelOrderService().Symbol("BTCUSDT").OrderID(created.OrderID).Do(ctx)
+    assert.NoError(err)
+    canceled := receive(t, orders.Events()).(*bitnut.WsOrderUpdateEvent)
+    assert.Equal(bitnut.ExecutionTypeCanceled, canceled.ExecutionType)
+    order, err := c.NewGetOrderService().Symbol("BTCUSDT").OrderID(created.OrderID).Do(ctx)
+    assert.NoError(err)
+    assert.Equal(order, canceled.Order())
+
+    assert.NoError(tickers.Unsubscribe())
+    _, ok := <-tickers.Events()
+    assert.False(ok)
+    polls := srv.Requests("/v1/tick/24info")
+    time.Sleep(50 * time.Millisecond)
+    assert.Equal(polls, srv.Requests("/v1/tick/24info"))
+}
+
+func withoutTime(e *bitnut.WsBalanceUpdateEvent) *bitnut.WsBalanceUpdateEvent {
+    res := *e
+    res.Time = 0
+    return &res
+}
+
+func TestWatcherRateLimit(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    c := srv.Client()
+    config := bitnut.DefaultRateLimiterConfig()
+    config.General = bitnut.RateLimit{Limit: 3, Interval: time.Hour}
+    c.RateLimiter = bitnut.NewRateLimiter(config)
+    srv.SetTicker(&bitnut.SymbolTicker{Symbol: "BTCUSDT", LastPrice: "40000"})
+
+    w := testWatcher(c)
+    w.OnError(func(err error) { t.Error(err) })
+    _, err := w.SubscribeTicker("BTCUSDT", nil)
+    assert.NoError(err)
+    assert.NoError(w.Connect(context.Background()))
+    time.Sleep(100 * time.Millisecond)
+    assert.NoError(w.Close())
+    assert.Equal(3, srv.Requests("/v1/tick/24info"))
+    _, err = w.SubscribeTicker("BTCUSDT", nil)
+    assert.ErrorIs(err, bitnut.ErrStreamClosed)
+}
+
+func TestWatcherLocalOrderBook(t *testing.T) {
+    assert := assert.New(t)
+    srv := bitnuttest.NewServer()
+    defer srv.Close()
+    srv.SetDepth("BTCUSDT", &bitnut.Depth{LastUpdateID: 10, Bids: [][2]string{{"39999", "1"}}, Asks: [][2]string{{"40001", "1"}}})
+    c := srv.Client()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    w := testWatcher(c)
+    assert.NoError(w.Connect(ctx))
+    defer w.Close()
+    b := c.NewLocalOrderBook(w, "BTCUSDT")
+    assert.NoError(b.Start(ctx))
+    assert.NoError(b.WaitSynced(ctx))
+
+    srv.UpdateDepth("BTCUSDT", [][2]string{{"39998", "2"}}, nil)
+    waitUpdate(t, b, 11)
+    book, err := b.Book()
+    assert.NoError(err)
+    bids, _ := levels(book)
+    assert.Equal([]string{"39999x1", "39998x2"}, bids)
+}